@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/PuerkitoBio/goquery"
+	"gopkg.in/yaml.v3"
+)
+
+const altTextFile = "alt.yaml"
+
+// isAltTextPath reports whether a file holds the alt text of an article's
+// images. It configures the build and is not copied.
+func isAltTextPath(path string) bool {
+	return filepath.Base(path) == altTextFile && isArticlePath(directoryIndex(filepath.Dir(path)))
+}
+
+// readAltText reads the alt.yaml of an article bundle, which maps image
+// paths relative to the bundle to their alt text.
+func readAltText(dir string) (map[string]string, error) {
+	path := filepath.Join(dir, altTextFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var altText map[string]string
+	if err := yaml.Unmarshal(data, &altText); err != nil {
+		return nil, fmt.Errorf("Invalid alt text in %s: %w", path, err)
+	}
+
+	return altText, nil
+}
+
+// applyAltText sets the alt text from alt.yaml on the images of an article,
+// taking precedence over the alt written in the source.
+func applyAltText(art *article) error {
+	dir := filepath.Dir(art.source)
+	altText, err := readAltText(dir)
+	if err != nil || len(altText) == 0 {
+		return err
+	}
+
+	art.content, err = modifyHtml(art.content, func(doc *goquery.Document) {
+		doc.Find("img[src]").Each(func(_ int, img *goquery.Selection) {
+			src, _ := img.Attr("src")
+			path, ok := contentPathFromUrl(src, art.source)
+			if !ok {
+				return
+			}
+
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return
+			}
+			if alt, ok := altText[filepath.ToSlash(rel)]; ok {
+				img.SetAttr("alt", alt)
+			}
+		})
+	})
+	return err
+}
+
+// validateAltText reports images without an alt attribute. An empty alt is
+// left alone, since it marks an image as decorative.
+func validateAltText(doc *goquery.Document, page string) {
+	doc.Find("#content img:not([alt])").Each(func(_ int, img *goquery.Selection) {
+		src, _ := img.Attr("src")
+		addDiagnostic("alt-text", page, 0, "image %s has no alt text", src)
+	})
+}
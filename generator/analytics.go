@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"slices"
+)
+
+type analyticsConfig struct {
+	Provider string   `json:"provider"`
+	Domain   string   `json:"domain"`
+	Code     string   `json:"code"`
+	Snippet  string   `json:"snippet"`
+	Exclude  []string `json:"exclude"`
+}
+
+func analyticsSnippet() (string, error) {
+	analytics := config.Analytics
+	switch analytics.Provider {
+	case "":
+		return "", nil
+	case "plausible":
+		return fmt.Sprintf(`<script defer data-domain="%s" src="https://plausible.io/js/script.js"></script>`,
+			html.EscapeString(analytics.Domain)), nil
+	case "goatcounter":
+		return fmt.Sprintf(`<script data-goatcounter="https://%s.goatcounter.com/count" async src="//gc.zgo.at/count.js"></script>`,
+			html.EscapeString(analytics.Code)), nil
+	case "custom":
+		return analytics.Snippet, nil
+	default:
+		return "", fmt.Errorf("Unknown analytics provider: %s", analytics.Provider)
+	}
+}
+
+// injectAnalytics adds the configured snippet to the page head unless the
+// page is excluded in the config, opted out or still a draft.
+func injectAnalytics(head *pageHead, url string, metadata *articleInfo) error {
+	if slices.Contains(config.Analytics.Exclude, url) {
+		return nil
+	}
+	if metadata != nil && (metadata.NoAnalytics || metadata.Draft) {
+		return nil
+	}
+
+	snippet, err := analyticsSnippet()
+	if err != nil || snippet == "" {
+		return err
+	}
+
+	head.add("analytics", "", snippet)
+	return nil
+}
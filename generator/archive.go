@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type archiveConfig struct {
+	Enabled bool   `json:"enabled"`
+	File    string `json:"file"`
+	Days    int    `json:"days"`
+	Limit   int    `json:"limit"`
+	Delay   int    `json:"delay"`
+	Links   bool   `json:"links"`
+}
+
+// archivedLink is a snapshot of an external page in the Wayback Machine.
+type archivedLink struct {
+	Url        string    `json:"url"`
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+var (
+	archivedLinks       map[string]archivedLink
+	archivedLinksLoaded bool
+	archiveSubmissions  int
+	lastArchiveRequest  time.Time
+	archiveClient       = &http.Client{Timeout: 2 * time.Minute, Transport: networkTransport{}}
+)
+
+func archiveFile() string {
+	if config.Archive.File != "" {
+		return config.Archive.File
+	}
+
+	return "archived-links.json"
+}
+
+func saveArchivedLinks() error {
+	if !archivedLinksLoaded {
+		return nil
+	}
+
+	return writeCacheFile(archiveFile(), archivedLinks)
+}
+
+// submitToArchive asks the Wayback Machine to save a page and returns the
+// url of the snapshot. Requests are spaced by the configured delay.
+func submitToArchive(link string) (string, error) {
+	if err := requireNetwork("link archiving", link); err != nil {
+		return "", err
+	}
+
+	delay := time.Duration(config.Archive.Delay) * time.Second
+	if config.Archive.Delay <= 0 {
+		delay = 5 * time.Second
+	}
+	time.Sleep(time.Until(lastArchiveRequest.Add(delay)))
+	lastArchiveRequest = time.Now()
+
+	req, err := http.NewRequest("GET", "https://web.archive.org/save/"+link, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "site-generator")
+
+	resp, err := archiveClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("the archive returned %s for %s", resp.Status, link)
+	}
+
+	if location := resp.Header.Get("Content-Location"); strings.HasPrefix(location, "/web/") {
+		return "https://web.archive.org" + location, nil
+	}
+	if strings.HasPrefix(resp.Request.URL.Path, "/web/") {
+		return resp.Request.URL.String(), nil
+	}
+	return "", fmt.Errorf("the archive did not return a snapshot for %s", link)
+}
+
+// isExternalLink reports whether a link leaves the site.
+func isExternalLink(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+
+	base, err := url.Parse(config.BaseUrl)
+	return err != nil || base.Host != u.Host
+}
+
+// archiveArticleLinks submits the external links of recent articles to the
+// Wayback Machine, once each and at most limit a build, and records the
+// snapshots in the archive file. With links set, every link with a
+// snapshot is followed by a link to its archived copy.
+func archiveArticleLinks(art *article) error {
+	if !archivedLinksLoaded {
+		archivedLinksLoaded = true
+		archivedLinks = make(map[string]archivedLink)
+		readCacheFile(archiveFile(), &archivedLinks)
+	}
+
+	days := config.Archive.Days
+	if days <= 0 {
+		days = 30
+	}
+	limit := config.Archive.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+	recent := time.Since(art.date) < time.Duration(days)*24*time.Hour
+
+	var err error
+	art.content, err = modifyHtml(art.content, func(doc *goquery.Document) {
+		doc.Find("a[href]").Each(func(_ int, link *goquery.Selection) {
+			href, _ := link.Attr("href")
+			if !isExternalLink(href) {
+				return
+			}
+
+			archived, ok := archivedLinks[href]
+			if !ok && recent && archiveSubmissions < limit {
+				archiveSubmissions++
+				snapshot, err := submitToArchive(href)
+				if err != nil {
+					addDiagnostic("network", art.source, 0, "Cannot archive link: %s", err)
+					return
+				}
+				archived = archivedLink{Url: snapshot, ArchivedAt: time.Now()}
+				archivedLinks[href] = archived
+				ok = true
+			}
+
+			if ok && config.Archive.Links {
+				link.AfterHtml(fmt.Sprintf(` <a class="archived-copy" href="%s">(archived copy)</a>`,
+					html.EscapeString(archived.Url)))
+			}
+		})
+	})
+	return err
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// mediumPostId is the id Medium appends to the slug of a post url.
+var mediumPostId = regexp.MustCompile(`-[0-9a-f]{8,12}$`)
+
+// openArchive opens an export either as the zip file it was downloaded as
+// or as the directory it was extracted to.
+func openArchive(source string) (fs.FS, func() error, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Cannot open export: %w", err)
+	}
+	if info.IsDir() {
+		return os.DirFS(source), func() error { return nil }, nil
+	}
+
+	archive, err := zip.OpenReader(source)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Cannot open export %s: %w", source, err)
+	}
+	return archive, archive.Close, nil
+}
+
+// stripExportMarkup drops the classes and ids an export platform puts on
+// every element.
+func stripExportMarkup(doc *goquery.Selection) {
+	doc.Find("[class], [id], [name], [data-image-id], [data-width], [data-height]").Each(func(_ int, element *goquery.Selection) {
+		for _, name := range []string{"class", "id", "name", "data-image-id", "data-width", "data-height"} {
+			element.RemoveAttr(name)
+		}
+	})
+}
+
+func (imp *importer) importMediumPost(archive fs.FS, name string) error {
+	data, err := fs.ReadFile(archive, name)
+	if err != nil {
+		return err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(data)))
+	if err != nil {
+		return fmt.Errorf("Cannot parse %s: %w", name, err)
+	}
+
+	title := strings.TrimSpace(doc.Find("h1.p-name").First().Text())
+	if title == "" {
+		title = strings.TrimSpace(doc.Find("title").First().Text())
+	}
+
+	body := doc.Find("section[data-field=body]").First()
+	body.Find(".graf--title").First().Remove()
+	body.Find("hr.section-divider").Remove()
+	// Medium wraps every part of a post in section and div elements.
+	body.Find("section, .section-inner").Each(func(_ int, wrapper *goquery.Selection) {
+		wrapper.Contents().Unwrap()
+	})
+	stripExportMarkup(body)
+	content, err := body.Html()
+	if err != nil {
+		return err
+	}
+
+	date := time.Now()
+	if published, ok := doc.Find("time.dt-published").Attr("datetime"); ok {
+		if date, err = time.Parse(time.RFC3339, published); err != nil {
+			return fmt.Errorf("Invalid date in %s: %s", name, published)
+		}
+	}
+
+	post := importedPost{
+		title: title,
+		date:  date,
+		html:  content,
+		draft: strings.HasPrefix(path.Base(name), "draft_"),
+	}
+	if canonical, ok := doc.Find("a.p-canonical").Attr("href"); ok {
+		if u, err := url.Parse(canonical); err == nil {
+			post.slug = mediumPostId.ReplaceAllString(path.Base(u.Path), "")
+		}
+	}
+
+	return imp.writePost(post)
+}
+
+// importMedium imports the posts of a Medium export. Medium does not export
+// tags, and its urls live on another domain, so there are no aliases.
+func (imp *importer) importMedium(source string) error {
+	archive, closeArchive, err := openArchive(source)
+	if err != nil {
+		return err
+	}
+	defer closeArchive()
+
+	posts, err := fs.Glob(archive, "posts/*.html")
+	if err != nil {
+		return err
+	}
+	if len(posts) == 0 {
+		return fmt.Errorf("No posts found in %s", source)
+	}
+
+	for _, name := range posts {
+		if err := imp.importMediumPost(archive, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importSubstack imports a Substack export: posts.csv lists the posts and
+// posts/<id>.html holds their bodies. The /p/<slug> path of every post is
+// kept as an alias for when the domain moves along.
+func (imp *importer) importSubstack(source string) error {
+	archive, closeArchive, err := openArchive(source)
+	if err != nil {
+		return err
+	}
+	defer closeArchive()
+
+	file, err := archive.Open("posts.csv")
+	if err != nil {
+		return fmt.Errorf("No posts.csv in %s: %w", source, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("Cannot read posts.csv: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range []string{"post_id", "post_date", "is_published", "title"} {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("posts.csv has no %s column", name)
+		}
+	}
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Cannot read posts.csv: %w", err)
+		}
+
+		id := row[columns["post_id"]]
+		body, err := fs.ReadFile(archive, "posts/"+id+".html")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping %s: %s\n", id, err)
+			imp.skipped++
+			continue
+		}
+
+		date := time.Now()
+		if value := row[columns["post_date"]]; value != "" {
+			if date, err = time.Parse(time.RFC3339, value); err != nil {
+				return fmt.Errorf("Invalid date of %s: %s", id, value)
+			}
+		}
+
+		content, err := modifyHtml(string(body), func(doc *goquery.Document) {
+			doc.Find(".subscription-widget-wrap, .button-wrapper, .subscribe-widget").Remove()
+			stripExportMarkup(doc.Selection)
+		})
+		if err != nil {
+			return err
+		}
+
+		// Post ids look like 12345678.the-slug.
+		slug := id
+		if i := strings.Index(id, "."); i != -1 {
+			slug = id[i+1:]
+		}
+
+		err = imp.writePost(importedPost{
+			slug:    slug,
+			title:   row[columns["title"]],
+			date:    date,
+			html:    content,
+			draft:   row[columns["is_published"]] != "true",
+			aliases: []string{"/p/" + slug},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	adocHeading     = regexp.MustCompile(`^(={1,6})\s+(.+)$`)
+	adocAttribute   = regexp.MustCompile(`^:([\w-]+):\s*(.*)$`)
+	adocBlockAttrs  = regexp.MustCompile(`^\[([^\]]*)\]$`)
+	adocBlockTitle  = regexp.MustCompile(`^\.([^.\s].*)$`)
+	adocImage       = regexp.MustCompile(`^image::([^\[]+)\[([^\]]*)\]$`)
+	adocAdmonition  = regexp.MustCompile(`^(NOTE|TIP|IMPORTANT|WARNING|CAUTION):\s+(.*)$`)
+	adocListItem    = regexp.MustCompile(`^(\*+|-|\.+)\s+(.*)$`)
+	adocDescription = regexp.MustCompile(`^(.+?)::(?:\s+(.*))?$`)
+	adocReference   = regexp.MustCompile(`\{([\w-]+)\}`)
+
+	adocInlineCode   = regexp.MustCompile("`([^`]+)`")
+	adocInlineImage  = regexp.MustCompile(`image:([^\s\[]+)\[([^\]]*)\]`)
+	adocLinkMacro    = regexp.MustCompile(`link:([^\s\[]+)\[([^\]]*)\]`)
+	adocUrl          = regexp.MustCompile(`(https?://[^\s\[<]+)(?:\[([^\]]*)\])?`)
+	adocXref         = regexp.MustCompile(`&lt;&lt;([\w-]+)(?:,\s*([^&]+))?&gt;&gt;|xref:([\w-]+)\[([^\]]*)\]`)
+	adocStrong       = regexp.MustCompile(`(^|[^\w*])\*([^*\s](?:[^*]*[^*\s])?)\*([^\w*]|$)`)
+	adocEmphasis     = regexp.MustCompile(`(^|[^\w_])_([^_\s](?:[^_]*[^_\s])?)_([^\w_]|$)`)
+	adocPlaceholder  = regexp.MustCompile("\x00(\\d+)\x00")
+	adocDelimiters   = []string{"----", "....", "____", "****", "====", "++++", "////"}
+	adocAdmonitionOf = map[string]string{"NOTE": "Note", "TIP": "Tip", "IMPORTANT": "Important", "WARNING": "Warning", "CAUTION": "Caution"}
+)
+
+// asciidocConverter renders the parts of AsciiDoc that articles use:
+// sections, paragraphs, lists, blocks, images, links and inline formatting.
+type asciidocConverter struct {
+	attributes map[string]string
+	out        strings.Builder
+}
+
+func convertAsciidoc(path string, data []byte) (convertedSource, error) {
+	converter := &asciidocConverter{attributes: make(map[string]string)}
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	converter.blocks(lines)
+	return convertedSource{html: converter.out.String()}, nil
+}
+
+// inline applies the inline formatting to one escaped line of text. Code
+// spans are set aside first so nothing inside them is formatted.
+func (c *asciidocConverter) inline(text string) string {
+	text = adocReference.ReplaceAllStringFunc(text, func(match string) string {
+		if value, ok := c.attributes[match[1:len(match)-1]]; ok {
+			return value
+		}
+		return match
+	})
+
+	var kept []string
+	keep := func(fragment string) string {
+		kept = append(kept, fragment)
+		return fmt.Sprintf("\x00%d\x00", len(kept)-1)
+	}
+
+	text = adocInlineCode.ReplaceAllStringFunc(text, func(match string) string {
+		return keep("<code>" + html.EscapeString(match[1:len(match)-1]) + "</code>")
+	})
+	text = adocInlineImage.ReplaceAllStringFunc(text, func(match string) string {
+		parts := adocInlineImage.FindStringSubmatch(match)
+		return keep(fmt.Sprintf(`<img src="%s" alt="%s">`, html.EscapeString(parts[1]), html.EscapeString(parts[2])))
+	})
+	text = adocLinkMacro.ReplaceAllStringFunc(text, func(match string) string {
+		parts := adocLinkMacro.FindStringSubmatch(match)
+		return keep(fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(parts[1]), html.EscapeString(parts[2])))
+	})
+	text = adocUrl.ReplaceAllStringFunc(text, func(match string) string {
+		parts := adocUrl.FindStringSubmatch(match)
+		label := parts[2]
+		if label == "" {
+			label = parts[1]
+		}
+		return keep(fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(parts[1]), html.EscapeString(label)))
+	})
+
+	text = html.EscapeString(text)
+	text = adocXref.ReplaceAllStringFunc(text, func(match string) string {
+		parts := adocXref.FindStringSubmatch(match)
+		id, label := parts[1]+parts[3], parts[2]+parts[4]
+		if label == "" {
+			label = id
+		}
+		return fmt.Sprintf(`<a href="#%s">%s</a>`, id, label)
+	})
+	text = adocStrong.ReplaceAllString(text, "$1<strong>$2</strong>$3")
+	text = adocEmphasis.ReplaceAllString(text, "$1<em>$2</em>$3")
+
+	return adocPlaceholder.ReplaceAllStringFunc(text, func(match string) string {
+		var i int
+		fmt.Sscanf(strings.Trim(match, "\x00"), "%d", &i)
+		return kept[i]
+	})
+}
+
+func adocSectionId(title string) string {
+	return "_" + strings.ReplaceAll(slugify(title), "-", "_")
+}
+
+func isAdocDelimiter(line string) bool {
+	for _, delimiter := range adocDelimiters {
+		if line == delimiter {
+			return true
+		}
+	}
+
+	return false
+}
+
+// blocks converts a run of lines. Block attributes and titles apply to the
+// block that follows them.
+func (c *asciidocConverter) blocks(lines []string) {
+	var attrs, title string
+	for i := 0; i < len(lines); {
+		line := strings.TrimRight(lines[i], " \t")
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "//") && line != "////":
+			i++
+			continue
+		case adocAttribute.MatchString(line):
+			parts := adocAttribute.FindStringSubmatch(line)
+			c.attributes[parts[1]] = parts[2]
+			i++
+			continue
+		case adocBlockAttrs.MatchString(line):
+			attrs = adocBlockAttrs.FindStringSubmatch(line)[1]
+			i++
+			continue
+		case adocBlockTitle.MatchString(line):
+			title = adocBlockTitle.FindStringSubmatch(line)[1]
+			i++
+			continue
+		}
+
+		caption := ""
+		if title != "" {
+			caption = `<div class="title">` + c.inline(title) + `</div>`
+		}
+
+		switch {
+		case adocHeading.MatchString(line):
+			parts := adocHeading.FindStringSubmatch(line)
+			level := len(parts[1])
+			fmt.Fprintf(&c.out, `<h%d id="%s">%s</h%d>`+"\n", level, adocSectionId(parts[2]), c.inline(parts[2]), level)
+			i++
+		case line == "'''":
+			c.out.WriteString("<hr>\n")
+			i++
+		case line == "<<<":
+			i++
+		case isAdocDelimiter(line):
+			end := i + 1
+			for end < len(lines) && strings.TrimRight(lines[end], " \t") != line {
+				end++
+			}
+			c.delimitedBlock(line, attrs, caption, lines[i+1:min(end, len(lines))])
+			i = end + 1
+		case adocImage.MatchString(line):
+			parts := adocImage.FindStringSubmatch(line)
+			alt := strings.SplitN(parts[2], ",", 2)[0]
+			figcaption := ""
+			if title != "" {
+				figcaption = "<figcaption>" + c.inline(title) + "</figcaption>"
+			}
+			fmt.Fprintf(&c.out, `<figure><img src="%s" alt="%s">%s</figure>`+"\n",
+				html.EscapeString(parts[1]), html.EscapeString(alt), figcaption)
+			i++
+		case adocListItem.MatchString(line):
+			i = c.list(lines, i)
+		case adocDescription.MatchString(line) && !strings.Contains(line, "://"):
+			i = c.descriptionList(lines, i)
+		default:
+			end := i
+			for end < len(lines) && strings.TrimSpace(lines[end]) != "" && !isAdocDelimiter(strings.TrimRight(lines[end], " \t")) &&
+				(end == i || !adocListItem.MatchString(lines[end]) && !adocBlockAttrs.MatchString(lines[end])) {
+				end++
+			}
+			text := strings.Join(lines[i:end], "\n")
+			if parts := adocAdmonition.FindStringSubmatch(text); parts != nil {
+				fmt.Fprintf(&c.out, `<div class="admonition %s">%s<p><strong>%s:</strong> %s</p></div>`+"\n",
+					strings.ToLower(parts[1]), caption, adocAdmonitionOf[parts[1]], c.inline(parts[2]))
+			} else {
+				fmt.Fprintf(&c.out, "%s<p>%s</p>\n", caption, c.inline(text))
+			}
+			i = end
+		}
+
+		attrs, title = "", ""
+	}
+}
+
+func (c *asciidocConverter) delimitedBlock(delimiter string, attrs string, caption string, content []string) {
+	text := strings.Join(content, "\n")
+	switch delimiter {
+	case "----":
+		language := ""
+		if parts := strings.Split(attrs, ","); len(parts) > 1 && strings.TrimSpace(parts[0]) == "source" {
+			language = strings.TrimSpace(parts[1])
+		}
+		c.out.WriteString(caption + highlightCode(text+"\n", language) + "\n")
+	case "....":
+		c.out.WriteString(caption + "<pre>" + html.EscapeString(text) + "</pre>\n")
+	case "++++":
+		c.out.WriteString(text + "\n")
+	case "////":
+	default:
+		tag, class := "blockquote", ""
+		switch delimiter {
+		case "****":
+			tag, class = "aside", "sidebar"
+		case "====":
+			tag, class = "div", "example"
+		}
+		if admonition, ok := adocAdmonitionOf[attrs]; ok {
+			tag, class = "div", "admonition "+strings.ToLower(attrs)
+			caption = "<p><strong>" + admonition + ":</strong></p>" + caption
+		}
+
+		if class != "" {
+			fmt.Fprintf(&c.out, `<%s class="%s">`, tag, class)
+		} else {
+			fmt.Fprintf(&c.out, "<%s>", tag)
+		}
+		c.out.WriteString(caption)
+		c.blocks(content)
+		fmt.Fprintf(&c.out, "</%s>\n", tag)
+	}
+}
+
+// list converts the list starting at line i, nesting items by the length
+// of their marker, and returns the line after it.
+func (c *asciidocConverter) list(lines []string, i int) int {
+	type level struct {
+		marker string
+		tag    string
+	}
+	var open []level
+
+	for ; i < len(lines); i++ {
+		parts := adocListItem.FindStringSubmatch(strings.TrimRight(lines[i], " \t"))
+		if parts == nil {
+			break
+		}
+
+		marker := parts[1]
+		tag := "ul"
+		if strings.HasPrefix(marker, ".") {
+			tag = "ol"
+		}
+
+		depth := -1
+		for j, l := range open {
+			if l.marker == marker {
+				depth = j
+			}
+		}
+		if depth == -1 {
+			fmt.Fprintf(&c.out, "<%s>", tag)
+			open = append(open, level{marker, tag})
+		} else {
+			for len(open) > depth+1 {
+				fmt.Fprintf(&c.out, "</li></%s>", open[len(open)-1].tag)
+				open = open[:len(open)-1]
+			}
+			c.out.WriteString("</li>")
+		}
+
+		item := parts[2]
+		if strings.HasPrefix(item, "[ ] ") || strings.HasPrefix(item, "[x] ") || strings.HasPrefix(item, "[*] ") {
+			checked := ""
+			if item[1] != ' ' {
+				checked = " checked"
+			}
+			item = item[4:]
+			fmt.Fprintf(&c.out, `<li><input type="checkbox" disabled%s> %s`, checked, c.inline(item))
+			continue
+		}
+		c.out.WriteString("<li>" + c.inline(item))
+	}
+
+	for len(open) > 0 {
+		fmt.Fprintf(&c.out, "</li></%s>", open[len(open)-1].tag)
+		open = open[:len(open)-1]
+	}
+	c.out.WriteString("\n")
+	return i
+}
+
+func (c *asciidocConverter) descriptionList(lines []string, i int) int {
+	c.out.WriteString("<dl>")
+	for ; i < len(lines); i++ {
+		parts := adocDescription.FindStringSubmatch(strings.TrimRight(lines[i], " \t"))
+		if parts == nil {
+			break
+		}
+
+		fmt.Fprintf(&c.out, "<dt>%s</dt>", c.inline(parts[1]))
+		definition := parts[2]
+		if definition == "" && i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" && !adocDescription.MatchString(lines[i+1]) {
+			i++
+			definition = strings.TrimSpace(lines[i])
+		}
+		if definition != "" {
+			fmt.Fprintf(&c.out, "<dd>%s</dd>", c.inline(definition))
+		}
+	}
+	c.out.WriteString("</dl>\n")
+	return i
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// backlinks lists, per article source, the articles that link to it.
+var backlinks map[string][]siteEntry
+
+// linkedArticle finds the article a link on the page of from points to, by
+// its content path or by its url.
+func linkedArticle(link string, from siteEntry) (siteEntry, bool) {
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return siteEntry{}, false
+	}
+
+	source, _ := contentPathFromUrl(link, from.source)
+	if info, err := os.Stat(source); err == nil && info.IsDir() {
+		source = directoryIndex(source)
+	}
+
+	target := u.Path
+	if !strings.HasPrefix(target, "/") {
+		target = path.Join(path.Dir(from.url), target)
+	}
+	if strings.HasSuffix(u.Path, "/") {
+		target = strings.TrimSuffix(target, "/") + "/index.html"
+	}
+
+	for _, entry := range siteArticles {
+		if entry.source == source || entry.url == target {
+			return entry, true
+		}
+	}
+
+	return siteEntry{}, false
+}
+
+// loadBacklinks reads the links of every article before the build, so the
+// first article built already knows who links to it.
+func loadBacklinks() error {
+	backlinks = make(map[string][]siteEntry)
+	for _, from := range siteArticles {
+		source, err := readSourceHtml(from.source)
+		if err != nil {
+			return err
+		}
+
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(source))
+		if err != nil {
+			return fmt.Errorf("Failed to parse %s: %w", from.source, err)
+		}
+
+		linked := make(map[string]bool)
+		doc.Find("a[href]").Each(func(_ int, link *goquery.Selection) {
+			href, _ := link.Attr("href")
+			target, ok := linkedArticle(href, from)
+			if !ok || target.source == from.source || linked[target.source] {
+				return
+			}
+			linked[target.source] = true
+			backlinks[target.source] = append(backlinks[target.source], from)
+		})
+	}
+
+	for _, list := range backlinks {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].date.After(list[j].date)
+		})
+	}
+
+	return nil
+}
+
+func backlinksSection(entries []siteEntry) string {
+	var list strings.Builder
+	for _, entry := range entries {
+		fmt.Fprintf(&list, `<li><a href="%s">%s</a></li>`, html.EscapeString(entry.url), html.EscapeString(entry.title))
+	}
+
+	return fmt.Sprintf(`<section class="backlinks"><h2>This post is referenced by</h2><ul>%s</ul></section>`, list.String())
+}
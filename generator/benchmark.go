@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var benchmarkWords = strings.Fields(`the a site build page article static generator content template feed
+	markdown image link section tag series cache render parse write read fast slow simple small large
+	language reader writer morning evening river mountain coffee garden window letter story`)
+
+func benchmarkSentence(rng *rand.Rand) string {
+	words := make([]string, 6+rng.IntN(14))
+	for i := range words {
+		words[i] = benchmarkWords[rng.IntN(len(benchmarkWords))]
+	}
+
+	return strings.ToUpper(words[0][:1]) + words[0][1:] + " " + strings.Join(words[1:], " ") + "."
+}
+
+// writeBenchmarkCorpus fills dir with n articles of Markdown, with headings,
+// lists, code, tags, series and links between articles. The corpus is the
+// same on every run, so timings compare.
+func writeBenchmarkCorpus(dir string, n int) error {
+	rng := rand.New(rand.NewPCG(1, 2))
+	start := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < n; i++ {
+		var text strings.Builder
+		fmt.Fprintf(&text, "# Article %d\n\n", i)
+		for section := 0; section < 3+rng.IntN(4); section++ {
+			fmt.Fprintf(&text, "## Part %d\n\n", section+1)
+			for paragraph := 0; paragraph < 2+rng.IntN(3); paragraph++ {
+				for sentence := 0; sentence < 3+rng.IntN(5); sentence++ {
+					text.WriteString(benchmarkSentence(rng) + " ")
+				}
+				text.WriteString("\n\n")
+			}
+			if rng.IntN(2) == 0 {
+				text.WriteString("- " + benchmarkSentence(rng) + "\n- " + benchmarkSentence(rng) + "\n\n")
+			}
+			if rng.IntN(3) == 0 {
+				text.WriteString("```go\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n```\n\n")
+			}
+		}
+		if i > 0 {
+			fmt.Fprintf(&text, "See also [an earlier article](/articles/post-%04d/index.html).\n", rng.IntN(i))
+		}
+
+		tags := []string{fmt.Sprintf("topic-%d", rng.IntN(20)), fmt.Sprintf("topic-%d", rng.IntN(20))}
+		metadata := fmt.Sprintf(`{"release_date": %q, "word_count": %d, "estimated_time": %d, "tags": [%q, %q], "series": %q}`,
+			start.AddDate(0, 0, i*3).Format(time.DateOnly), text.Len()/6, 1+text.Len()/1500, tags[0], tags[1],
+			fmt.Sprintf("series-%d", rng.IntN(10)))
+
+		article := filepath.Join(dir, "articles", fmt.Sprintf("post-%04d", i))
+		if err := createDir(article); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(article, "index.md"), []byte(text.String()), 0644); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(article, "metadata.json"), []byte(metadata), 0644); err != nil {
+			return err
+		}
+	}
+
+	return os.WriteFile(filepath.Join(dir, "about.md"), []byte("# About\n\n"+benchmarkSentence(rng)+"\n"), 0644)
+}
+
+// runBenchmark builds a synthetic corpus several times with the built-in
+// template and reports how long the builds took. Every build runs in a
+// child process, so no state carries over from one to the next.
+func runBenchmark(args []string) error {
+	flags := flag.NewFlagSet("benchmark", flag.ContinueOnError)
+	count := flags.Int("articles", 1000, "number of articles in the corpus")
+	runs := flags.Int("runs", 3, "number of builds")
+	profile := flags.String("profile", "", "record a cpu, mem or trace profile of the last build")
+	profileFile := flags.String("profile-file", "", "file to write the profile to, a temporary file by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *count < 1 || *runs < 1 {
+		return fmt.Errorf("Usage: benchmark [--articles n] [--runs n] [--profile cpu|mem|trace] [--profile-file path]")
+	}
+
+	// The builds run in the corpus directory, which is removed at the end,
+	// so the profile goes elsewhere.
+	var profileTarget string
+	if *profile != "" {
+		path, err := profilePath(*profile, *profileFile)
+		if err != nil {
+			return err
+		}
+		if profileTarget, err = filepath.Abs(path); err != nil {
+			return err
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "site-benchmark-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	content := filepath.Join(dir, "content")
+	if err := writeBenchmarkCorpus(content, *count); err != nil {
+		return fmt.Errorf("Failed to write the corpus: %w", err)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	var total, fastest time.Duration
+	for run := 1; run <= *runs; run++ {
+		buildArgs := []string{"build"}
+		if *profile != "" && run == *runs {
+			buildArgs = append(buildArgs, "--profile", *profile, "--profile-file", profileTarget)
+		}
+
+		var output bytes.Buffer
+		cmd := exec.Command(executable, buildArgs...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "CONTENT_PATH="+content, "TARGET_PATH="+filepath.Join(dir, "site"),
+			"CONFIG_PATH=", "TEMPLATE_PATH=")
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+
+		started := time.Now()
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("Build %d failed: %w\n%s", run, err, output.String())
+		}
+		elapsed := time.Since(started)
+
+		total += elapsed
+		if run == 1 || elapsed < fastest {
+			fastest = elapsed
+		}
+		fmt.Printf("build %d: %s\n", run, elapsed.Round(time.Millisecond))
+	}
+
+	mean := total / time.Duration(*runs)
+	fmt.Printf("%d articles, mean %s, fastest %s, %.0f articles/s\n", *count, mean.Round(time.Millisecond),
+		fastest.Round(time.Millisecond), float64(*count)/fastest.Seconds())
+
+	if *profile != "" {
+		fmt.Printf("Profile written to %s\n", profileTarget)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain lets a benchmark run the test binary as the generator, so every
+// build starts from a fresh process, like the benchmark command does.
+func TestMain(m *testing.M) {
+	if os.Getenv("SITE_BENCHMARK_BUILD") == "1" {
+		os.Args = []string{os.Args[0], "build"}
+		main()
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+func BenchmarkBuild(b *testing.B) {
+	for _, count := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("articles=%d", count), func(b *testing.B) {
+			dir := b.TempDir()
+			content := filepath.Join(dir, "content")
+			if err := writeBenchmarkCorpus(content, count); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				cmd := exec.Command(os.Args[0])
+				cmd.Dir = dir
+				cmd.Env = append(os.Environ(), "SITE_BENCHMARK_BUILD=1", "CONTENT_PATH="+content,
+					"TARGET_PATH="+filepath.Join(dir, "site"), "CONFIG_PATH=", "TEMPLATE_PATH=")
+				if output, err := cmd.CombinedOutput(); err != nil {
+					b.Fatalf("build failed: %v\n%s", err, output)
+				}
+			}
+			b.ReportMetric(float64(count*b.N)/b.Elapsed().Seconds(), "articles/s")
+		})
+	}
+}
+
+func BenchmarkReadSourceHtml(b *testing.B) {
+	dir := b.TempDir()
+	content := filepath.Join(dir, "content")
+	b.Setenv("CONTENT_PATH", content)
+	b.Setenv("TARGET_PATH", filepath.Join(dir, "site"))
+	b.Setenv("CONFIG_PATH", "")
+	saved := config
+	b.Cleanup(func() { config = saved })
+
+	if err := writeBenchmarkCorpus(content, 100); err != nil {
+		b.Fatal(err)
+	}
+	sources, err := filepath.Glob(filepath.Join(content, "articles", "*", "index.md"))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, source := range sources {
+			if _, err := readSourceHtml(source); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.ReportMetric(float64(len(sources)*b.N)/b.Elapsed().Seconds(), "articles/s")
+}
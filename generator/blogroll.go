@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type blogrollConfig struct {
+	Enabled    bool   `json:"enabled"`
+	File       string `json:"file"`
+	Url        string `json:"url"`
+	Title      string `json:"title"`
+	Posts      int    `json:"posts"`
+	CacheFile  string `json:"cache_file"`
+	CacheHours int    `json:"cache_hours"`
+}
+
+// blogrollEntry is a blog listed in the blogroll file. The title, when set,
+// replaces the one in its feed.
+type blogrollEntry struct {
+	Feed  string `json:"feed"`
+	Title string `json:"title"`
+}
+
+type blogrollPost struct {
+	Title string    `json:"title"`
+	Link  string    `json:"link"`
+	Date  time.Time `json:"date"`
+}
+
+type blogrollFeed struct {
+	Title     string         `json:"title"`
+	Link      string         `json:"link"`
+	Posts     []blogrollPost `json:"posts"`
+	FetchedAt time.Time      `json:"fetched_at"`
+}
+
+// feedDocument reads both RSS and Atom feeds; only the fields of the format
+// found are filled.
+type feedDocument struct {
+	Channel struct {
+		Title string `xml:"title"`
+		Link  string `xml:"link"`
+		Items []struct {
+			Title   string `xml:"title"`
+			Link    string `xml:"link"`
+			PubDate string `xml:"pubDate"`
+			Date    string `xml:"http://purl.org/dc/elements/1.1/ date"`
+		} `xml:"item"`
+	} `xml:"channel"`
+	Title   string     `xml:"title"`
+	Links   []atomLink `xml:"link"`
+	Entries []struct {
+		Title     string     `xml:"title"`
+		Links     []atomLink `xml:"link"`
+		Published string     `xml:"published"`
+		Updated   string     `xml:"updated"`
+	} `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+var (
+	blogrollFeeds       map[string]blogrollFeed
+	blogrollFeedsLoaded bool
+)
+
+var feedDateLayouts = []string{
+	time.RFC3339, time.RFC1123Z, time.RFC1123, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700", "2006-01-02",
+}
+
+func blogrollCacheFile() string {
+	if config.Blogroll.CacheFile != "" {
+		return config.Blogroll.CacheFile
+	}
+
+	return cachePath("blogroll-feeds.json")
+}
+
+func saveBlogrollFeeds() error {
+	if !blogrollFeedsLoaded {
+		return nil
+	}
+
+	return writeCacheFile(blogrollCacheFile(), blogrollFeeds)
+}
+
+func parseFeedDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range feedDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date
+		}
+	}
+
+	return time.Time{}
+}
+
+func alternateLink(links []atomLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+
+	return ""
+}
+
+func fetchBlogrollFeed(link string) (blogrollFeed, error) {
+	if err := requireNetwork("blogroll", link); err != nil {
+		return blogrollFeed{}, err
+	}
+
+	req, err := http.NewRequest("GET", link, nil)
+	if err != nil {
+		return blogrollFeed{}, err
+	}
+	req.Header.Set("User-Agent", "site-generator")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return blogrollFeed{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return blogrollFeed{}, fmt.Errorf("%s returned %s", link, resp.Status)
+	}
+
+	var document feedDocument
+	decoder := xml.NewDecoder(resp.Body)
+	decoder.Strict = false
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) { return input, nil }
+	if err := decoder.Decode(&document); err != nil {
+		return blogrollFeed{}, fmt.Errorf("Invalid feed %s: %w", link, err)
+	}
+
+	feed := blogrollFeed{Title: document.Title, Link: alternateLink(document.Links), FetchedAt: time.Now()}
+	if document.Channel.Title != "" {
+		feed.Title, feed.Link = document.Channel.Title, document.Channel.Link
+	}
+	for _, item := range document.Channel.Items {
+		date := parseFeedDate(item.PubDate)
+		if date.IsZero() {
+			date = parseFeedDate(item.Date)
+		}
+		feed.Posts = append(feed.Posts, blogrollPost{Title: item.Title, Link: item.Link, Date: date})
+	}
+	for _, entry := range document.Entries {
+		date := parseFeedDate(entry.Published)
+		if date.IsZero() {
+			date = parseFeedDate(entry.Updated)
+		}
+		feed.Posts = append(feed.Posts, blogrollPost{Title: entry.Title, Link: alternateLink(entry.Links), Date: date})
+	}
+
+	sort.SliceStable(feed.Posts, func(i, j int) bool {
+		return feed.Posts[i].Date.After(feed.Posts[j].Date)
+	})
+	limit := config.Blogroll.Posts
+	if limit <= 0 {
+		limit = 3
+	}
+	feed.Posts = feed.Posts[:min(limit, len(feed.Posts))]
+
+	return feed, nil
+}
+
+// loadBlogrollFeed returns the cached feed while it is fresh and falls back to
+// a stale one when fetching fails, so a blog that is down does not break
+// the build.
+func loadBlogrollFeed(link string) (blogrollFeed, bool) {
+	if !blogrollFeedsLoaded {
+		blogrollFeedsLoaded = true
+		blogrollFeeds = make(map[string]blogrollFeed)
+		readCacheFile(blogrollCacheFile(), &blogrollFeeds)
+	}
+
+	ttl := 6 * time.Hour
+	if config.Blogroll.CacheHours > 0 {
+		ttl = time.Duration(config.Blogroll.CacheHours) * time.Hour
+	}
+
+	cached, ok := blogrollFeeds[link]
+	if ok && (time.Since(cached.FetchedAt) < ttl || offline) {
+		return cached, true
+	}
+
+	feed, err := fetchBlogrollFeed(link)
+	if err != nil {
+		addDiagnostic("network", link, 0, "Cannot fetch blogroll feed: %s", err)
+		return cached, ok
+	}
+
+	blogrollFeeds[link] = feed
+	return feed, true
+}
+
+// writeBlogroll writes a page listing the latest posts of every blog in the
+// blogroll file, the most recently updated blog first.
+func writeBlogroll() error {
+	file := config.Blogroll.File
+	if file == "" {
+		file = "blogroll.json"
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("Cannot read blogroll: %w", err)
+	}
+
+	var entries []blogrollEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("Invalid blogroll %s: %w", file, err)
+	}
+
+	var feeds []blogrollFeed
+	for _, entry := range entries {
+		feed, ok := loadBlogrollFeed(entry.Feed)
+		if !ok {
+			continue
+		}
+		if entry.Title != "" {
+			feed.Title = entry.Title
+		}
+		if feed.Link == "" {
+			feed.Link = entry.Feed
+		}
+		feeds = append(feeds, feed)
+	}
+
+	latest := func(feed blogrollFeed) time.Time {
+		if len(feed.Posts) == 0 {
+			return time.Time{}
+		}
+		return feed.Posts[0].Date
+	}
+	sort.SliceStable(feeds, func(i, j int) bool {
+		return latest(feeds[i]).After(latest(feeds[j]))
+	})
+
+	title := config.Blogroll.Title
+	if title == "" {
+		title = "Blogroll"
+	}
+
+	var content strings.Builder
+	fmt.Fprintf(&content, `<section class="blogroll"><h1>%s</h1>`, html.EscapeString(title))
+	for _, feed := range feeds {
+		fmt.Fprintf(&content, `<section class="blogroll-blog"><h2><a href="%s">%s</a></h2><ul>`,
+			html.EscapeString(feed.Link), html.EscapeString(feed.Title))
+		for _, post := range feed.Posts {
+			fmt.Fprintf(&content, `<li><a href="%s">%s</a>`, html.EscapeString(post.Link), html.EscapeString(post.Title))
+			if !post.Date.IsZero() {
+				fmt.Fprintf(&content, ` <time datetime="%s">%s</time>`, post.Date.Format("2006-01-02"), post.Date.Format("2006-01-02"))
+			}
+			content.WriteString("</li>")
+		}
+		content.WriteString("</ul></section>")
+	}
+	content.WriteString("</section>")
+
+	u := config.Blogroll.Url
+	if u == "" {
+		u = "/blogroll.html"
+	}
+
+	tmpl, err := templateWithVariables(pageVariables(u, title))
+	if err != nil {
+		return err
+	}
+
+	tmpl.Find("#content").SetHtml(content.String())
+	if err := finalizePage(tmpl, &pageHead{}, u, nil); err != nil {
+		return err
+	}
+
+	final, err := tmpl.Html()
+	if err != nil {
+		return fmt.Errorf("Failed to serialize HTML: %w", err)
+	}
+
+	target := targetPathFromUrl(u)
+	if err := claimOutput(target, "the blogroll"); err != nil {
+		return err
+	}
+	if err := createDir(filepath.Dir(target)); err != nil {
+		return err
+	}
+	sitemapPages[u] = buildTime.UTC().Format("2006-01-02")
+
+	return writeOutputFile(target, []byte(final))
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// previewBranch is the branch a --preview build is for. A preview is built
+// into previews/<branch>/ of the target and served from there, so that CI
+// can publish one per branch next to each other.
+var previewBranch string
+
+// sitePath is the directory the site is served from, empty unless it is a
+// preview.
+var sitePath string
+
+// noscriptLink finds the links in the content of a noscript element, which
+// is only text to a parser that runs scripts.
+var noscriptLink = regexp.MustCompile(`\b(href|src)="([^"]*)"`)
+
+func previewDirectory() string {
+	return "previews/" + slugify(previewBranch)
+}
+
+// applyPreview points the config of a preview build at its directory and
+// keeps the preview out of search engines. The pings that noindex builds
+// skip are skipped with it.
+func applyPreview() error {
+	if previewBranch == "" {
+		return nil
+	}
+	if slugify(previewBranch) == "" {
+		return fmt.Errorf("Invalid preview branch: %q", previewBranch)
+	}
+
+	sitePath = "/" + previewDirectory()
+	if config.BaseUrl != "" {
+		config.BaseUrl = strings.TrimSuffix(config.BaseUrl, "/") + sitePath
+	}
+	config.Noindex = true
+	return nil
+}
+
+// siteUrl is a root-relative url as it is served, below the directory of a
+// preview. Other urls are returned as they are.
+func siteUrl(u string) string {
+	if sitePath == "" || !strings.HasPrefix(u, "/") || strings.HasPrefix(u, "//") {
+		return u
+	}
+	return sitePath + u
+}
+
+func rewriteSrcset(srcset string) string {
+	candidates := strings.Split(srcset, ",")
+	for i, candidate := range candidates {
+		fields := strings.Fields(candidate)
+		if len(fields) > 0 {
+			fields[0] = siteUrl(fields[0])
+			candidates[i] = strings.Join(fields, " ")
+		}
+	}
+	return strings.Join(candidates, ", ")
+}
+
+func rewriteCssUrls(css string) string {
+	return cssUrl.ReplaceAllStringFunc(css, func(match string) string {
+		link := cssUrl.FindStringSubmatch(match)[1]
+		return strings.Replace(match, link, siteUrl(link), 1)
+	})
+}
+
+// setRawText replaces the content of a style or noscript element, whose text
+// is written out as it is rather than escaped.
+func setRawText(element *goquery.Selection, text string) {
+	for _, node := range element.Nodes {
+		for node.FirstChild != nil {
+			node.RemoveChild(node.FirstChild)
+		}
+		node.AppendChild(&html.Node{Type: html.TextNode, Data: text})
+	}
+}
+
+func rewritePageLinks(doc *goquery.Document) {
+	for _, name := range []string{"href", "src", "poster", "action", "formaction"} {
+		doc.Find("[" + name + "]").Each(func(_ int, element *goquery.Selection) {
+			link, _ := element.Attr(name)
+			element.SetAttr(name, siteUrl(link))
+		})
+	}
+	doc.Find("object[data]").Each(func(_ int, element *goquery.Selection) {
+		link, _ := element.Attr("data")
+		element.SetAttr("data", siteUrl(link))
+	})
+	doc.Find("[srcset]").Each(func(_ int, element *goquery.Selection) {
+		srcset, _ := element.Attr("srcset")
+		element.SetAttr("srcset", rewriteSrcset(srcset))
+	})
+	doc.Find("[style]").Each(func(_ int, element *goquery.Selection) {
+		style, _ := element.Attr("style")
+		element.SetAttr("style", rewriteCssUrls(style))
+	})
+	doc.Find("style").Each(func(_ int, element *goquery.Selection) {
+		setRawText(element, rewriteCssUrls(element.Text()))
+	})
+	doc.Find("noscript").Each(func(_ int, element *goquery.Selection) {
+		setRawText(element, noscriptLink.ReplaceAllStringFunc(element.Text(), func(match string) string {
+			parts := noscriptLink.FindStringSubmatch(match)
+			return fmt.Sprintf(`%s="%s"`, parts[1], siteUrl(parts[2]))
+		}))
+	})
+	doc.Find(`meta[http-equiv="refresh"][content]`).Each(func(_ int, element *goquery.Selection) {
+		content, _ := element.Attr("content")
+		if delay, link, ok := strings.Cut(content, "url="); ok {
+			element.SetAttr("content", delay+"url="+siteUrl(link))
+		}
+	})
+}
+
+// rewritePreviewLinks moves every root-relative link of the built pages and
+// stylesheets below the directory of the preview. It runs once everything
+// is written, so that the features and reports before it all see the site
+// as it is at the root.
+func rewritePreviewLinks() error {
+	if sitePath == "" {
+		return nil
+	}
+
+	return filepath.WalkDir(targetDirectory(), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		var rewrite func(data []byte) ([]byte, error)
+		switch filepath.Ext(path) {
+		case ".html":
+			rewrite = func(data []byte) ([]byte, error) {
+				doc, err := goquery.NewDocumentFromReader(bytes.NewReader(data))
+				if err != nil {
+					return nil, fmt.Errorf("Failed to parse %s: %w", path, err)
+				}
+				rewritePageLinks(doc)
+
+				final, err := doc.Html()
+				if err != nil {
+					return nil, fmt.Errorf("Failed to serialize HTML of %s: %w", path, err)
+				}
+				return []byte(final), nil
+			}
+		case ".css":
+			rewrite = func(data []byte) ([]byte, error) {
+				return []byte(rewriteCssUrls(string(data))), nil
+			}
+		default:
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rewritten, err := rewrite(data)
+		if err != nil {
+			return err
+		}
+		return writeOutputFile(path, rewritten)
+	})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type budgetConfig struct {
+	Enabled bool `json:"enabled"`
+	MaxKb   int  `json:"max_kb"`
+	Fail    bool `json:"fail"`
+	Top     int  `json:"top"`
+}
+
+type pageResource struct {
+	url  string
+	size int64
+}
+
+// loadedResources lists the local files a browser fetches to show the page,
+// including those referenced from its stylesheets.
+func loadedResources(pageUrl string, doc *goquery.Document) []string {
+	references := make(map[string]bool)
+	add := func(link string) {
+		addReference(references, pageUrl, link)
+	}
+
+	doc.Find(`link[href]`).Each(func(_ int, link *goquery.Selection) {
+		rel, _ := link.Attr("rel")
+		if strings.Contains(rel, "stylesheet") || strings.Contains(rel, "preload") || strings.Contains(rel, "icon") {
+			href, _ := link.Attr("href")
+			add(href)
+		}
+	})
+	doc.Find("script[src], img[src], audio[src], video[src], source[src], embed[src]").Each(func(_ int, element *goquery.Selection) {
+		src, _ := element.Attr("src")
+		add(src)
+	})
+	doc.Find("video[poster]").Each(func(_ int, video *goquery.Selection) {
+		poster, _ := video.Attr("poster")
+		add(poster)
+	})
+	doc.Find("style, [style]").Each(func(_ int, element *goquery.Selection) {
+		css := element.Text()
+		if style, ok := element.Attr("style"); ok {
+			css = style
+		}
+		cssReferences(references, pageUrl, css)
+	})
+
+	for u := range references {
+		if strings.HasSuffix(u, ".css") {
+			if data, err := os.ReadFile(targetPathFromUrl(u)); err == nil {
+				cssReferences(references, u, string(data))
+			}
+		}
+	}
+
+	var urls []string
+	for u := range references {
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+// checkBudget adds up the weight of every generated page and reports the
+// pages above the configured budget with their heaviest resources.
+func checkBudget() error {
+	if config.Budget.MaxKb <= 0 {
+		return fmt.Errorf("The size budget needs a positive max_kb")
+	}
+
+	pages, err := generatedPages()
+	if err != nil {
+		return err
+	}
+
+	limit := int64(config.Budget.MaxKb) * 1024
+	top := config.Budget.Top
+	if top <= 0 {
+		top = 5
+	}
+
+	var over []string
+	for path, doc := range pages {
+		pageUrl := outputUrl(path)
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		resources := []pageResource{{pageUrl, info.Size()}}
+		for _, u := range loadedResources(pageUrl, doc) {
+			if info, err := os.Stat(targetPathFromUrl(u)); err == nil && !info.IsDir() {
+				resources = append(resources, pageResource{u, info.Size()})
+			}
+		}
+
+		var total int64
+		for _, resource := range resources {
+			total += resource.size
+		}
+		if total <= limit {
+			continue
+		}
+
+		sort.Slice(resources, func(i, j int) bool {
+			return resources[i].size > resources[j].size
+		})
+
+		var report strings.Builder
+		fmt.Fprintf(&report, "%s: %d KB, budget %d KB\n", pageUrl, total/1024, config.Budget.MaxKb)
+		for _, resource := range resources[:min(top, len(resources))] {
+			fmt.Fprintf(&report, "  %7d KB  %s\n", resource.size/1024, resource.url)
+		}
+		over = append(over, report.String())
+	}
+
+	sort.Strings(over)
+	for _, report := range over {
+		fmt.Print(report)
+	}
+
+	if config.Budget.Fail && len(over) > 0 {
+		return fmt.Errorf("%d pages are over the size budget", len(over))
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// buildState is what a build was made from: the generator and its config,
+// the templates it used and every file of the content, by hash. It is kept
+// in the cache so the next build into the same target can tell what changed.
+type buildState struct {
+	Config    string            `json:"config"`
+	Templates map[string]string `json:"templates"`
+	Content   map[string]string `json:"content"`
+}
+
+var (
+	// incremental reuses the assets of the previous build that did not
+	// change instead of writing them again, set with --incremental.
+	incremental bool
+
+	previousBuild *buildState
+	currentBuild  = buildState{Templates: make(map[string]string), Content: make(map[string]string)}
+)
+
+func buildStateFile() string {
+	return cachePath("build-state.json")
+}
+
+// buildStateKey tells the targets apart in the state file, since previews
+// and other environments may build from the same cache.
+func buildStateKey() string {
+	path, err := filepath.Abs(outputDirectory())
+	if err != nil {
+		return outputDirectory()
+	}
+	return path
+}
+
+func hashData(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// recordTemplate notes a template the build read, so a change to it is seen
+// by the next build.
+func recordTemplate(path string, data []byte) {
+	if _, ok := currentBuild.Templates[templateName(path)]; !ok {
+		currentBuild.Templates[templateName(path)] = hashData(data)
+	}
+}
+
+// templatesChanged reports whether a template the previous build used
+// changed since. The main template counts as used even when it was not
+// recorded.
+func templatesChanged(previous buildState) bool {
+	used := map[string]string{templateName(templatePath()): ""}
+	for name, hash := range previous.Templates {
+		used[name] = hash
+	}
+
+	for name, hash := range used {
+		path := name
+		if name == templateName("") {
+			path = ""
+		}
+		data, err := readTemplateFile(path)
+		if err != nil || hashData(data) != hash {
+			return true
+		}
+	}
+	return false
+}
+
+// loadBuildState hashes what this build is made from and compares it with
+// the previous build. When only the templates changed, every page comes out
+// different while the content stays the same, which is worth saying, since
+// the diff of the site will be large.
+func loadBuildState() error {
+	settings, err := json.Marshal(config)
+	if err == nil {
+		currentBuild.Config = hashData(append([]byte(version+"\n"), settings...))
+	}
+
+	err = filepath.WalkDir(contentDirectory(), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if isExcludedPath(path) {
+			return skipExcluded(entry)
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(contentDirectory(), path)
+		if err != nil {
+			return err
+		}
+		currentBuild.Content[filepath.ToSlash(rel)] = hash
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Cannot hash content: %w", err)
+	}
+
+	states := make(map[string]buildState)
+	readCacheFile(buildStateFile(), &states)
+	previous, ok := states[buildStateKey()]
+	if !ok {
+		return nil
+	}
+	previousBuild = &previous
+
+	if templatesChanged(previous) && previous.Config == currentBuild.Config && sameContent(previous.Content, currentBuild.Content) {
+		if incremental {
+			fmt.Println("Only the template changed since the last build: every page is regenerated, no content changed, assets are reused")
+		} else {
+			fmt.Println("Only the template changed since the last build: every page is regenerated, no content changed; build with --incremental to reuse the assets")
+		}
+	}
+	return nil
+}
+
+func sameContent(a map[string]string, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, hash := range a {
+		if b[path] != hash {
+			return false
+		}
+	}
+	return true
+}
+
+// saveBuildState records the finished build for the next one to compare
+// itself with.
+func saveBuildState() error {
+	states := make(map[string]buildState)
+	readCacheFile(buildStateFile(), &states)
+	states[buildStateKey()] = currentBuild
+
+	return writeCacheFile(buildStateFile(), states)
+}
+
+// reuseAsset links an asset of the previous build into this one when the
+// asset and the config are the same as they were then, instead of copying
+// or cleaning it again. Pages are always built again, since they depend on
+// much more than their source.
+func reuseAsset(path string) bool {
+	if !incremental || previousBuild == nil || currentBuild.Config == "" || previousBuild.Config != currentBuild.Config {
+		return false
+	}
+
+	rel, err := filepath.Rel(contentDirectory(), path)
+	if err != nil {
+		return false
+	}
+	if hash, ok := previousBuild.Content[filepath.ToSlash(rel)]; !ok || hash != currentBuild.Content[filepath.ToSlash(rel)] {
+		return false
+	}
+
+	target := targetPathFromContentPath(path)
+	built, err := filepath.Rel(targetDirectory(), target)
+	if err != nil {
+		return false
+	}
+	previous := filepath.Join(outputDirectory(), built)
+	if info, err := os.Stat(previous); err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+
+	// Output files are only ever replaced, never written in place, so the
+	// previous build keeps its copy intact while the link is shared.
+	return recordOutput(target, path) == nil && os.Link(previous, target) == nil
+}
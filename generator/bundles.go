@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// bundleUrl resolves a link relative to the article source into the url of
+// the copied file, so it keeps working wherever the content is embedded.
+func bundleUrl(link string, source string) (string, bool) {
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || strings.HasPrefix(u.Path, "/") {
+		return "", false
+	}
+
+	path, _ := contentPathFromUrl(link, source)
+	if _, isAsset := sourceAsset(path); !fileExists(path) && !isAsset {
+		addDiagnostic("missing-file", source, 0, "links to missing file %s", link)
+	}
+
+	resolved := urlFromContentPath(path)
+	if strings.HasSuffix(u.Path, "/") {
+		resolved += "/"
+	}
+	if u.RawQuery != "" {
+		resolved += "?" + u.RawQuery
+	}
+	if u.Fragment != "" {
+		resolved += "#" + u.Fragment
+	}
+
+	return resolved, true
+}
+
+// resolveBundleLinks rewrites the relative links of an article into links
+// from the site root.
+func resolveBundleLinks(content *goquery.Selection, source string) {
+	content.Find("[href], [src], [poster]").Each(func(_ int, element *goquery.Selection) {
+		for _, name := range []string{"href", "src", "poster"} {
+			if link, ok := element.Attr(name); ok {
+				if resolved, ok := bundleUrl(link, source); ok {
+					element.SetAttr(name, resolved)
+				}
+			}
+		}
+	})
+
+	content.Find("[srcset]").Each(func(_ int, element *goquery.Selection) {
+		srcset, _ := element.Attr("srcset")
+		candidates := strings.Split(srcset, ",")
+		for i, candidate := range candidates {
+			fields := strings.Fields(candidate)
+			if len(fields) == 0 {
+				continue
+			}
+			if resolved, ok := bundleUrl(fields[0], source); ok {
+				fields[0] = resolved
+			}
+			candidates[i] = strings.Join(fields, " ")
+		}
+		element.SetAttr("srcset", strings.Join(candidates, ", "))
+	})
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// cacheConfig places what the build can fetch or compute again: downloaded
+// feeds and counts, converted sources, resized images and content checkouts.
+// With max_size_mb set, the least recently used derived files are evicted
+// once the cache grows past it.
+type cacheConfig struct {
+	Dir       string `json:"dir"`
+	MaxSizeMb int    `json:"max_size_mb"`
+}
+
+func cacheDirectory() string {
+	if config.Cache.Dir != "" {
+		return config.Cache.Dir
+	}
+
+	return ".cache"
+}
+
+func cachePath(name string) string {
+	return filepath.Join(cacheDirectory(), name)
+}
+
+// readCacheFile fills target from a JSON cache file. A missing or corrupt
+// cache is not an error; the build simply starts from an empty one.
+func readCacheFile(path string, target any) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, target)
+	}
+	if err != nil {
+		addDiagnostic("cache", path, 0, "Ignoring invalid cache: %s", err)
+	}
+}
+
+func writeCacheFile(path string, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := createDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return writeOutputFile(path, data)
+}
+
+// cachedBlob returns what compute makes for key, from the cache when an
+// earlier build already made it. A hit touches the file, so eviction can
+// tell what has not been used for the longest.
+func cachedBlob(kind string, key string, compute func() ([]byte, error)) ([]byte, error) {
+	sum := sha256.Sum256([]byte(key))
+	path := filepath.Join(cacheDirectory(), "blobs", kind, hex.EncodeToString(sum[:]))
+
+	if data, err := os.ReadFile(path); err == nil {
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		return data, nil
+	}
+
+	data, err := compute()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := createDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	if err := writeOutputFile(path, data); err != nil {
+		return nil, fmt.Errorf("Failed to cache %s: %w", kind, err)
+	}
+	return data, nil
+}
+
+// evictCache removes the least recently used blobs until the cache fits in
+// max_size_mb again.
+func evictCache() error {
+	if config.Cache.MaxSizeMb <= 0 {
+		return nil
+	}
+
+	type blob struct {
+		path string
+		size int64
+		used time.Time
+	}
+
+	var total int64
+	var blobs []blob
+	blobDir := filepath.Join(cacheDirectory(), "blobs")
+	err := filepath.WalkDir(cacheDirectory(), func(path string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		if rel, err := filepath.Rel(blobDir, path); err == nil && filepath.IsLocal(rel) {
+			blobs = append(blobs, blob{path, info.Size(), info.ModTime()})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(blobs, func(i, j int) bool {
+		return blobs[i].used.Before(blobs[j].used)
+	})
+
+	limit := int64(config.Cache.MaxSizeMb) << 20
+	evicted := 0
+	for _, b := range blobs {
+		if total <= limit {
+			break
+		}
+		if err := os.Remove(b.path); err != nil {
+			return err
+		}
+		total -= b.size
+		evicted++
+	}
+
+	if evicted > 0 {
+		fmt.Printf("Evicted %d cached files, the cache now holds %.1f MB\n", evicted, float64(total)/(1<<20))
+	}
+	return nil
+}
+
+// runCache manages the cache directory: clean removes all of it.
+func runCache(args []string) error {
+	flags := flag.NewFlagSet("cache", flag.ContinueOnError)
+	flags.StringVar(&buildEnvironment, "env", buildEnvironment, "config environment to use")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := loadConfig(); err != nil {
+		return err
+	}
+
+	switch flags.Arg(0) {
+	case "clean":
+		if err := deleteDirIfExists(cacheDirectory()); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %s\n", cacheDirectory())
+		return nil
+	default:
+		return fmt.Errorf("Usage: cache clean")
+	}
+}
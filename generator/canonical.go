@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+)
+
+// canonicalSite names the site a syndicated article first appeared on.
+func canonicalSite(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return link
+	}
+
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
+
+// addCanonicalLink points search engines at the original of a page: the
+// canonical_url of an article republished from elsewhere, and the page
+// itself otherwise. A canonical link already in the template is kept.
+func addCanonicalLink(head *pageHead, u string, metadata *articleInfo) {
+	canonical := ""
+	if metadata != nil && metadata.CanonicalUrl != "" {
+		canonical = metadata.CanonicalUrl
+	} else if config.BaseUrl != "" {
+		canonical = absoluteUrl(u, "/")
+	}
+	if canonical == "" {
+		return
+	}
+
+	head.add("canonical", `link[rel="canonical"]`, fmt.Sprintf(`<link rel="canonical" href="%s">`, html.EscapeString(canonical)))
+}
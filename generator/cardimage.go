@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"path/filepath"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// cardImageConfig gives every article an image for social cards: its cover,
+// or else the first image in it, cropped to width by height.
+type cardImageConfig struct {
+	Enabled bool `json:"enabled"`
+	Width   int  `json:"width"`
+	Height  int  `json:"height"`
+}
+
+type cardImage struct {
+	url  string
+	size image.Point
+}
+
+// cropImage cuts the middle of an image to the aspect ratio of width by
+// height and scales it down to width when it is wider.
+func cropImage(src image.Image, width int, height int) image.Image {
+	bounds := src.Bounds()
+	crop := bounds
+	if bounds.Dx()*height > bounds.Dy()*width {
+		w := bounds.Dy() * width / height
+		crop.Min.X += (bounds.Dx() - w) / 2
+		crop.Max.X = crop.Min.X + w
+	} else {
+		h := bounds.Dx() * height / width
+		crop.Min.Y += (bounds.Dy() - h) / 2
+		crop.Max.Y = crop.Min.Y + h
+	}
+
+	if sub, ok := src.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		src = sub.SubImage(crop)
+	}
+	return downscaleImage(src, width)
+}
+
+// cardImageSource is the cover of an article, or the first image in it.
+func cardImageSource(art article) string {
+	if art.metadata.Cover != "" {
+		return art.metadata.Cover
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(art.content))
+	if err != nil {
+		return ""
+	}
+
+	source := ""
+	doc.Find("img[src]").EachWithBreak(func(_ int, img *goquery.Selection) bool {
+		src, _ := img.Attr("src")
+		if !strings.HasPrefix(src, "data:") {
+			source = src
+		}
+		return source == ""
+	})
+	return source
+}
+
+// articleCardImage writes the card image of an article next to its source
+// image and returns it. Remote images and the formats the standard library
+// cannot decode are used as they are.
+func articleCardImage(art article) (cardImage, bool, error) {
+	source := cardImageSource(art)
+	if source == "" {
+		return cardImage{}, false, nil
+	}
+
+	path, ok := contentPathFromUrl(source, art.source)
+	if !ok {
+		return cardImage{url: source}, true, nil
+	}
+
+	data, err := readImageFile(path)
+	if err != nil {
+		return cardImage{}, false, fmt.Errorf("Cannot read the card image of %s: %w", art.source, err)
+	}
+	img, format, err := decodeImage(path, data)
+	if err != nil {
+		return cardImage{url: urlFromContentPath(path)}, true, nil
+	}
+
+	width, height := config.CardImage.Width, config.CardImage.Height
+	if width <= 0 || height <= 0 {
+		width, height = 1200, 630
+	}
+
+	card := fmt.Sprintf("%s-card%s", strings.TrimSuffix(path, filepath.Ext(path)), filepath.Ext(path))
+	u := urlFromContentPath(card)
+	encoded, ok := thumbnails[card]
+	if !ok {
+		encoded, err = cachedBlob("cards", fmt.Sprintf("%s %dx%d", imageCacheKey(data, width), width, height), func() ([]byte, error) {
+			return encodeImage(cropImage(img, width, height), format, 85)
+		})
+		if err != nil {
+			return cardImage{}, false, fmt.Errorf("Failed to encode %s: %w", path, err)
+		}
+
+		target := targetPathFromUrl(u)
+		if err := recordOutput(target, path); err != nil {
+			return cardImage{}, false, err
+		}
+		if err := createDir(filepath.Dir(target)); err != nil {
+			return cardImage{}, false, err
+		}
+		if err := writeOutputFile(target, encoded); err != nil {
+			return cardImage{}, false, err
+		}
+		thumbnails[card] = encoded
+	}
+
+	decoded, _, err := image.DecodeConfig(bytes.NewReader(encoded))
+	if err != nil {
+		return cardImage{url: u}, true, nil
+	}
+	return cardImage{url: u, size: image.Pt(decoded.Width, decoded.Height)}, true, nil
+}
+
+func addCardImageTags(head *pageHead, art article) error {
+	card, ok, err := articleCardImage(art)
+	if err != nil || !ok {
+		return err
+	}
+
+	link := html.EscapeString(absoluteUrl(card.url, art.url))
+	head.add("og:image", `meta[property="og:image"]`, fmt.Sprintf(`<meta property="og:image" content="%s">`, link))
+	if card.size != (image.Point{}) {
+		head.add("og:image:width", `meta[property="og:image:width"]`,
+			fmt.Sprintf(`<meta property="og:image:width" content="%d">`, card.size.X))
+		head.add("og:image:height", `meta[property="og:image:height"]`,
+			fmt.Sprintf(`<meta property="og:image:height" content="%d">`, card.size.Y))
+	}
+	head.add("twitter:card", `meta[name="twitter:card"]`, `<meta name="twitter:card" content="summary_large_image">`)
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+)
+
+type changelogEntry struct {
+	Date string `json:"date"`
+	Note string `json:"note"`
+}
+
+// lastUpdated is the date of the newest changelog entry, or the release
+// date when the article was never revised.
+func lastUpdated(released time.Time, changelog []changelogEntry, path string) (time.Time, error) {
+	updated := released
+	for _, entry := range changelog {
+		date, err := time.Parse("2006-01-02", entry.Date)
+		if err != nil {
+			return updated, fmt.Errorf("Invalid changelog date found in %s: %s", path, entry.Date)
+		}
+		if date.After(updated) {
+			updated = date
+		}
+	}
+
+	return updated, nil
+}
+
+func revisionHistorySection(changelog []changelogEntry) string {
+	entries := append([]changelogEntry(nil), changelog...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Date > entries[j].Date
+	})
+
+	var list strings.Builder
+	for _, entry := range entries {
+		fmt.Fprintf(&list, `<li><time datetime="%s">%s</time>: %s</li>`,
+			html.EscapeString(entry.Date), html.EscapeString(entry.Date), html.EscapeString(entry.Note))
+	}
+
+	return fmt.Sprintf(`<section class="revision-history"><h2>Revision history</h2><ul>%s</ul></section>`, list.String())
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type checksumsConfig struct {
+	Enabled bool   `json:"enabled"`
+	KeyFile string `json:"key_file"`
+}
+
+const checksumsUrl = "/checksums.txt"
+
+// contentHash identifies the text of an article, independent of the
+// template around it.
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// readSigningKey reads an Ed25519 private key in PKCS #8 PEM form, as made
+// by openssl genpkey -algorithm ed25519.
+func readSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("Signing key %s is not PEM encoded", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid signing key %s: %w", path, err)
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("Signing key %s is not an Ed25519 key", path)
+	}
+
+	return private, nil
+}
+
+// writeChecksums lists the SHA-256 of every file of the site in the format
+// of sha256sum, so a mirror can be checked with sha256sum -c. With a key,
+// the list is signed into checksums.txt.sig.
+func writeChecksums() error {
+	target := targetPathFromUrl(checksumsUrl)
+	signature := target + ".sig"
+
+	var lines []string
+	err := filepath.WalkDir(targetDirectory(), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || path == target || path == signature {
+			return err
+		}
+
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(targetDirectory(), path)
+		if err != nil {
+			return err
+		}
+
+		lines = append(lines, strings.TrimPrefix(hash, "sha256:")+"  "+filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i][66:] < lines[j][66:]
+	})
+	checksums := []byte(strings.Join(lines, "\n") + "\n")
+
+	if err := claimOutput(target, "the checksums"); err != nil {
+		return err
+	}
+	if err := writeOutputFile(target, checksums); err != nil {
+		return err
+	}
+
+	if config.Checksums.KeyFile == "" {
+		return nil
+	}
+
+	key, err := readSigningKey(config.Checksums.KeyFile)
+	if err != nil {
+		return err
+	}
+	if err := claimOutput(signature, "the checksums signature"); err != nil {
+		return err
+	}
+	signed := base64.StdEncoding.EncodeToString(ed25519.Sign(key, checksums))
+	return writeOutputFile(signature, []byte(signed+"\n"))
+}
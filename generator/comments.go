@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"path"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type commentsConfig struct {
+	Provider   string `json:"provider"`
+	Repo       string `json:"repo"`
+	RepoId     string `json:"repo_id"`
+	Category   string `json:"category"`
+	CategoryId string `json:"category_id"`
+	Label      string `json:"label"`
+	Theme      string `json:"theme"`
+}
+
+// commentsEmbed returns the script that loads the comment thread of an
+// article. Threads are mapped to the article by its slug, so they survive
+// changes to the title or the url.
+func commentsEmbed(slug string) (string, error) {
+	comments := config.Comments
+	theme := comments.Theme
+	switch comments.Provider {
+	case "":
+		return "", nil
+	case "giscus":
+		if theme == "" {
+			theme = "preferred_color_scheme"
+		}
+		return fmt.Sprintf(`<script src="https://giscus.app/client.js" data-repo="%s" data-repo-id="%s" `+
+			`data-category="%s" data-category-id="%s" data-mapping="specific" data-term="%s" data-strict="1" `+
+			`data-reactions-enabled="1" data-emit-metadata="0" data-input-position="bottom" data-theme="%s" `+
+			`data-lang="en" data-loading="lazy" crossorigin="anonymous" async></script>`,
+			html.EscapeString(comments.Repo), html.EscapeString(comments.RepoId), html.EscapeString(comments.Category),
+			html.EscapeString(comments.CategoryId), html.EscapeString(slug), html.EscapeString(theme)), nil
+	case "utterances":
+		if theme == "" {
+			theme = "preferred-color-scheme"
+		}
+		label := ""
+		if comments.Label != "" {
+			label = fmt.Sprintf(` label="%s"`, html.EscapeString(comments.Label))
+		}
+		return fmt.Sprintf(`<script src="https://utteranc.es/client.js" repo="%s" issue-term="%s"%s theme="%s" `+
+			`crossorigin="anonymous" async></script>`,
+			html.EscapeString(comments.Repo), html.EscapeString(slug), label, html.EscapeString(theme)), nil
+	default:
+		return "", fmt.Errorf("Unknown comments provider: %s", comments.Provider)
+	}
+}
+
+// injectComments adds the comment thread after an article unless it opted
+// out. Only the page itself gets it; the lite, print and feed variants are
+// made from the article content and stay without.
+func injectComments(doc *goquery.Document, art article) error {
+	if art.metadata.NoComments {
+		return nil
+	}
+
+	embed, err := commentsEmbed(path.Base(path.Dir(art.url)))
+	if err != nil || embed == "" {
+		return err
+	}
+
+	doc.Find("#content > article").AfterHtml(`<section class="comments">` + embed + `</section>`)
+	return nil
+}
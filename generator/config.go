@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type siteConfig struct {
+	Pdf                   bool               `json:"pdf"`
+	Lite                  bool               `json:"lite"`
+	Print                 printConfig        `json:"print"`
+	Discussions           discussionsConfig  `json:"discussions"`
+	Analytics             analyticsConfig    `json:"analytics"`
+	Csp                   cspConfig          `json:"csp"`
+	Sri                   sriConfig          `json:"sri"`
+	CriticalCss           string             `json:"critical_css"`
+	CssReport             cssReportConfig    `json:"css_report"`
+	Validate              validateConfig     `json:"validate"`
+	TemplateHooks         []string           `json:"template_hooks"`
+	Menu                  []menuItem         `json:"menu"`
+	Site                  map[string]string  `json:"site"`
+	BaseUrl               string             `json:"base_url"`
+	Drafts                bool               `json:"drafts"`
+	HoldScheduled         bool               `json:"hold_scheduled"`
+	Noindex               bool               `json:"noindex"`
+	Fragments             string             `json:"fragments"`
+	Raw                   []string           `json:"raw"`
+	ExcludePrefixes       []string           `json:"exclude_prefixes"`
+	Tags                  tagsConfig         `json:"tags"`
+	Orphans               orphansConfig      `json:"orphans"`
+	CaseInsensitiveOutput bool               `json:"case_insensitive_output"`
+	SanitizePaths         sanitizeConfig     `json:"sanitize_paths"`
+	Feed                  feedConfig         `json:"feed"`
+	Sitemap               bool               `json:"sitemap"`
+	Sections              []sectionConfig    `json:"sections"`
+	HomeSections          []string           `json:"home_sections"`
+	Microformats          microformatsConfig `json:"microformats"`
+	Budget                budgetConfig       `json:"budget"`
+	StripImageMetadata    bool               `json:"strip_image_metadata"`
+	Video                 videoConfig        `json:"video"`
+	Podcast               podcastConfig      `json:"podcast"`
+	Json                  bool               `json:"json"`
+	Backlinks             bool               `json:"backlinks"`
+	WikiLinks             bool               `json:"wiki_links"`
+	Converters            convertersConfig   `json:"converters"`
+	Markdown              markdownConfig     `json:"markdown"`
+	Comments              commentsConfig     `json:"comments"`
+	Blogroll              blogrollConfig     `json:"blogroll"`
+	Archive               archiveConfig      `json:"archive"`
+	Ping                  pingConfig         `json:"ping"`
+	Crosspost             crosspostConfig    `json:"crosspost"`
+	ShortUrls             shortUrlsConfig    `json:"short_urls"`
+	Checksums             checksumsConfig    `json:"checksums"`
+	Stats                 statsConfig        `json:"stats"`
+	Duplicates            duplicatesConfig   `json:"duplicates"`
+	ContentRepo           contentRepoConfig  `json:"content_repo"`
+	Cache                 cacheConfig        `json:"cache"`
+	WordCount             wordCountConfig    `json:"word_count"`
+	TagCloud              tagCloudConfig     `json:"tag_cloud"`
+	Random                randomConfig       `json:"random"`
+	OnThisDay             onThisDayConfig    `json:"on_this_day"`
+	ListGroups            string             `json:"list_groups"`
+	HomeSort              string             `json:"home_sort"`
+	HomeLimit             int                `json:"home_limit"`
+	HomeOffset            int                `json:"home_offset"`
+	ArchiveUrl            string             `json:"archive_url"`
+	SummaryPreviews       bool               `json:"summary_previews"`
+	CardImage             cardImageConfig    `json:"card_image"`
+	Lint                  lintConfig         `json:"lint"`
+}
+
+var config siteConfig
+
+// buildEnvironment selects one of the overlays under "environments" in the
+// config file. It is set by the --env flag and defaults to BLOG_ENV.
+var buildEnvironment = os.Getenv("BLOG_ENV")
+
+func configPath() string {
+	return os.Getenv("CONFIG_PATH")
+}
+
+// configRelativePath resolves a path given in the config file against the
+// directory of the file, so the build finds it wherever it runs from.
+func configRelativePath(path string) string {
+	if path == "" || filepath.IsAbs(path) || configPath() == "" {
+		return path
+	}
+
+	return filepath.Join(filepath.Dir(configPath()), path)
+}
+
+// mergeConfig applies an environment overlay on top of the base config.
+// Objects are merged key by key, everything else is replaced.
+func mergeConfig(base map[string]any, overlay map[string]any) {
+	for key, value := range overlay {
+		if overlayObject, ok := value.(map[string]any); ok {
+			if baseObject, ok := base[key].(map[string]any); ok {
+				mergeConfig(baseObject, overlayObject)
+				continue
+			}
+		}
+		base[key] = value
+	}
+}
+
+func loadConfig() error {
+	path := configPath()
+	if path == "" {
+		if buildEnvironment != "" {
+			return fmt.Errorf("Environment %s needs a config file", buildEnvironment)
+		}
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Cannot read config file: %s", path)
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("Cannot decode config: %w", jsonError(path, data, err))
+	}
+
+	environments, _ := raw["environments"].(map[string]any)
+	delete(raw, "environments")
+	if buildEnvironment != "" {
+		overlay, ok := environments[buildEnvironment].(map[string]any)
+		if !ok {
+			return fmt.Errorf("Unknown environment: %s", buildEnvironment)
+		}
+		mergeConfig(raw, overlay)
+	}
+
+	merged, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return fmt.Errorf("Cannot decode config: %s", err)
+	}
+
+	return registerConverterCommands()
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+func isArchivePath(value string) bool {
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(value, ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func readSourceArchive(location string) ([]byte, error) {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		return download(location)
+	}
+
+	return os.ReadFile(location)
+}
+
+// contentEntryPath is where an entry of an archive or a bucket goes in
+// dir. Entries that would land outside of it are refused.
+func contentEntryPath(dir string, name string) (string, error) {
+	name = strings.TrimPrefix(filepath.ToSlash(name), "./")
+	if slices.Contains(strings.Split(name, "/"), "..") || path.IsAbs(name) {
+		return "", fmt.Errorf("Refusing %s, it points outside the content", name)
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(path.Clean(name))), nil
+}
+
+func writeContentEntry(dir string, name string, data []byte) error {
+	target, err := contentEntryPath(dir, name)
+	if err != nil {
+		return err
+	}
+	if err := createDir(filepath.Dir(target)); err != nil {
+		return err
+	}
+
+	return os.WriteFile(target, data, 0644)
+}
+
+// extractTar writes the files of a tarball, gzipped or not, under sub in
+// it to dir.
+func extractTar(data []byte, sub string, dir string) error {
+	var reader io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// The name is checked before it is cut to sub, so a link out of
+		// the archive is refused wherever it is.
+		if _, err := contentEntryPath(dir, header.Name); err != nil {
+			return err
+		}
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if sub != "" {
+			rest, ok := strings.CutPrefix(name, sub+"/")
+			if !ok {
+				continue
+			}
+			name = rest
+		}
+
+		data, err := io.ReadAll(archive)
+		if err != nil {
+			return err
+		}
+		if err := writeContentEntry(dir, name, data); err != nil {
+			return err
+		}
+	}
+}
+
+// extractS3 writes every object under a prefix of a bucket to dir.
+func extractS3(bucketName string, prefix string, dir string) error {
+	bucket := newS3Bucket(bucketName)
+	keys, err := bucket.list(prefix)
+	if err != nil {
+		return err
+	}
+	if err := deleteDirIfExists(dir); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if strings.HasSuffix(key, "/") {
+			continue
+		}
+
+		data, err := bucket.get(key, nil)
+		if err != nil {
+			return err
+		}
+		if err := writeContentEntry(dir, strings.TrimPrefix(strings.TrimPrefix(key, prefix), "/"), data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extractContentSource writes the content CONTENT_PATH names to dir when it
+// is not a local directory: s3://bucket/prefix, or a zip or tarball, local or
+// at a URL, with an optional #dir naming the content directory inside the
+// archive. Converters, ffmpeg and git work on real files, so the build reads
+// the copy rather than the source itself.
+func extractContentSource(value string, dir string) (bool, error) {
+	if rest, ok := strings.CutPrefix(value, "s3://"); ok {
+		bucket, prefix, _ := strings.Cut(rest, "/")
+		return true, extractS3(bucket, prefix, dir)
+	}
+
+	location, sub, _ := strings.Cut(value, "#")
+	if !isArchivePath(location) {
+		return false, nil
+	}
+
+	data, err := readSourceArchive(location)
+	if err != nil {
+		return true, err
+	}
+	if err := deleteDirIfExists(dir); err != nil {
+		return true, err
+	}
+
+	sub = strings.Trim(sub, "/")
+	if !strings.HasSuffix(location, ".zip") {
+		return true, extractTar(data, sub, dir)
+	}
+
+	var fsys fs.FS
+	fsys, err = zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return true, fmt.Errorf("Cannot read %s: %w", location, err)
+	}
+	if sub != "" {
+		if fsys, err = fs.Sub(fsys, sub); err != nil {
+			return true, err
+		}
+	}
+	return true, copyContentSource(fsys, dir)
+}
+
+// copyContentSource writes the files of fsys to dir.
+func copyContentSource(fsys fs.FS, dir string) error {
+	return fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if entry.IsDir() {
+			return createDir(target)
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		return writeContentEntry(dir, name, data)
+	})
+}
+
+// fetchContent makes the content available as a local directory when it
+// comes from a repository, an archive or a bucket.
+func fetchContent() error {
+	if _, ok := contentRepository(); ok {
+		return checkoutContent()
+	}
+
+	name := os.Getenv("CONTENT_PATH")
+
+	// An offline build uses the copy of the last build for remote content.
+	remote := strings.HasPrefix(name, "s3://") || strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+	if offline && remote && fileExists(checkoutDirectory(name)) {
+		contentCheckout = checkoutDirectory(name)
+		return nil
+	}
+
+	dir := checkoutDirectory(name)
+	ok, err := extractContentSource(name, dir)
+	if !ok {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Cannot fetch content %s: %w", name, err)
+	}
+
+	contentCheckout = dir
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func tarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	archive := tar.NewWriter(&buffer)
+	for name, text := range files {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(text)), Typeflag: tar.TypeReg}
+		if err := archive.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := archive.Write([]byte(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestExtractTar(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		sub     string
+		want    map[string]string
+		refused string
+	}{
+		{
+			name:  "whole archive",
+			files: map[string]string{"./index.md": "home", "articles/a/index.md": "a"},
+			want:  map[string]string{"index.md": "home", "articles/a/index.md": "a"},
+		},
+		{
+			name:  "directory inside",
+			files: map[string]string{"site/content/index.md": "home", "site/README.md": "readme"},
+			sub:   "site/content",
+			want:  map[string]string{"index.md": "home"},
+		},
+		{
+			name:    "parent directory",
+			files:   map[string]string{"../evil.md": "x"},
+			refused: "../evil.md",
+		},
+		{
+			name:    "parent directory inside a path",
+			files:   map[string]string{"content/../../evil.md": "x"},
+			sub:     "content",
+			refused: "content/../../evil.md",
+		},
+		{
+			name:    "absolute path",
+			files:   map[string]string{"/etc/evil.md": "x"},
+			refused: "/etc/evil.md",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "content")
+			err := extractTar(tarball(t, test.files), test.sub, dir)
+			if test.refused != "" {
+				if err == nil || !strings.Contains(err.Error(), test.refused) {
+					t.Fatalf("extractTar = %v, want %s refused", err, test.refused)
+				}
+				if fileExists(filepath.Join(filepath.Dir(dir), "evil.md")) {
+					t.Fatal("a file was written outside the content")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			count := 0
+			filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+				if err == nil && !entry.IsDir() {
+					count++
+				}
+				return nil
+			})
+			if count != len(test.want) {
+				t.Errorf("extracted %d files, want %d", count, len(test.want))
+			}
+			for name, text := range test.want {
+				data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+				if err != nil || string(data) != text {
+					t.Errorf("%s = %q, %v; want %q", name, data, err, text)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+type convertersConfig struct {
+	Commands  map[string][]string `json:"commands"`
+	CacheFile string              `json:"cache_file"`
+}
+
+var (
+	convertedHtml       map[string]string
+	convertedHtmlLoaded bool
+	usedConvertedHtml   = make(map[string]bool)
+)
+
+func convertersCacheFile() string {
+	if config.Converters.CacheFile != "" {
+		return config.Converters.CacheFile
+	}
+
+	return cachePath("converted-sources.json")
+}
+
+// registerConverterCommands makes every extension with a configured command
+// a source, converted by running that command.
+func registerConverterCommands() error {
+	for ext, command := range config.Converters.Commands {
+		if !strings.HasPrefix(ext, ".") || len(command) == 0 {
+			return fmt.Errorf("Invalid converter for %q: an extension needs a command", ext)
+		}
+		sourceConverters[ext] = convertWithCommand
+	}
+
+	return nil
+}
+
+// saveConvertedHtml keeps the conversions used by this build, so sources
+// that were removed or changed do not pile up in the cache.
+func saveConvertedHtml() error {
+	if !convertedHtmlLoaded {
+		return nil
+	}
+
+	for key := range convertedHtml {
+		if !usedConvertedHtml[key] {
+			delete(convertedHtml, key)
+		}
+	}
+	return writeCacheFile(convertersCacheFile(), convertedHtml)
+}
+
+// convertWithCommand runs the command configured for the extension of a
+// source. The source is passed as {input} when the command names it, and
+// on standard input otherwise; the HTML is read from standard output.
+// Results are cached by the hash of the command and the source, since
+// external converters tend to be slow.
+func convertWithCommand(path string, data []byte) (convertedSource, error) {
+	command := config.Converters.Commands[filepath.Ext(path)]
+
+	if !convertedHtmlLoaded {
+		convertedHtmlLoaded = true
+		convertedHtml = make(map[string]string)
+		readCacheFile(convertersCacheFile(), &convertedHtml)
+	}
+
+	hash := sha256.New()
+	for _, arg := range command {
+		hash.Write([]byte(arg + "\x00"))
+	}
+	hash.Write(data)
+	key := hex.EncodeToString(hash.Sum(nil))
+
+	usedConvertedHtml[key] = true
+	if cached, ok := convertedHtml[key]; ok {
+		return convertedSource{html: cached}, nil
+	}
+
+	args := make([]string, len(command)-1)
+	stdin := true
+	for i, arg := range command[1:] {
+		if strings.Contains(arg, "{input}") {
+			stdin = false
+		}
+		args[i] = strings.ReplaceAll(arg, "{input}", path)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(command[0], args...)
+	if stdin {
+		cmd.Stdin = bytes.NewReader(data)
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return convertedSource{}, fmt.Errorf("%s failed: %w\n%s", command[0], err, stderr.Bytes())
+	}
+
+	convertedHtml[key] = stdout.String()
+	return convertedSource{html: stdout.String()}, nil
+}
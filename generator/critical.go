@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var criticalCss *string
+
+// loadCriticalCss reads the critical_css file, which is relative to the
+// config file.
+func loadCriticalCss() (string, error) {
+	if criticalCss == nil {
+		data, err := os.ReadFile(configRelativePath(config.CriticalCss))
+		if err != nil {
+			return "", fmt.Errorf("Cannot read critical CSS: %w", err)
+		}
+		css := string(data)
+		criticalCss = &css
+	}
+
+	return *criticalCss, nil
+}
+
+// inlineCriticalCss puts the critical rules into the head and turns the local
+// stylesheets into preloads, so they no longer block the first paint.
+func inlineCriticalCss(doc *goquery.Document, head *pageHead) error {
+	css, err := loadCriticalCss()
+	if err != nil {
+		return err
+	}
+
+	doc.Find(`link[rel="stylesheet"][href]`).Each(func(_ int, link *goquery.Selection) {
+		href, _ := link.Attr("href")
+		if _, local := contentPathFromUrl(href, contentDirectory()); !local {
+			return
+		}
+
+		link.SetAttr("rel", "preload")
+		link.SetAttr("as", "style")
+		link.SetAttr("onload", "this.onload=null;this.rel='stylesheet'")
+		link.AfterHtml(fmt.Sprintf(`<noscript><link rel="stylesheet" href="%s"></noscript>`, html.EscapeString(href)))
+	})
+
+	head.add("critical-css", "", "<style>"+css+"</style>")
+	return nil
+}
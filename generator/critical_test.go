@@ -0,0 +1,68 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestConfigRelativePath(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name   string
+		config string
+		path   string
+		want   string
+	}{
+		{"next to the config", filepath.Join(dir, "site.json"), "critical.css", filepath.Join(dir, "critical.css")},
+		{"below the config", filepath.Join(dir, "site.json"), "css/critical.css", filepath.Join(dir, "css", "critical.css")},
+		{"absolute", filepath.Join(dir, "site.json"), filepath.Join(dir, "other.css"), filepath.Join(dir, "other.css")},
+		{"no config file", "", "critical.css", "critical.css"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("CONFIG_PATH", test.config)
+			if got := configRelativePath(test.path); got != test.want {
+				t.Errorf("configRelativePath(%q) = %q, want %q", test.path, got, test.want)
+			}
+		})
+	}
+}
+
+func TestInlineCriticalCss(t *testing.T) {
+	t.Setenv("CONTENT_PATH", t.TempDir())
+	saved := criticalCss
+	t.Cleanup(func() { criticalCss = saved })
+	css := "body{margin:0}"
+	criticalCss = &css
+
+	tests := []struct {
+		name string
+		href string
+		want string
+	}{
+		{"plain", "/style.css", `<noscript><link rel="stylesheet" href="/style.css"></noscript>`},
+		{"query", "/style.css?v=1&amp;t=2", `<noscript><link rel="stylesheet" href="/style.css?v=1&amp;t=2"></noscript>`},
+		{"quote", "/style.css?x=&#34;&gt;&lt;script&gt;", `<noscript><link rel="stylesheet" href="/style.css?x=&#34;&gt;&lt;script&gt;"></noscript>`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page := `<html><head><link rel="stylesheet" href="` + test.href + `"></head><body></body></html>`
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := inlineCriticalCss(doc, &pageHead{}); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := doc.Html(); !strings.Contains(got, test.want) {
+				t.Errorf("page = %s, want %s in it", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type crosspostConfig struct {
+	Enabled   bool   `json:"enabled"`
+	StateFile string `json:"state_file"`
+	Template  string `json:"template"`
+	Mastodon  string `json:"mastodon"`
+	Bluesky   string `json:"bluesky"`
+}
+
+// crosspostState remembers which articles every service was told about.
+type crosspostState struct {
+	PublishedAt time.Time           `json:"published_at"`
+	Announced   map[string][]string `json:"announced"`
+}
+
+const blueskyServer = "https://bsky.social"
+
+func crosspostStateFile() string {
+	if config.Crosspost.StateFile != "" {
+		return config.Crosspost.StateFile
+	}
+
+	return "crosspost-state.json"
+}
+
+// crosspostText fills the template with {title}, {url} and {tags}, the tags
+// written as hashtags.
+func crosspostText(entry siteEntry) string {
+	template := config.Crosspost.Template
+	if template == "" {
+		template = "{title} {url}"
+	}
+
+	var hashtags []string
+	for _, tag := range entry.tags {
+		hashtag := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, tag)
+		if hashtag != "" {
+			hashtags = append(hashtags, "#"+hashtag)
+		}
+	}
+
+	return strings.TrimSpace(strings.NewReplacer("{title}", entry.title, "{url}", absoluteUrl(entry.url, "/"),
+		"{tags}", strings.Join(hashtags, " ")).Replace(template))
+}
+
+func postJson(link string, token string, body any, result any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", link, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "site-generator")
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s returned %s", link, resp.Status)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// postToMastodon posts a status with the token in MASTODON_TOKEN. The url of
+// the article is the idempotency key, so a retried publish does not post
+// twice.
+func postToMastodon(text string, link string) error {
+	token := os.Getenv("MASTODON_TOKEN")
+	if token == "" {
+		return fmt.Errorf("MASTODON_TOKEN is not set")
+	}
+
+	form := url.Values{"status": {text}}
+	req, err := http.NewRequest("POST", strings.TrimSuffix(config.Crosspost.Mastodon, "/")+"/api/v1/statuses",
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "site-generator")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Idempotency-Key", link)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Mastodon returned %s", resp.Status)
+	}
+	return nil
+}
+
+// postToBluesky signs in as the configured handle with the app password in
+// BLUESKY_PASSWORD and posts the text, with the article url made a link.
+func postToBluesky(text string, link string) error {
+	password := os.Getenv("BLUESKY_PASSWORD")
+	if password == "" {
+		return fmt.Errorf("BLUESKY_PASSWORD is not set")
+	}
+
+	var session struct {
+		AccessJwt string `json:"accessJwt"`
+		Did       string `json:"did"`
+	}
+	err := postJson(blueskyServer+"/xrpc/com.atproto.server.createSession", "",
+		map[string]string{"identifier": config.Crosspost.Bluesky, "password": password}, &session)
+	if err != nil {
+		return err
+	}
+
+	record := map[string]any{
+		"$type":     "app.bsky.feed.post",
+		"text":      text,
+		"createdAt": time.Now().UTC().Format(time.RFC3339),
+	}
+	if start := strings.Index(text, link); start != -1 {
+		record["facets"] = []any{map[string]any{
+			"index": map[string]int{"byteStart": start, "byteEnd": start + len(link)},
+			"features": []any{map[string]string{
+				"$type": "app.bsky.richtext.facet#link",
+				"uri":   link,
+			}},
+		}}
+	}
+
+	return postJson(blueskyServer+"/xrpc/com.atproto.repo.createRecord", session.AccessJwt,
+		map[string]any{"repo": session.Did, "collection": "app.bsky.feed.post", "record": record}, nil)
+}
+
+// crosspostArticles announces the deployed articles no service was told
+// about yet. The first publish only records the articles that are already
+// out, so the archive is not announced all at once.
+func crosspostArticles(deployed map[string]bool) error {
+	if config.BaseUrl == "" {
+		return fmt.Errorf("Cross-posting needs base_url to be set")
+	}
+
+	var state crosspostState
+	_, err := os.Stat(crosspostStateFile())
+	first := os.IsNotExist(err)
+	readCacheFile(crosspostStateFile(), &state)
+	if state.Announced == nil {
+		state.Announced = make(map[string][]string)
+	}
+
+	if err := loadSiteIndex(); err != nil {
+		return err
+	}
+	entries := append([]siteEntry(nil), siteArticles...)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].date.Before(entries[j].date)
+	})
+
+	services := map[string]func(string, string) error{}
+	if config.Crosspost.Mastodon != "" {
+		services["mastodon"] = postToMastodon
+	}
+	if config.Crosspost.Bluesky != "" {
+		services["bluesky"] = postToBluesky
+	}
+
+	failed := 0
+	for _, entry := range entries {
+		if !deployed[entry.url] {
+			continue
+		}
+
+		for name, post := range services {
+			if slices.Contains(state.Announced[entry.url], name) {
+				continue
+			}
+			if !first {
+				if err := post(crosspostText(entry), absoluteUrl(entry.url, "/")); err != nil {
+					fmt.Fprintf(os.Stderr, "Cannot post %s to %s: %s\n", entry.url, name, err)
+					failed++
+					continue
+				}
+				fmt.Printf("Posted %s to %s\n", entry.url, name)
+			}
+			state.Announced[entry.url] = append(state.Announced[entry.url], name)
+		}
+	}
+
+	state.PublishedAt = time.Now()
+	if err := writeCacheFile(crosspostStateFile(), state); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d cross-posts failed and will be retried on the next publish", failed)
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type cspConfig struct {
+	Enabled    bool                `json:"enabled"`
+	Mode       string              `json:"mode"`
+	Directives map[string][]string `json:"directives"`
+}
+
+// pageHeaders collects the policy of every page when it is written to a
+// headers file instead of a meta tag.
+var pageHeaders = make(map[string]string)
+
+type cspPolicy map[string][]string
+
+func (p cspPolicy) add(directive string, source string) {
+	if !slices.Contains(p[directive], source) {
+		p[directive] = append(p[directive], source)
+	}
+}
+
+func (p cspPolicy) addUrl(directive string, link string) {
+	link = strings.TrimSpace(link)
+	if link == "" {
+		return
+	}
+	if strings.HasPrefix(link, "data:") {
+		p.add(directive, "data:")
+		return
+	}
+	if strings.HasPrefix(link, "//") {
+		link = "https:" + link
+	}
+
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		p.add(directive, "'self'")
+		return
+	}
+	p.add(directive, u.Scheme+"://"+u.Host)
+}
+
+func (p cspPolicy) addHash(directive string, content string) {
+	sum := sha256.Sum256([]byte(content))
+	p.add(directive, "'sha256-"+base64.StdEncoding.EncodeToString(sum[:])+"'")
+}
+
+func (p cspPolicy) String() string {
+	var directives []string
+	for directive := range p {
+		directives = append(directives, directive)
+	}
+	sort.Strings(directives)
+
+	var parts []string
+	for _, directive := range directives {
+		parts = append(parts, directive+" "+strings.Join(p[directive], " "))
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// pagePolicy derives a policy from the resources the page actually loads.
+func pagePolicy(doc *goquery.Document) cspPolicy {
+	policy := cspPolicy{
+		"default-src": {"'self'"},
+		"base-uri":    {"'self'"},
+		"object-src":  {"'none'"},
+	}
+
+	doc.Find("script").Each(func(_ int, script *goquery.Selection) {
+		if src, ok := script.Attr("src"); ok {
+			policy.addUrl("script-src", src)
+			policy.addUrl("connect-src", src)
+		} else if strings.TrimSpace(script.Text()) != "" {
+			policy.addHash("script-src", script.Text())
+		}
+	})
+
+	// GoatCounter sends each count to the endpoint named on its script, as a
+	// beacon or, where beacons are unavailable, as an image.
+	doc.Find("script[data-goatcounter]").Each(func(_ int, script *goquery.Selection) {
+		endpoint, _ := script.Attr("data-goatcounter")
+		policy.addUrl("connect-src", endpoint)
+		policy.addUrl("img-src", endpoint)
+	})
+
+	doc.Find("*").Each(func(_ int, element *goquery.Selection) {
+		for _, attr := range element.Nodes[0].Attr {
+			if strings.HasPrefix(attr.Key, "on") {
+				policy.add("script-src", "'unsafe-hashes'")
+				policy.addHash("script-src", attr.Val)
+			}
+		}
+	})
+
+	doc.Find(`link[rel~="stylesheet"], link[rel="preload"][as="style"]`).Each(func(_ int, link *goquery.Selection) {
+		href, _ := link.Attr("href")
+		policy.addUrl("style-src", href)
+	})
+	doc.Find("style").Each(func(_ int, style *goquery.Selection) {
+		policy.addHash("style-src", style.Text())
+	})
+	if doc.Find("[style]").Length() > 0 {
+		policy.add("style-src", "'unsafe-inline'")
+	}
+
+	doc.Find(`link[rel~="preconnect"]`).Each(func(_ int, link *goquery.Selection) {
+		href, _ := link.Attr("href")
+		policy.addUrl("font-src", href)
+	})
+	policy.add("font-src", "'self'")
+
+	doc.Find(`img[src], link[rel~="icon"], video[poster]`).Each(func(_ int, element *goquery.Selection) {
+		for _, attr := range []string{"src", "href", "poster"} {
+			if link, ok := element.Attr(attr); ok {
+				policy.addUrl("img-src", link)
+			}
+		}
+	})
+	doc.Find("img[srcset], source[srcset]").Each(func(_ int, element *goquery.Selection) {
+		srcset, _ := element.Attr("srcset")
+		for _, candidate := range strings.Split(srcset, ",") {
+			if fields := strings.Fields(candidate); len(fields) > 0 {
+				policy.addUrl("img-src", fields[0])
+			}
+		}
+	})
+
+	doc.Find("video[src], audio[src], video source[src], audio source[src]").Each(func(_ int, media *goquery.Selection) {
+		src, _ := media.Attr("src")
+		policy.addUrl("media-src", src)
+	})
+	doc.Find("iframe[src]").Each(func(_ int, frame *goquery.Selection) {
+		src, _ := frame.Attr("src")
+		policy.addUrl("frame-src", src)
+	})
+	// The comment widgets load the thread into a frame from the origin of
+	// their script.
+	doc.Find(".comments script[src]").Each(func(_ int, script *goquery.Selection) {
+		src, _ := script.Attr("src")
+		policy.addUrl("frame-src", src)
+	})
+
+	for directive, sources := range config.Csp.Directives {
+		for _, source := range sources {
+			policy.add(directive, source)
+		}
+	}
+
+	return policy
+}
+
+func applyCsp(doc *goquery.Document, head *pageHead, url string) error {
+	policy := pagePolicy(doc).String()
+
+	switch config.Csp.Mode {
+	case "", "meta":
+		head.addFirst("csp", "", fmt.Sprintf(`<meta http-equiv="Content-Security-Policy" content="%s">`, html.EscapeString(policy)))
+	case "headers":
+		pageHeaders[url] = policy
+	default:
+		return fmt.Errorf("Unknown CSP mode: %s", config.Csp.Mode)
+	}
+
+	return nil
+}
+
+// writeHeadersFile writes the collected policies, and the WebSub links of
+// the feeds, in the _headers format understood by Netlify and Cloudflare
+// Pages.
+func writeHeadersFile() error {
+	lines := make(map[string][]string)
+	for u, policy := range pageHeaders {
+		lines[u] = append(lines[u], "Content-Security-Policy: "+policy)
+		if dir, ok := strings.CutSuffix(u, "index.html"); ok {
+			lines[dir] = append(lines[dir], "Content-Security-Policy: "+policy)
+		}
+	}
+	if hub := websubHub(); hub != "" {
+		for _, feed := range writtenFeeds {
+			lines[feed] = append(lines[feed], fmt.Sprintf(`Link: <%s>; rel="hub", <%s>; rel="self"`, hub, absoluteUrl(feed, "/")))
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	var paths []string
+	for path := range lines {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var headers strings.Builder
+	for _, path := range paths {
+		fmt.Fprintf(&headers, "%s\n", path)
+		for _, line := range lines[path] {
+			fmt.Fprintf(&headers, "  %s\n", line)
+		}
+	}
+
+	target := filepath.Join(targetDirectory(), "_headers")
+	if err := claimOutput(target, "the headers file"); err != nil {
+		return err
+	}
+	return writeOutputFile(target, []byte(headers.String()))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const goatcounterScript = `<script data-goatcounter="https://blog.goatcounter.com/count" async src="//gc.zgo.at/count.js"></script>`
+
+func TestPagePolicy(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config = siteConfig{}
+
+	tests := []struct {
+		name      string
+		page      string
+		directive string
+		want      string
+	}{
+		{"giscus thread", `<section class="comments"><script src="https://giscus.app/client.js" async></script></section>`, "frame-src", "https://giscus.app"},
+		{"giscus script", `<section class="comments"><script src="https://giscus.app/client.js" async></script></section>`, "script-src", "https://giscus.app"},
+		{"utterances thread", `<section class="comments"><script src="https://utteranc.es/client.js" async></script></section>`, "frame-src", "https://utteranc.es"},
+		{"goatcounter script", goatcounterScript, "script-src", "https://gc.zgo.at"},
+		{"goatcounter beacon", goatcounterScript, "connect-src", "https://blog.goatcounter.com"},
+		{"goatcounter pixel", goatcounterScript, "img-src", "https://blog.goatcounter.com"},
+		{"embedded frame", `<iframe src="https://www.youtube-nocookie.com/embed/x"></iframe>`, "frame-src", "https://www.youtube-nocookie.com"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader("<html><head></head><body>" + test.page + "</body></html>"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			policy := pagePolicy(doc)
+			if !slices.Contains(policy[test.directive], test.want) {
+				t.Errorf("%s = %v, want %s in it (policy %s)", test.directive, policy[test.directive], test.want, policy)
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+type cssReportConfig struct {
+	Enabled bool `json:"enabled"`
+	Prune   bool `json:"prune"`
+}
+
+// cssRule is either a style rule with its selectors, an at-rule whose block
+// holds more rules (children), or any other at-rule kept verbatim in body.
+type cssRule struct {
+	prelude   string
+	selectors []string
+	body      string
+	children  []cssRule
+	nested    bool
+	statement bool
+}
+
+var cssComments = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// cssBlockEnd returns the index just past the brace closing the block that
+// starts at text[start], skipping over strings.
+func cssBlockEnd(text string, start int) int {
+	depth := 0
+	var quote byte
+	for i := start; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+
+	return len(text)
+}
+
+func splitSelectors(prelude string) []string {
+	var selectors []string
+	depth, start := 0, 0
+	for i, c := range prelude {
+		switch c {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				selectors = append(selectors, strings.TrimSpace(prelude[start:i]))
+				start = i + 1
+			}
+		}
+	}
+
+	return append(selectors, strings.TrimSpace(prelude[start:]))
+}
+
+func parseCss(text string) []cssRule {
+	text = cssComments.ReplaceAllString(text, "")
+
+	var rules []cssRule
+	for i := 0; i < len(text); {
+		rest := strings.TrimLeft(text[i:], " \t\r\n")
+		i = len(text) - len(rest)
+		if rest == "" {
+			break
+		}
+
+		open := strings.IndexByte(rest, '{')
+		semicolon := strings.IndexByte(rest, ';')
+		if strings.HasPrefix(rest, "@") && semicolon != -1 && (open == -1 || semicolon < open) {
+			rules = append(rules, cssRule{prelude: strings.TrimSpace(rest[:semicolon]), statement: true})
+			i += semicolon + 1
+			continue
+		}
+		if open == -1 {
+			break
+		}
+
+		end := cssBlockEnd(rest, open)
+		prelude := strings.TrimSpace(rest[:open])
+		body := strings.TrimSuffix(rest[open+1:end], "}")
+
+		rule := cssRule{prelude: prelude, body: strings.TrimSpace(body)}
+		switch {
+		case strings.HasPrefix(prelude, "@media"), strings.HasPrefix(prelude, "@supports"),
+			strings.HasPrefix(prelude, "@container"), strings.HasPrefix(prelude, "@layer"):
+			rule.nested = true
+			rule.children = parseCss(body)
+		case !strings.HasPrefix(prelude, "@"):
+			rule.selectors = splitSelectors(prelude)
+		}
+		rules = append(rules, rule)
+		i += end
+	}
+
+	return rules
+}
+
+func formatCss(rules []cssRule, indent string) string {
+	var css strings.Builder
+	for _, rule := range rules {
+		switch {
+		case rule.nested:
+			fmt.Fprintf(&css, "%s%s {\n%s%s}\n\n", indent, rule.prelude, formatCss(rule.children, indent+"  "), indent)
+		case rule.selectors != nil:
+			fmt.Fprintf(&css, "%s%s {\n%s  %s\n%s}\n\n", indent, strings.Join(rule.selectors, ",\n"+indent), indent, rule.body, indent)
+		case rule.statement:
+			fmt.Fprintf(&css, "%s%s;\n\n", indent, rule.prelude)
+		default:
+			fmt.Fprintf(&css, "%s%s {\n%s  %s\n%s}\n\n", indent, rule.prelude, indent, rule.body, indent)
+		}
+	}
+
+	return css.String()
+}
+
+var (
+	cssDynamicPseudo = regexp.MustCompile(`:(hover|focus|focus-visible|focus-within|active|visited|target|checked|disabled|enabled|invalid|valid|placeholder-shown)\b`)
+	cssPseudoElement = regexp.MustCompile(`::?(before|after|first-line|first-letter|placeholder|selection|marker|backdrop)\b`)
+)
+
+// expandIs turns the first :is() or :where() group into one selector per
+// alternative, recursively, since cascadia does not understand them.
+func expandIs(selector string) []string {
+	start := -1
+	for _, group := range []string{":is(", ":where("} {
+		if i := strings.Index(selector, group); i != -1 && (start == -1 || i < start) {
+			start = i
+		}
+	}
+	if start == -1 {
+		return []string{selector}
+	}
+
+	open := strings.IndexByte(selector[start:], '(') + start
+	depth, end := 0, len(selector)
+	for i := open; i < len(selector); i++ {
+		if selector[i] == '(' {
+			depth++
+		} else if selector[i] == ')' {
+			depth--
+			if depth == 0 {
+				end = i
+				break
+			}
+		}
+	}
+
+	var expanded []string
+	for _, alternative := range splitSelectors(selector[open+1 : min(end, len(selector))]) {
+		expanded = append(expanded, expandIs(selector[:start]+alternative+selector[min(end+1, len(selector)):])...)
+	}
+
+	return expanded
+}
+
+// staticSelectors rewrites a selector into ones cascadia can evaluate against
+// a static page. States that depend on user interaction are assumed to apply.
+func staticSelectors(selector string) []string {
+	selector = cssPseudoElement.ReplaceAllString(selector, "")
+	selector = cssDynamicPseudo.ReplaceAllString(selector, "")
+	selector = strings.NewReplacer(":any-link", "[href]", ":link", "[href]").Replace(selector)
+
+	var static []string
+	for _, expanded := range expandIs(selector) {
+		if expanded = strings.TrimSpace(expanded); expanded == "" || strings.HasSuffix(expanded, ">") {
+			expanded += "*"
+		}
+		static = append(static, expanded)
+	}
+
+	return static
+}
+
+// selectorUsed reports whether the selector matches an element in any page,
+// and false for ok when it cannot be evaluated.
+func selectorUsed(selector string, pages []*goquery.Document) (used bool, ok bool) {
+	for _, static := range staticSelectors(selector) {
+		matcher, err := cascadia.Compile(static)
+		if err != nil {
+			return false, false
+		}
+
+		for _, page := range pages {
+			if page.FindMatcher(matcher).Length() > 0 {
+				return true, true
+			}
+		}
+	}
+
+	return false, true
+}
+
+type cssUsage struct {
+	unused    []string
+	unchecked []string
+}
+
+// pruneRules drops the selectors matching nothing in any of the pages and
+// the rules left without selectors.
+func pruneRules(rules []cssRule, pages []*goquery.Document, usage *cssUsage) []cssRule {
+	var kept []cssRule
+	for _, rule := range rules {
+		if rule.nested {
+			rule.children = pruneRules(rule.children, pages, usage)
+			if len(rule.children) > 0 {
+				kept = append(kept, rule)
+			}
+			continue
+		}
+		if rule.selectors == nil {
+			kept = append(kept, rule)
+			continue
+		}
+
+		var used []string
+		for _, selector := range rule.selectors {
+			matched, ok := selectorUsed(selector, pages)
+			if !ok {
+				usage.unchecked = append(usage.unchecked, selector)
+				used = append(used, selector)
+				continue
+			}
+
+			if matched {
+				used = append(used, selector)
+			} else {
+				usage.unused = append(usage.unused, selector)
+			}
+		}
+
+		if len(used) > 0 {
+			rule.selectors = used
+			kept = append(kept, rule)
+		}
+	}
+
+	return kept
+}
+
+func generatedPages() (map[string]*goquery.Document, error) {
+	pages := make(map[string]*goquery.Document)
+	err := filepath.WalkDir(targetDirectory(), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || filepath.Ext(path) != ".html" {
+			return err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		doc, err := goquery.NewDocumentFromReader(file)
+		if err != nil {
+			return fmt.Errorf("Failed to parse %s: %w", path, err)
+		}
+		pages[path] = doc
+		return nil
+	})
+
+	return pages, err
+}
+
+// reportUnusedCss checks every local stylesheet linked from the generated
+// pages against those pages and lists the selectors that match nothing.
+func reportUnusedCss() error {
+	pages, err := generatedPages()
+	if err != nil {
+		return err
+	}
+
+	stylesheets := make(map[string][]*goquery.Document)
+	for page, doc := range pages {
+		doc.Find(`link[rel="stylesheet"][href], link[rel="preload"][as="style"][href]`).Each(func(_ int, link *goquery.Selection) {
+			href, _ := link.Attr("href")
+			if strings.Contains(href, "//") {
+				return
+			}
+			if !strings.HasPrefix(href, "/") {
+				href = path.Join(path.Dir(outputUrl(page)), href)
+			}
+			stylesheets[href] = append(stylesheets[href], doc)
+		})
+	}
+
+	var hrefs []string
+	for href := range stylesheets {
+		hrefs = append(hrefs, href)
+	}
+	sort.Strings(hrefs)
+
+	for _, href := range hrefs {
+		path := targetPathFromUrl(href)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("Cannot read stylesheet %s: %w", href, err)
+		}
+
+		var usage cssUsage
+		pruned := pruneRules(parseCss(string(data)), stylesheets[href], &usage)
+
+		fmt.Printf("%s: %d unused selectors\n", href, len(usage.unused))
+		for _, selector := range usage.unused {
+			fmt.Printf("  %s\n", selector)
+		}
+		for _, selector := range usage.unchecked {
+			fmt.Printf("  (not checked) %s\n", selector)
+		}
+
+		if config.CssReport.Prune {
+			if err := writeOutputFile(path, []byte(formatCss(pruned, ""))); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+type buildResult struct {
+	Trigger  string    `json:"trigger"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Commit   string    `json:"commit,omitempty"`
+	Success  bool      `json:"success"`
+	Error    string    `json:"error,omitempty"`
+	Output   string    `json:"output"`
+}
+
+// buildDaemon runs at most one build at a time. Triggers arriving during a
+// build are folded into a single follow-up build.
+type buildDaemon struct {
+	secret string
+	repo   string
+	pull   bool
+
+	mu          sync.Mutex
+	running     bool
+	pending     bool
+	nextTrigger string
+	last        *buildResult
+}
+
+func webhookSecret() string {
+	return os.Getenv("WEBHOOK_SECRET")
+}
+
+// authorized accepts either a GitHub style X-Hub-Signature-256 HMAC of the
+// body or the secret as a bearer token.
+func (d *buildDaemon) authorized(r *http.Request, body []byte) bool {
+	if signature, ok := strings.CutPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256="); ok {
+		mac := hmac.New(sha256.New, []byte(d.secret))
+		mac.Write(body)
+		expected := hex.EncodeToString(mac.Sum(nil))
+		return hmac.Equal([]byte(signature), []byte(expected))
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(d.secret)) == 1
+}
+
+func (d *buildDaemon) runCommand(output *bytes.Buffer, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s failed: %w", name, strings.Join(args, " "), err)
+	}
+
+	return nil
+}
+
+// rebuild pulls the content repository and runs the build in a child process,
+// so a failing build cannot take the daemon down with it.
+func (d *buildDaemon) rebuild(trigger string) buildResult {
+	result := buildResult{Trigger: trigger, Started: time.Now()}
+	var output bytes.Buffer
+
+	err := func() error {
+		if d.pull {
+			if err := d.runCommand(&output, "git", "-C", d.repo, "pull", "--ff-only"); err != nil {
+				return err
+			}
+		}
+
+		executable, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		args := []string{"build"}
+		if buildEnvironment != "" {
+			args = append(args, "--env", buildEnvironment)
+		}
+		return d.runCommand(&output, executable, args...)
+	}()
+
+	// The build fetches a content repository itself, so the commit is read
+	// once it is done.
+	if commit, err := exec.Command("git", "-C", d.repo, "rev-parse", "HEAD").Output(); err == nil {
+		result.Commit = strings.TrimSpace(string(commit))
+	}
+
+	result.Finished = time.Now()
+	result.Success = err == nil
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Output = output.String()
+	return result
+}
+
+func (d *buildDaemon) schedule(trigger string) {
+	d.mu.Lock()
+	if d.running {
+		d.pending = true
+		d.nextTrigger = trigger
+		d.mu.Unlock()
+		return
+	}
+	d.running = true
+	d.mu.Unlock()
+
+	go func() {
+		for {
+			result := d.rebuild(trigger)
+			if result.Success {
+				fmt.Printf("Build triggered by %s succeeded\n", trigger)
+			} else {
+				fmt.Fprintf(os.Stderr, "Build triggered by %s failed: %s\n", trigger, result.Error)
+			}
+
+			d.mu.Lock()
+			d.last = &result
+			if !d.pending {
+				d.running = false
+				d.mu.Unlock()
+				return
+			}
+			d.pending = false
+			trigger = d.nextTrigger
+			d.mu.Unlock()
+		}
+	}()
+}
+
+func (d *buildDaemon) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Cannot read request", http.StatusBadRequest)
+		return
+	}
+	if !d.authorized(r, body) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if event := r.Header.Get("X-GitHub-Event"); event == "ping" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	trigger := "webhook"
+	if event := r.Header.Get("X-GitHub-Event"); event != "" {
+		trigger += " (" + event + ")"
+	}
+	d.schedule(trigger)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (d *buildDaemon) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !d.authorized(r, nil) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	d.mu.Lock()
+	status := struct {
+		Building bool         `json:"building"`
+		Pending  bool         `json:"pending"`
+		Last     *buildResult `json:"last"`
+	}{d.running, d.pending, d.last}
+	data, err := json.MarshalIndent(status, "", "  ")
+	d.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// routes serves the webhook and the status of the builds, both only to
+// callers that know the secret.
+func (d *buildDaemon) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook", d.handleWebhook)
+	mux.HandleFunc("/status", d.handleStatus)
+	return mux
+}
+
+func runDaemon(args []string) error {
+	flags := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	listen := flags.String("listen", "localhost:8080", "address to listen on")
+	repo := flags.String("repo", "", "git repository to pull before building (defaults to the content directory)")
+	noPull := flags.Bool("no-pull", false, "build without pulling first")
+	initial := flags.Bool("build", true, "build once on startup")
+	flags.StringVar(&buildEnvironment, "env", buildEnvironment, "config environment to build for")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	daemon := &buildDaemon{secret: webhookSecret(), repo: *repo, pull: !*noPull}
+	if daemon.secret == "" {
+		return fmt.Errorf("WEBHOOK_SECRET is not set")
+	}
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	if repo, ok := contentRepository(); ok && daemon.repo == "" {
+		daemon.repo = checkoutDirectory(repo.Url)
+		daemon.pull = false
+	}
+	if daemon.repo == "" {
+		daemon.repo = contentDirectory()
+	}
+
+	if *initial {
+		daemon.schedule("startup")
+	}
+
+	// The timeouts keep slow or idle clients from holding connections open,
+	// the daemon being meant to face the internet.
+	server := &http.Server{
+		Addr:              *listen,
+		Handler:           daemon.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	fmt.Printf("Listening on %s\n", *listen)
+	return server.ListenAndServe()
+}
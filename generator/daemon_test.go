@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDaemonAuthorization(t *testing.T) {
+	daemon := &buildDaemon{secret: "secret"}
+	server := httptest.NewServer(daemon.routes())
+	defer server.Close()
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(`{"zen":"hi"}`))
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		headers map[string]string
+		want    int
+	}{
+		{"status without a token", "GET", "/status", "", nil, http.StatusUnauthorized},
+		{"status with a wrong token", "GET", "/status", "", map[string]string{"Authorization": "Bearer guess"}, http.StatusUnauthorized},
+		{"status with the token", "GET", "/status", "", map[string]string{"Authorization": "Bearer secret"}, http.StatusOK},
+		{"webhook without a signature", "POST", "/webhook", `{"zen":"hi"}`, map[string]string{"X-GitHub-Event": "ping"}, http.StatusUnauthorized},
+		{"webhook with a wrong signature", "POST", "/webhook", `{"zen":"hi"}`,
+			map[string]string{"X-GitHub-Event": "ping", "X-Hub-Signature-256": "sha256=00"}, http.StatusUnauthorized},
+		{"webhook with the signature", "POST", "/webhook", `{"zen":"hi"}`,
+			map[string]string{"X-GitHub-Event": "ping", "X-Hub-Signature-256": signature}, http.StatusNoContent},
+		{"webhook with the token", "POST", "/webhook", "", map[string]string{"X-GitHub-Event": "ping", "Authorization": "Bearer secret"}, http.StatusNoContent},
+		{"webhook read", "GET", "/webhook", "", map[string]string{"Authorization": "Bearer secret"}, http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, server.URL+test.path, strings.NewReader(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, value := range test.headers {
+				req.Header.Set(name, value)
+			}
+
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != test.want {
+				t.Errorf("%s %s = %d, want %d", test.method, test.path, resp.StatusCode, test.want)
+			}
+		})
+	}
+}
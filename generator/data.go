@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Excerpts are cut after this many words.
+const excerptWords = 50
+
+const articlesJsonUrl = "/articles.json"
+
+// articleData is the structured form of an article written next to it as
+// index.json and listed in /articles.json.
+type articleData struct {
+	Title     string   `json:"title"`
+	Url       string   `json:"url"`
+	Date      string   `json:"date"`
+	Updated   string   `json:"updated"`
+	Tags      []string `json:"tags"`
+	WordCount int      `json:"word_count"`
+	Excerpt   string   `json:"excerpt"`
+	Hash      string   `json:"hash,omitempty"`
+}
+
+func articleJsonUrl(art article) string {
+	return art.url[:strings.LastIndex(art.url, "/")+1] + "index.json"
+}
+
+// articleExcerpt returns the text of the first paragraph of an article,
+// shortened to excerptWords words.
+func articleExcerpt(art article) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(art.content))
+	if err != nil {
+		return "", err
+	}
+
+	var words []string
+	doc.Find("p").EachWithBreak(func(_ int, p *goquery.Selection) bool {
+		if p.Closest(".article-info, .audio-player").Length() > 0 {
+			return true
+		}
+		words = strings.Fields(p.Text())
+		return len(words) == 0
+	})
+
+	if len(words) > excerptWords {
+		return strings.Join(words[:excerptWords], " ") + "…", nil
+	}
+	return strings.Join(words, " "), nil
+}
+
+func getArticleData(art article) (articleData, error) {
+	tags := art.metadata.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return articleData{
+		Title:     art.title,
+		Url:       siteUrl(art.url),
+		Date:      art.date.Format(time.DateOnly),
+		Updated:   art.updated.Format(time.DateOnly),
+		Tags:      tags,
+		WordCount: art.metadata.WordCount,
+		Excerpt:   art.summary,
+		Hash:      art.hash,
+	}, nil
+}
+
+func writeJsonFile(u string, owner string, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	target := targetPathFromUrl(u)
+	if err := claimOutput(target, owner); err != nil {
+		return err
+	}
+	if err := createDir(filepath.Dir(target)); err != nil {
+		return err
+	}
+	return writeOutputFile(target, append(data, '\n'))
+}
+
+func writeArticleJson(art article) error {
+	data, err := getArticleData(art)
+	if err != nil {
+		return err
+	}
+
+	return writeJsonFile(articleJsonUrl(art), "the JSON of "+art.source, data)
+}
+
+// writeArticlesJson writes the index of all articles, newest first.
+func writeArticlesJson() error {
+	list := append([]article(nil), articles...)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].date.After(list[j].date)
+	})
+
+	index := make([]articleData, 0, len(list))
+	for _, art := range list {
+		data, err := getArticleData(art)
+		if err != nil {
+			return err
+		}
+		index = append(index, data)
+	}
+
+	writtenFeeds = append(writtenFeeds, articlesJsonUrl)
+	return writeJsonFile(articlesJsonUrl, "the article index", index)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// defaults holds the template and stylesheet used when no TEMPLATE_PATH is
+// set, so the generator works as a single binary without any setup.
+//
+//go:embed defaults
+var defaults embed.FS
+
+const defaultStylesheetUrl = "/default.css"
+
+// readTemplateFile reads a template, the built-in one for an empty path.
+func readTemplateFile(path string) ([]byte, error) {
+	var data []byte
+	var err error
+	if path == "" {
+		data, err = defaults.ReadFile("defaults/template.html")
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err == nil {
+		recordTemplate(path, data)
+	}
+
+	return data, err
+}
+
+// templateName names a template in messages.
+func templateName(path string) string {
+	if path == "" {
+		return "the default template"
+	}
+
+	return path
+}
+
+// writeDefaultStylesheet writes the stylesheet the built-in template links.
+func writeDefaultStylesheet() error {
+	data, err := defaults.ReadFile("defaults" + defaultStylesheetUrl)
+	if err != nil {
+		return err
+	}
+
+	target := targetPathFromUrl(defaultStylesheetUrl)
+	if err := recordOutput(target, templateName("")); err != nil {
+		return err
+	}
+	if err := createDir(filepath.Dir(target)); err != nil {
+		return err
+	}
+	if err := writeOutputFile(target, data); err != nil {
+		return fmt.Errorf("Failed to write default stylesheet: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Converted sources wrap a shortcode on its own line in a paragraph, which
+// is dropped along with it.
+var detailsShortcode = regexp.MustCompile(`(?:<p>\s*)?\{\{<\s*(/?)details((?:\s+\w+="[^"]*")*)\s*>\}\}(?:\s*</p>)?`)
+
+// expandDetails turns {{< details summary="..." >}} ... {{< /details >}}
+// into a collapsed details element. Blocks may be nested, and open="true"
+// shows a block expanded.
+func expandDetails(text string, source string) (string, error) {
+	var out strings.Builder
+	var opened []string
+	last := 0
+	for _, match := range detailsShortcode.FindAllStringSubmatchIndex(text, -1) {
+		out.WriteString(text[last:match[0]])
+		last = match[1]
+
+		if text[match[2]:match[3]] == "/" {
+			if len(opened) == 0 {
+				return "", directiveError(source, text, text[match[0]:match[1]], fmt.Errorf("Unopened details block"))
+			}
+			opened = opened[:len(opened)-1]
+			out.WriteString("</details>")
+			continue
+		}
+
+		args := make(map[string]string)
+		for _, arg := range queryArgument.FindAllStringSubmatch(html.UnescapeString(text[match[4]:match[5]]), -1) {
+			args[arg[1]] = arg[2]
+		}
+
+		summary := args["summary"]
+		if summary == "" {
+			summary = "Details"
+		}
+		open := ""
+		if args["open"] == "true" {
+			open = " open"
+		}
+
+		opened = append(opened, text[match[0]:match[1]])
+		fmt.Fprintf(&out, "<details%s><summary>%s</summary>", open, html.EscapeString(summary))
+	}
+	out.WriteString(text[last:])
+
+	if len(opened) > 0 {
+		return "", directiveError(source, text, opened[len(opened)-1], fmt.Errorf("Unclosed details block"))
+	}
+
+	return out.String(), nil
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// diagnostic is a problem found by a build. Check names the feature that
+// found it, like validate or wiki-link, and level is warning for problems
+// that do not stop the build or error for source files that failed.
+type diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Check   string `json:"check"`
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// diagnostics collects what the features report during a build, once each,
+// since a source may be read more than once.
+var (
+	diagnostics    []diagnostic
+	seenDiagnostic = make(map[diagnostic]bool)
+)
+
+func addDiagnostic(check string, file string, line int, format string, args ...any) {
+	recordDiagnostic(diagnostic{File: file, Line: line, Check: check, Level: "warning", Message: fmt.Sprintf(format, args...)})
+}
+
+func recordDiagnostic(d diagnostic) {
+	if seenDiagnostic[d] {
+		return
+	}
+
+	seenDiagnostic[d] = true
+	diagnostics = append(diagnostics, d)
+}
+
+// keepGoing lets a build go on past a source file that fails, so that all
+// the failures are reported at the end instead of only the first.
+var keepGoing bool
+
+// failedSources are the source files that failed, each reported once even
+// when several stages read them.
+var failedSources = make(map[string]bool)
+
+// skipFailure records err as the failure of a source file and returns nil
+// when the build keeps going, or returns err to stop it.
+func skipFailure(path string, err error) error {
+	if err == nil || !keepGoing {
+		return err
+	}
+	if failedSources[path] {
+		return nil
+	}
+	failedSources[path] = true
+
+	d := diagnostic{File: path, Check: "build", Level: "error", Message: err.Error()}
+	var located *sourceError
+	if errors.As(err, &located) && located.path == path {
+		d.Message = located.err.Error()
+		if located.position != nil {
+			d.Line = located.position.line
+		}
+	}
+	recordDiagnostic(d)
+	return nil
+}
+
+// checkFailures fails a build that went past failed source files, once
+// everything else has been built, reported and put in place. The site is
+// promoted without the pages of the failed sources, so one broken article
+// does not hold back the rest; with --fail-fast the build stops at the first
+// failure and the previous site stays.
+func checkFailures() error {
+	if len(failedSources) > 0 {
+		return fmt.Errorf("Build failed in %d source files", len(failedSources))
+	}
+	return nil
+}
+
+func countErrors() int {
+	count := 0
+	for _, d := range diagnostics {
+		if d.Level == "error" {
+			count++
+		}
+	}
+
+	return count
+}
+
+func countDiagnostics(checks ...string) int {
+	count := 0
+	for _, d := range diagnostics {
+		for _, check := range checks {
+			if d.Check == check {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+func checkDiagnosticsFormat(format string) error {
+	switch format {
+	case "text", "json", "sarif", "github":
+		return nil
+	default:
+		return fmt.Errorf("Unknown diagnostics format: %s", format)
+	}
+}
+
+// writeDiagnosticsText lists the diagnostics grouped by file.
+func writeDiagnosticsText(w io.Writer, list []diagnostic) {
+	for i, d := range list {
+		if i == 0 || d.File != list[i-1].File {
+			fmt.Fprintf(w, "%s:\n", d.File)
+		}
+
+		location := ""
+		if d.Line > 0 {
+			location = fmt.Sprintf("%d: ", d.Line)
+		}
+		if d.Level == "error" {
+			location += "error: "
+		}
+		fmt.Fprintf(w, "  %s%s [%s]\n", location, d.Message, d.Check)
+	}
+}
+
+// diagnosticUri is a file relative to the working directory, where code
+// scanning tools look for it, or a page url without its leading slash.
+func diagnosticUri(file string) string {
+	if fileExists(file) {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, file); err == nil {
+				return filepath.ToSlash(rel)
+			}
+		}
+	}
+	return strings.TrimPrefix(file, "/")
+}
+
+// githubEscape escapes a value of a GitHub Actions workflow command, where
+// properties also end at commas and colons.
+func githubEscape(value string, property bool) string {
+	value = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(value)
+	if property {
+		value = strings.NewReplacer(":", "%3A", ",", "%2C").Replace(value)
+	}
+	return value
+}
+
+// writeDiagnosticsGithub writes the diagnostics as workflow commands, which
+// GitHub Actions shows as annotations on the files of a pull request.
+func writeDiagnosticsGithub(w io.Writer, list []diagnostic) {
+	for _, d := range list {
+		properties := "file=" + githubEscape(diagnosticUri(d.File), true)
+		if d.Line > 0 {
+			properties += fmt.Sprintf(",line=%d", d.Line)
+		}
+		properties += ",title=" + githubEscape(d.Check, true)
+		fmt.Fprintf(w, "::%s %s::%s\n", d.Level, properties, githubEscape(d.Message, false))
+	}
+}
+
+// sarifLog is the subset of SARIF 2.1.0 that code scanning tools need to
+// annotate files.
+func sarifLog(list []diagnostic) any {
+	type location struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				Uri string `json:"uri"`
+			} `json:"artifactLocation"`
+			Region *struct {
+				StartLine int `json:"startLine"`
+			} `json:"region,omitempty"`
+		} `json:"physicalLocation"`
+	}
+	type result struct {
+		RuleId  string `json:"ruleId"`
+		Level   string `json:"level"`
+		Message struct {
+			Text string `json:"text"`
+		} `json:"message"`
+		Locations []location `json:"locations"`
+	}
+	type rule struct {
+		Id string `json:"id"`
+	}
+
+	results := []result{}
+	checks := make(map[string]bool)
+	for _, d := range list {
+		var r result
+		r.RuleId, r.Level, r.Message.Text = d.Check, d.Level, d.Message
+		var l location
+		l.PhysicalLocation.ArtifactLocation.Uri = diagnosticUri(d.File)
+		if d.Line > 0 {
+			l.PhysicalLocation.Region = &struct {
+				StartLine int `json:"startLine"`
+			}{d.Line}
+		}
+		r.Locations = []location{l}
+		results = append(results, r)
+		checks[d.Check] = true
+	}
+
+	rules := []rule{}
+	for check := range checks {
+		rules = append(rules, rule{Id: check})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Id < rules[j].Id })
+
+	return map[string]any{
+		"version": "2.1.0",
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"runs": []any{map[string]any{
+			"tool":    map[string]any{"driver": map[string]any{"name": "site-generator", "version": buildVersion(), "rules": rules}},
+			"results": results,
+		}},
+	}
+}
+
+// reportDiagnostics writes the diagnostics of the build to path, or to
+// standard error without one.
+func reportDiagnostics(format string, path string) error {
+	if path == "" {
+		return writeDiagnostics(os.Stderr, format)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Cannot write diagnostics: %w", err)
+	}
+	defer file.Close()
+	return writeDiagnostics(file, format)
+}
+
+// writeDiagnostics writes the diagnostics sorted by file as text, JSON,
+// SARIF or GitHub annotations.
+func writeDiagnostics(w io.Writer, format string) error {
+	list := append([]diagnostic(nil), diagnostics...)
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].File != list[j].File {
+			return list[i].File < list[j].File
+		}
+		return list[i].Line < list[j].Line
+	})
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if list == nil {
+			list = []diagnostic{}
+		}
+		return encoder.Encode(list)
+	case "sarif":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(sarifLog(list))
+	case "github":
+		writeDiagnosticsGithub(w, list)
+		return nil
+	default:
+		writeDiagnosticsText(w, list)
+		return nil
+	}
+}
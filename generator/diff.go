@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// diffContext is how many unchanged words are shown around a change.
+const diffContext = 5
+
+// maxDiffCells bounds the table of the word diff. Pages that differ in more
+// are shown as a whole removed and added.
+const maxDiffCells = 4_000_000
+
+type wordEdit struct {
+	op    byte
+	words []string
+}
+
+// changedPage is a page whose file changed. Compared is false when the page
+// of either build could not be read, so only its hash is known to differ.
+type changedPage struct {
+	Path         string   `json:"path"`
+	Source       string   `json:"source,omitempty"`
+	Compared     bool     `json:"compared"`
+	WordsAdded   int      `json:"words_added"`
+	WordsRemoved int      `json:"words_removed"`
+	Changes      []string `json:"changes"`
+}
+
+// siteDiff is what changed on the rendered site between two builds.
+type siteDiff struct {
+	Added   []string      `json:"added"`
+	Removed []string      `json:"removed"`
+	Changed []changedPage `json:"changed"`
+}
+
+func readManifest(path string) (map[string]manifestEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read manifest: %w", err)
+	}
+
+	var manifest struct {
+		Files []manifestEntry `json:"files"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, jsonError(path, data, err)
+	}
+
+	files := make(map[string]manifestEntry)
+	for _, entry := range manifest.Files {
+		if entry.Type == "page" {
+			files[entry.Path] = entry
+		}
+	}
+	return files, nil
+}
+
+// pageWords is the text of the content of a built page, word by word. Pages
+// built from another template without #content use their body.
+func pageWords(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	doc, err := goquery.NewDocumentFromReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse %s: %w", path, err)
+	}
+
+	content := doc.Find("#content")
+	if content.Length() == 0 {
+		content = doc.Find("body")
+	}
+	content.Find("script, style").Remove()
+	return strings.Fields(content.Text()), nil
+}
+
+// diffWords lines up the words of two texts by their longest common
+// subsequence, after the common start and end are taken off.
+func diffWords(a []string, b []string) []wordEdit {
+	var edits []wordEdit
+	add := func(op byte, word string) {
+		if n := len(edits); n > 0 && edits[n-1].op == op {
+			edits[n-1].words = append(edits[n-1].words, word)
+			return
+		}
+		edits = append(edits, wordEdit{op: op, words: []string{word}})
+	}
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	for _, word := range a[:prefix] {
+		add(' ', word)
+	}
+
+	oldWords, newWords := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(oldWords), len(newWords)
+	i, j := 0, 0
+	if n*m <= maxDiffCells {
+		lcs := make([][]int32, n+1)
+		for k := range lcs {
+			lcs[k] = make([]int32, m+1)
+		}
+		for x := n - 1; x >= 0; x-- {
+			for y := m - 1; y >= 0; y-- {
+				if oldWords[x] == newWords[y] {
+					lcs[x][y] = lcs[x+1][y+1] + 1
+				} else {
+					lcs[x][y] = max(lcs[x+1][y], lcs[x][y+1])
+				}
+			}
+		}
+
+		for i < n && j < m {
+			switch {
+			case oldWords[i] == newWords[j]:
+				add(' ', oldWords[i])
+				i, j = i+1, j+1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				add('-', oldWords[i])
+				i++
+			default:
+				add('+', newWords[j])
+				j++
+			}
+		}
+	}
+	for ; i < n; i++ {
+		add('-', oldWords[i])
+	}
+	for ; j < m; j++ {
+		add('+', newWords[j])
+	}
+
+	for _, word := range a[len(a)-suffix:] {
+		add(' ', word)
+	}
+	return edits
+}
+
+// diffHunks writes the changes like git's word diff, [-removed-] and
+// {+added+}, each with the words around it. Changes close together share a
+// hunk.
+func diffHunks(edits []wordEdit) []string {
+	var hunks []string
+	var hunk []string
+	for k, edit := range edits {
+		if edit.op != ' ' {
+			marks := map[byte][2]string{'-': {"[-", "-]"}, '+': {"{+", "+}"}}[edit.op]
+			hunk = append(hunk, marks[0]+strings.Join(edit.words, " ")+marks[1])
+			continue
+		}
+
+		words := edit.words
+		first, last := k == 0, k == len(edits)-1
+		switch {
+		case first && last:
+		case first:
+			if len(words) > diffContext {
+				words = append([]string{"…"}, words[len(words)-diffContext:]...)
+			}
+			hunk = append(hunk, words...)
+		case last:
+			if len(words) > diffContext {
+				words = append(words[:diffContext:diffContext], "…")
+			}
+			hunk = append(hunk, words...)
+		case len(words) > 2*diffContext:
+			hunk = append(hunk, append(words[:diffContext:diffContext], "…")...)
+			hunks = append(hunks, strings.Join(hunk, " "))
+			hunk = append([]string{"…"}, words[len(words)-diffContext:]...)
+		default:
+			hunk = append(hunk, words...)
+		}
+	}
+	if len(hunk) > 0 && len(edits) > 1 {
+		hunks = append(hunks, strings.Join(hunk, " "))
+	}
+
+	return hunks
+}
+
+// compareBuilds compares the pages of two manifests. The pages themselves
+// are read from the builds the manifests are in, when they are still there.
+func compareBuilds(oldPath string, newPath string) (siteDiff, error) {
+	diff := siteDiff{Added: []string{}, Removed: []string{}, Changed: []changedPage{}}
+
+	oldFiles, err := readManifest(oldPath)
+	if err != nil {
+		return diff, err
+	}
+	newFiles, err := readManifest(newPath)
+	if err != nil {
+		return diff, err
+	}
+
+	for path, entry := range newFiles {
+		old, ok := oldFiles[path]
+		if !ok {
+			diff.Added = append(diff.Added, path)
+			continue
+		}
+		if old.Hash == entry.Hash {
+			continue
+		}
+
+		page := changedPage{Path: path, Source: entry.Source, Changes: []string{}}
+		oldWords, oldErr := pageWords(filepath.Join(filepath.Dir(oldPath), filepath.FromSlash(path)))
+		newWords, newErr := pageWords(filepath.Join(filepath.Dir(newPath), filepath.FromSlash(path)))
+		if oldErr == nil && newErr == nil {
+			page.Compared = true
+			edits := diffWords(oldWords, newWords)
+			for _, edit := range edits {
+				switch edit.op {
+				case '-':
+					page.WordsRemoved += len(edit.words)
+				case '+':
+					page.WordsAdded += len(edit.words)
+				}
+			}
+			page.Changes = diffHunks(edits)
+		}
+		diff.Changed = append(diff.Changed, page)
+	}
+	for path := range oldFiles {
+		if _, ok := newFiles[path]; !ok {
+			diff.Removed = append(diff.Removed, path)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Path < diff.Changed[j].Path })
+	return diff, nil
+}
+
+func (diff siteDiff) text() string {
+	var b strings.Builder
+	for _, path := range diff.Added {
+		fmt.Fprintf(&b, "Added: %s\n", path)
+	}
+	for _, path := range diff.Removed {
+		fmt.Fprintf(&b, "Removed: %s\n", path)
+	}
+	for _, page := range diff.Changed {
+		if !page.Compared {
+			fmt.Fprintf(&b, "Changed: %s\n", page.Path)
+			continue
+		}
+		if len(page.Changes) == 0 {
+			fmt.Fprintf(&b, "Changed: %s (markup only)\n", page.Path)
+			continue
+		}
+
+		fmt.Fprintf(&b, "Changed: %s (+%d -%d words)\n", page.Path, page.WordsAdded, page.WordsRemoved)
+		for _, change := range page.Changes {
+			fmt.Fprintf(&b, "  %s\n", change)
+		}
+	}
+
+	if b.Len() == 0 {
+		return "No pages changed.\n"
+	}
+	return b.String()
+}
+
+// runDiff compares the current build to the build of an earlier manifest,
+// to review what a change of the content does to the rendered site.
+func runDiff(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	newPath := flags.String("new", "", "manifest of the build to compare, the one in TARGET_PATH by default")
+	format := flags.String("format", "text", "write the diff as text or json")
+	output := flags.String("output", "", "file to write the diff to instead of standard output")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Usage: diff [--new manifest] [--format text|json] [--output file] old-manifest")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("Unknown diff format: %s", *format)
+	}
+	if *newPath == "" {
+		*newPath = filepath.Join(outputDirectory(), "manifest.json")
+	}
+
+	diff, err := compareBuilds(flags.Arg(0), *newPath)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if *format == "json" {
+		if data, err = json.MarshalIndent(diff, "", "  "); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	} else {
+		data = []byte(diff.text())
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*output, data, 0644)
+}
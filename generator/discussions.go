@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type discussionsConfig struct {
+	FetchCounts bool   `json:"fetch_counts"`
+	CacheFile   string `json:"cache_file"`
+	CacheHours  int    `json:"cache_hours"`
+}
+
+type commentCount struct {
+	Count     int       `json:"count"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+var (
+	commentCounts       map[string]commentCount
+	commentCountsLoaded bool
+	httpClient          = &http.Client{Timeout: 10 * time.Second, Transport: networkTransport{}}
+)
+
+func discussionsCacheFile() string {
+	if config.Discussions.CacheFile != "" {
+		return config.Discussions.CacheFile
+	}
+
+	return cachePath("discussion-counts.json")
+}
+
+func discussionSite(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	switch {
+	case host == "news.ycombinator.com":
+		return "Hacker News"
+	case host == "reddit.com" || strings.HasSuffix(host, ".reddit.com"):
+		return "Reddit"
+	case host == "lobste.rs":
+		return "Lobsters"
+	default:
+		return host
+	}
+}
+
+func fetchJson(link string, target any) error {
+	if err := requireNetwork("discussion comment counts", link); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("GET", link, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "site-generator")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", link, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+func fetchCommentCount(link string) (int, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return 0, err
+	}
+
+	switch discussionSite(link) {
+	case "Hacker News":
+		var item struct {
+			Descendants int `json:"descendants"`
+		}
+		err := fetchJson("https://hacker-news.firebaseio.com/v0/item/"+u.Query().Get("id")+".json", &item)
+		return item.Descendants, err
+	case "Reddit":
+		var listings []struct {
+			Data struct {
+				Children []struct {
+					Data struct {
+						NumComments int `json:"num_comments"`
+					} `json:"data"`
+				} `json:"children"`
+			} `json:"data"`
+		}
+		if err := fetchJson(strings.TrimSuffix(link, "/")+".json", &listings); err != nil {
+			return 0, err
+		}
+		if len(listings) == 0 || len(listings[0].Data.Children) == 0 {
+			return 0, fmt.Errorf("Unexpected response for %s", link)
+		}
+		return listings[0].Data.Children[0].Data.NumComments, nil
+	case "Lobsters":
+		var story struct {
+			CommentCount int `json:"comment_count"`
+		}
+		err := fetchJson(strings.TrimSuffix(link, "/")+".json", &story)
+		return story.CommentCount, err
+	default:
+		return 0, fmt.Errorf("Cannot count comments on %s", link)
+	}
+}
+
+func loadCommentCounts() {
+	commentCountsLoaded = true
+	commentCounts = make(map[string]commentCount)
+	readCacheFile(discussionsCacheFile(), &commentCounts)
+}
+
+func saveCommentCounts() error {
+	if !commentCountsLoaded {
+		return nil
+	}
+
+	return writeCacheFile(discussionsCacheFile(), commentCounts)
+}
+
+// discussionCommentCount returns the cached count while it is fresh and
+// falls back to a stale one when fetching fails.
+func discussionCommentCount(link string) (int, bool) {
+	if !commentCountsLoaded {
+		loadCommentCounts()
+	}
+
+	ttl := 24 * time.Hour
+	if config.Discussions.CacheHours > 0 {
+		ttl = time.Duration(config.Discussions.CacheHours) * time.Hour
+	}
+
+	cached, ok := commentCounts[link]
+	if ok && (time.Since(cached.FetchedAt) < ttl || offline) {
+		return cached.Count, true
+	}
+
+	count, err := fetchCommentCount(link)
+	if err != nil {
+		addDiagnostic("network", link, 0, "Cannot fetch comment count: %s", err)
+		return cached.Count, ok
+	}
+
+	commentCounts[link] = commentCount{Count: count, FetchedAt: time.Now()}
+	return count, true
+}
+
+func discussionsSection(links []string) string {
+	var list strings.Builder
+	for _, link := range links {
+		fmt.Fprintf(&list, `<li><a href="%s">%s</a>`, html.EscapeString(link), html.EscapeString(discussionSite(link)))
+		if config.Discussions.FetchCounts {
+			if count, ok := discussionCommentCount(link); ok {
+				fmt.Fprintf(&list, " (%d comments)", count)
+			}
+		}
+		list.WriteString("</li>")
+	}
+
+	return fmt.Sprintf(`<section class="discussions"><h2>Discuss this post</h2><ul>%s</ul></section>`, list.String())
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"hash/fnv"
+	"math"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type duplicatesConfig struct {
+	Enabled   bool    `json:"enabled"`
+	Threshold float64 `json:"threshold"`
+}
+
+const (
+	shingleWords    = 5
+	minHashFunction = 128
+)
+
+type articleSignature struct {
+	url       string
+	signature []uint64
+}
+
+var articleSignatures []articleSignature
+
+// mixHash derives the i-th hash function from one hash of a shingle.
+func mixHash(hash uint64, i int) uint64 {
+	hash ^= uint64(i+1) * 0x9E3779B97F4A7C15
+	hash ^= hash >> 33
+	hash *= 0xFF51AFD7ED558CCD
+	hash ^= hash >> 33
+	return hash
+}
+
+// minHashSignature sketches the set of word shingles of a text. The share
+// of equal positions in two signatures estimates how much of the shingles
+// the texts have in common.
+func minHashSignature(text string) []uint64 {
+	words := strings.Fields(strings.ToLower(text))
+	if len(words) < shingleWords {
+		return nil
+	}
+
+	signature := make([]uint64, minHashFunction)
+	for i := range signature {
+		signature[i] = math.MaxUint64
+	}
+	for start := 0; start+shingleWords <= len(words); start++ {
+		hash := fnv.New64a()
+		hash.Write([]byte(strings.Join(words[start:start+shingleWords], " ")))
+		shingle := hash.Sum64()
+		for i := range signature {
+			signature[i] = min(signature[i], mixHash(shingle, i))
+		}
+	}
+
+	return signature
+}
+
+func collectSignature(art article) error {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(art.content))
+	if err != nil {
+		return err
+	}
+	doc.Find(".article-info").Remove()
+
+	if signature := minHashSignature(doc.Text()); signature != nil {
+		articleSignatures = append(articleSignatures, articleSignature{art.url, signature})
+	}
+	return nil
+}
+
+// reportDuplicates warns about every pair of articles whose texts are more
+// alike than the threshold, usually a draft that was copied and published
+// twice.
+func reportDuplicates() {
+	threshold := config.Duplicates.Threshold
+	if threshold <= 0 {
+		threshold = 0.8
+	}
+
+	for i, a := range articleSignatures {
+		for _, b := range articleSignatures[i+1:] {
+			equal := 0
+			for k := range a.signature {
+				if a.signature[k] == b.signature[k] {
+					equal++
+				}
+			}
+
+			if similarity := float64(equal) / minHashFunction; similarity >= threshold {
+				addDiagnostic("duplicates", a.url, 0, "near-duplicate of %s, %.0f%% alike", b.url, similarity*100)
+			}
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+type epubBook struct {
+	title    string
+	author   string
+	language string
+	articles []article
+}
+
+type epubItem struct {
+	id         string
+	href       string
+	mediaType  string
+	properties string
+	data       []byte
+}
+
+type epubChapter struct {
+	id    string
+	href  string
+	title string
+}
+
+const epubStyle = `body { font-family: serif; line-height: 1.5; }
+h1, h2, h3 { line-height: 1.2; }
+img { max-width: 100%; }
+pre { white-space: pre-wrap; font-size: 0.85em; }
+.article-info { font-style: italic; }
+`
+
+func xmlEscape(text string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(text))
+	return buf.String()
+}
+
+func xhtmlPage(language string, title string, body string) []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="%[1]s" lang="%[1]s">
+<head>
+<meta charset="UTF-8"/>
+<title>%[2]s</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+%[3]s
+</body>
+</html>
+`, xmlEscape(language), xmlEscape(title), body))
+}
+
+func renderXhtml(selection *goquery.Selection) (string, error) {
+	var buf bytes.Buffer
+	for _, node := range selection.Nodes {
+		if err := html.Render(&buf, node); err != nil {
+			return "", err
+		}
+	}
+
+	return buf.String(), nil
+}
+
+func (b *epubBook) identifier() string {
+	hash := sha1.New()
+	hash.Write([]byte(b.title))
+	for _, a := range b.articles {
+		hash.Write([]byte(a.url))
+	}
+	sum := hash.Sum(nil)
+
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+func (b *epubBook) cover() []byte {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(b.title) {
+		if line != "" && len(line)+len(word) > 18 {
+			lines = append(lines, line)
+			line = ""
+		}
+		line = strings.TrimSpace(line + " " + word)
+	}
+	lines = append(lines, line)
+
+	var text strings.Builder
+	for i, l := range lines {
+		fmt.Fprintf(&text, `<text x="60" y="%d" font-size="48" font-family="serif" fill="#dddddd">%s</text>`+"\n",
+			260+i*60, xmlEscape(l))
+	}
+
+	underline := 260 + (len(lines)-1)*60 + 25
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="600" height="800" viewBox="0 0 600 800">
+<rect width="600" height="800" fill="#222222"/>
+%s<rect x="60" y="%d" width="200" height="6" fill="#387438"/>
+<text x="60" y="720" font-size="28" font-family="serif" fill="#bbbbbb">%s</text>
+</svg>
+`, text.String(), underline, xmlEscape(b.author)))
+}
+
+// epubImageTypes are the image types EPUB readers have to show, with the
+// extension they are stored under.
+var epubImageTypes = map[string]string{
+	"image/gif":     ".gif",
+	"image/jpeg":    ".jpg",
+	"image/png":     ".png",
+	"image/svg+xml": ".svg",
+	"image/webp":    ".webp",
+}
+
+// fetchEpubImage downloads an image an article shows from another site, so
+// the book carries it along and reads without a connection.
+func fetchEpubImage(link string) ([]byte, string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, "", err
+	}
+	if u.Scheme == "" && u.Host != "" {
+		u.Scheme = "https"
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, "", fmt.Errorf("only http and https images can be fetched")
+	}
+	if err := requireNetwork("EPUB images", u.String()); err != nil {
+		return nil, "", err
+	}
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", "site-generator")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("%s returned %s", u, resp.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if _, ok := epubImageTypes[mediaType]; !ok {
+		return nil, "", fmt.Errorf("%s is %q, not an image EPUB readers show", u, mediaType)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	return data, mediaType, err
+}
+
+// chapters converts every article to an XHTML document, collecting the images
+// it references, local or not, so they can be bundled next to it.
+func (b *epubBook) chapters() ([]epubChapter, []epubItem, error) {
+	var chapters []epubChapter
+	var items []epubItem
+
+	hrefs := make(map[string]string)
+	for i, a := range b.articles {
+		hrefs[a.source] = fmt.Sprintf("chapter-%d.xhtml", i+1)
+	}
+
+	images := make(map[string]string)
+	for i, a := range b.articles {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(a.content))
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to parse %s: %w", a.source, err)
+		}
+
+		doc.Find("script, style, iframe, noscript, object, embed").Remove()
+
+		var imageErr error
+		doc.Find("img").Each(func(_ int, img *goquery.Selection) {
+			img.RemoveAttr("srcset")
+			src, _ := img.Attr("src")
+			path, local := contentPathFromUrl(src, a.source)
+			key := path
+			if !local {
+				key = src
+			}
+
+			if href, ok := images[key]; ok {
+				img.SetAttr("src", href)
+				return
+			}
+
+			leaveOut := func(reason error) {
+				fmt.Fprintf(os.Stderr, "Left image %s out of %s, showing its alt text instead: %s\n", src, a.source, reason)
+				alt, _ := img.Attr("alt")
+				img.ReplaceWithHtml("<span>" + xmlEscape(alt) + "</span>")
+			}
+
+			var data []byte
+			var mediaType string
+			var err error
+			if local {
+				if data, err = readImageFile(path); err != nil {
+					imageErr = fmt.Errorf("Cannot read image %s referenced by %s: %w", src, a.source, err)
+					return
+				}
+				// Without a known extension the type is sniffed from the data.
+				mediaType, _, _ = mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(path)))
+				if mediaType == "" {
+					mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+				}
+				if _, ok := epubImageTypes[mediaType]; !ok {
+					leaveOut(fmt.Errorf("%s is %q, not an image EPUB readers show", src, mediaType))
+					return
+				}
+			} else if data, mediaType, err = fetchEpubImage(src); err != nil {
+				leaveOut(err)
+				return
+			}
+			ext := epubImageTypes[mediaType]
+
+			href := fmt.Sprintf("images/image-%d%s", len(images)+1, ext)
+			images[key] = href
+			items = append(items, epubItem{
+				id:        fmt.Sprintf("image-%d", len(images)),
+				href:      href,
+				mediaType: mediaType,
+				data:      data,
+			})
+			img.SetAttr("src", href)
+		})
+		if imageErr != nil {
+			return nil, nil, imageErr
+		}
+
+		doc.Find("a[href]").Each(func(_ int, link *goquery.Selection) {
+			href, _ := link.Attr("href")
+			path, ok := contentPathFromUrl(href, a.source)
+			if !ok {
+				return
+			}
+			if filepath.Ext(path) == "" {
+				path = filepath.Join(path, "index.html")
+			}
+
+			if chapter, ok := hrefs[path]; ok {
+				link.SetAttr("href", chapter)
+			} else {
+				link.RemoveAttr("href")
+			}
+		})
+
+		body, err := renderXhtml(doc.Find("body").Contents())
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to serialize %s: %w", a.source, err)
+		}
+
+		chapter := epubChapter{
+			id:    fmt.Sprintf("chapter-%d", i+1),
+			href:  hrefs[a.source],
+			title: a.title,
+		}
+		chapters = append(chapters, chapter)
+		items = append(items, epubItem{
+			id:        chapter.id,
+			href:      chapter.href,
+			mediaType: "application/xhtml+xml",
+			data:      xhtmlPage(b.language, a.title, body),
+		})
+	}
+
+	return chapters, items, nil
+}
+
+func (b *epubBook) navigation(chapters []epubChapter) []byte {
+	var list strings.Builder
+	for _, c := range chapters {
+		fmt.Fprintf(&list, "<li><a href=\"%s\">%s</a></li>\n", c.href, xmlEscape(c.title))
+	}
+
+	return xhtmlPage(b.language, b.title, fmt.Sprintf(
+		"<nav epub:type=\"toc\" id=\"toc\">\n<h1>Contents</h1>\n<ol>\n%s</ol>\n</nav>", list.String()))
+}
+
+func (b *epubBook) ncx(chapters []epubChapter) []byte {
+	var points strings.Builder
+	for i, c := range chapters {
+		fmt.Fprintf(&points, `<navPoint id="%s" playOrder="%d"><navLabel><text>%s</text></navLabel><content src="%s"/></navPoint>`+"\n",
+			c.id, i+1, xmlEscape(c.title), c.href)
+	}
+
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+<head><meta name="dtb:uid" content="%s"/></head>
+<docTitle><text>%s</text></docTitle>
+<navMap>
+%s</navMap>
+</ncx>
+`, b.identifier(), xmlEscape(b.title), points.String()))
+}
+
+func (b *epubBook) packageDocument(items []epubItem, chapters []epubChapter) []byte {
+	var manifest strings.Builder
+	for _, item := range items {
+		properties := ""
+		if item.properties != "" {
+			properties = fmt.Sprintf(` properties="%s"`, item.properties)
+		}
+		fmt.Fprintf(&manifest, `<item id="%s" href="%s" media-type="%s"%s/>`+"\n",
+			item.id, item.href, item.mediaType, properties)
+	}
+	manifest.WriteString(`<item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>` + "\n")
+
+	var spine strings.Builder
+	spine.WriteString(`<itemref idref="cover"/>` + "\n")
+	spine.WriteString(`<itemref idref="nav"/>` + "\n")
+	for _, c := range chapters {
+		fmt.Fprintf(&spine, `<itemref idref="%s"/>`+"\n", c.id)
+	}
+
+	latest := b.articles[len(b.articles)-1].date
+	for _, a := range b.articles {
+		if a.date.After(latest) {
+			latest = a.date
+		}
+	}
+
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="%[1]s">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="book-id">%[2]s</dc:identifier>
+<dc:title>%[3]s</dc:title>
+<dc:creator>%[4]s</dc:creator>
+<dc:language>%[1]s</dc:language>
+<dc:date>%[5]s</dc:date>
+<meta property="dcterms:modified">%[6]s</meta>
+<meta name="cover" content="cover-image"/>
+</metadata>
+<manifest>
+%[7]s</manifest>
+<spine toc="ncx">
+%[8]s</spine>
+</package>
+`, xmlEscape(b.language), b.identifier(), xmlEscape(b.title), xmlEscape(b.author),
+		latest.Format("2006-01-02"), latest.UTC().Format("2006-01-02T15:04:05Z"),
+		manifest.String(), spine.String()))
+}
+
+func (b *epubBook) write(path string) error {
+	chapters, chapterItems, err := b.chapters()
+	if err != nil {
+		return err
+	}
+
+	items := []epubItem{
+		{id: "style", href: "style.css", mediaType: "text/css", data: []byte(epubStyle)},
+		{id: "cover-image", href: "cover.svg", mediaType: "image/svg+xml", properties: "cover-image", data: b.cover()},
+		{id: "cover", href: "cover.xhtml", mediaType: "application/xhtml+xml", data: xhtmlPage(b.language, b.title,
+			`<div style="text-align: center;"><img src="cover.svg" alt="`+xmlEscape(b.title)+`" style="height: 100%;"/></div>`)},
+		{id: "nav", href: "nav.xhtml", mediaType: "application/xhtml+xml", properties: "nav", data: b.navigation(chapters)},
+	}
+	items = append(items, chapterItems...)
+
+	container := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles>
+<rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+</rootfiles>
+</container>
+`)
+
+	return writeOutputWith(path, func(file *os.File) error {
+		archive := zip.NewWriter(file)
+
+		// The mimetype entry has to come first and stay uncompressed so readers
+		// can sniff the container type.
+		mimetype, err := archive.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if _, err := mimetype.Write([]byte("application/epub+zip")); err != nil {
+			return err
+		}
+
+		if err := writeZipFile(archive, "META-INF/container.xml", container); err != nil {
+			return err
+		}
+		if err := writeZipFile(archive, "OEBPS/content.opf", b.packageDocument(items, chapters)); err != nil {
+			return err
+		}
+		if err := writeZipFile(archive, "OEBPS/toc.ncx", b.ncx(chapters)); err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := writeZipFile(archive, "OEBPS/"+item.href, item.data); err != nil {
+				return err
+			}
+		}
+
+		return archive.Close()
+	})
+}
+
+func writeZipFile(archive *zip.Writer, name string, data []byte) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"archive/zip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEpubChapterImages(t *testing.T) {
+	content := t.TempDir()
+	t.Setenv("CONTENT_PATH", content)
+	saved := config
+	t.Cleanup(func() { config = saved })
+
+	writeTestFiles(t, content, map[string]string{
+		"articles/a/local.png": "local png",
+		"articles/a/sniffed":   "\x89PNG\r\n\x1a\nsniffed png",
+		"articles/a/notes.txt": "not an image",
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/remote.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("remote png"))
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<p>not an image</p>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		src      string
+		wantSrc  string
+		wantType string
+		wantData string
+		wantAlt  bool
+	}{
+		{"local", "local.png", "images/image-1.png", "image/png", "local png", false},
+		{"local without an extension", "sniffed", "images/image-1.png", "image/png", "\x89PNG\r\n\x1a\nsniffed png", false},
+		{"local not an image", "notes.txt", "", "", "", true},
+		{"remote", server.URL + "/remote.png", "images/image-1.png", "image/png", "remote png", false},
+		{"remote missing", server.URL + "/missing.png", "", "", "", true},
+		{"remote not an image", server.URL + "/page.html", "", "", "", true},
+		{"data URI", "data:image/png;base64,AAAA", "", "", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			book := epubBook{title: "Book", language: "en", articles: []article{{
+				title:   "A",
+				source:  filepath.Join(content, "articles", "a", "index.html"),
+				content: `<p><img src="` + test.src + `" alt="A picture"></p>`,
+			}}}
+
+			_, items, err := book.chapters()
+			if err != nil {
+				t.Fatal(err)
+			}
+			chapter := string(items[len(items)-1].data)
+
+			if test.wantAlt {
+				if len(items) != 1 || !strings.Contains(chapter, "<span>A picture</span>") {
+					t.Errorf("image not replaced by its alt text: %d items, chapter %s", len(items), chapter)
+				}
+				return
+			}
+			if len(items) != 2 {
+				t.Fatalf("%d items, want the image and the chapter", len(items))
+			}
+			image := items[0]
+			if image.href != test.wantSrc || image.mediaType != test.wantType || string(image.data) != test.wantData {
+				t.Errorf("image = %s %s %q, want %s %s %q", image.href, image.mediaType, image.data, test.wantSrc, test.wantType, test.wantData)
+			}
+			if !strings.Contains(chapter, `src="`+test.wantSrc+`"`) {
+				t.Errorf("chapter does not show %s: %s", test.wantSrc, chapter)
+			}
+		})
+	}
+}
+
+func TestEpubWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "book.epub")
+	book := epubBook{title: "Book", language: "en", articles: []article{{
+		title:   "A",
+		source:  filepath.Join(dir, "articles", "a", "index.html"),
+		content: "<p>Text</p>",
+	}}}
+
+	if err := book.write(path); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	if first := archive.File[0]; first.Name != "mimetype" || first.Method != zip.Store {
+		t.Errorf("first entry = %s stored with method %d, want mimetype uncompressed", first.Name, first.Method)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("%d files next to the book, want only the book", len(entries))
+	}
+}
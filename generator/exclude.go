@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// defaultExcludePrefixes mark notes and editor files, like _notes.md or
+// .index.md.swp, that are kept next to the content but never built.
+var defaultExcludePrefixes = []string{"_", "."}
+
+// isExcludedPath reports whether a file or directory of the content is left
+// out of the site by the start of its name. exclude_prefixes replaces the
+// default prefixes, and an empty list builds every name. Raw directories
+// are copied whole, so listing one like .well-known in raw keeps it.
+func isExcludedPath(path string) bool {
+	if filepath.Clean(path) == filepath.Clean(contentDirectory()) {
+		return false
+	}
+
+	prefixes := config.ExcludePrefixes
+	if prefixes == nil {
+		prefixes = defaultExcludePrefixes
+	}
+
+	name := filepath.Base(path)
+	for _, prefix := range prefixes {
+		if prefix != "" && strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// skipExcluded is what a walk of the content returns for an excluded entry.
+func skipExcluded(entry fs.DirEntry) error {
+	if entry.IsDir() {
+		return filepath.SkipDir
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func collectArticles() ([]article, error) {
+	var collected []article
+
+	err := filepath.WalkDir(contentDirectory(), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if isExcludedPath(path) {
+			return skipExcluded(entry)
+		}
+		if entry.IsDir() || !isArticlePath(path) || (isDraft(path) || isHeldBack(path)) && !config.Drafts {
+			return nil
+		}
+
+		html, err := readSourceHtml(path)
+		if err != nil {
+			return err
+		}
+
+		art, err := loadArticle(path, html)
+		if err != nil {
+			return err
+		}
+
+		collected = append(collected, art)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(collected, func(i, j int) bool {
+		return collected[i].date.Before(collected[j].date)
+	})
+
+	return collected, nil
+}
+
+func filterArticles(all []article, series string, tag string) []article {
+	var selected []article
+	for _, a := range all {
+		if series != "" && a.metadata.Series != series {
+			continue
+		}
+		if tag != "" && !slices.Contains(a.metadata.Tags, tag) {
+			continue
+		}
+		selected = append(selected, a)
+	}
+
+	return selected
+}
+
+func templateDocument() (*goquery.Document, error) {
+	return templateWithVariables(pageVariables("/index.html", config.Site["title"]))
+}
+
+func siteTitle(tmplDoc *goquery.Document) string {
+	return strings.TrimSpace(tmplDoc.Find("title").First().Text())
+}
+
+func siteLanguage(tmplDoc *goquery.Document) string {
+	if lang, ok := tmplDoc.Find("html").Attr("lang"); ok && lang != "" {
+		return lang
+	}
+
+	return "en"
+}
+
+func runExport(args []string) error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	if err := fetchContent(); err != nil {
+		return err
+	}
+
+	if len(args) < 1 {
+		return fmt.Errorf("Usage: export epub [--series name | --tag name] [--output file]")
+	}
+
+	switch args[0] {
+	case "epub":
+		return exportEpub(args[1:])
+	default:
+		return fmt.Errorf("Unknown export format: %s", args[0])
+	}
+}
+
+func exportEpub(args []string) error {
+	flags := flag.NewFlagSet("export epub", flag.ContinueOnError)
+	series := flags.String("series", "", "only include articles of this series")
+	tag := flags.String("tag", "", "only include articles with this tag")
+	output := flags.String("output", "", "path of the generated EPUB file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *series != "" && *tag != "" {
+		return fmt.Errorf("--series and --tag cannot be used together")
+	}
+
+	if err := checkTemplate(); err != nil {
+		return err
+	}
+
+	if err := loadSiteIndex(); err != nil {
+		return err
+	}
+
+	all, err := collectArticles()
+	if err != nil {
+		return err
+	}
+
+	if *tag != "" {
+		*tag = canonicalTag(*tag)
+	}
+
+	selected := filterArticles(all, *series, *tag)
+	if len(selected) == 0 {
+		return fmt.Errorf("No articles matched the selection")
+	}
+
+	tmplDoc, err := templateDocument()
+	if err != nil {
+		return err
+	}
+
+	title := siteTitle(tmplDoc)
+	switch {
+	case *series != "":
+		title = *series
+	case *tag != "":
+		title = fmt.Sprintf("%s: %s", title, *tag)
+	}
+
+	path := *output
+	if path == "" {
+		name := slugify(title)
+		if name == "" {
+			name = "blog"
+		}
+		path = name + ".epub"
+	}
+
+	book := epubBook{
+		title:    title,
+		author:   siteTitle(tmplDoc),
+		language: siteLanguage(tmplDoc),
+		articles: selected,
+	}
+	if err := book.write(path); err != nil {
+		return fmt.Errorf("Failed to write EPUB: %w", err)
+	}
+
+	fmt.Printf("Exported %d articles to %s\n", len(selected), path)
+	return nil
+}
+
+func slugify(text string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			slug.WriteRune(r)
+			dash = false
+		} else if !dash && slug.Len() > 0 {
+			slug.WriteRune('-')
+			dash = true
+		}
+	}
+
+	return strings.TrimSuffix(slug.String(), "-")
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type feedConfig struct {
+	Enabled bool `json:"enabled"`
+	Limit   int  `json:"limit"`
+	// Formats are written next to the Atom feed, which is always there:
+	// "rss" as feed.rss and "json" as feed.json.
+	Formats []string `json:"formats"`
+}
+
+const feedUrl = "/feed.xml"
+
+// feedMediaTypes are the media types of the feed formats besides Atom.
+var feedMediaTypes = map[string]string{
+	"rss":  "application/rss+xml",
+	"json": "application/feed+json",
+}
+
+// absoluteUrl resolves a link found on the page at pageUrl against the base
+// url of the site.
+func absoluteUrl(link string, pageUrl string) string {
+	base, err := url.Parse(strings.TrimSuffix(config.BaseUrl, "/") + pageUrl)
+	if err != nil {
+		return link
+	}
+
+	u, err := url.Parse(siteUrl(strings.TrimSpace(link)))
+	if err != nil {
+		return link
+	}
+
+	return base.ResolveReference(u).String()
+}
+
+// feedContent prepares article HTML for feed readers: active content is
+// removed and every link is made absolute.
+func feedContent(art article) (string, error) {
+	return modifyHtml(art.content, func(doc *goquery.Document) {
+		doc.Find("script, noscript, style, link, template, form").Remove()
+
+		doc.Find("iframe, object, embed").Each(func(_ int, media *goquery.Selection) {
+			src, _ := media.Attr("src")
+			if src == "" {
+				src, _ = media.Attr("data")
+			}
+			media.ReplaceWithHtml(fmt.Sprintf(`<p><a href="%s">Embedded media</a></p>`, xmlEscape(absoluteUrl(src, art.url))))
+		})
+
+		removeEventHandlers(doc)
+		doc.Find("[style]").RemoveAttr("style")
+
+		doc.Find("[href], [src], [poster]").Each(func(_ int, element *goquery.Selection) {
+			for _, name := range []string{"href", "src", "poster"} {
+				if link, ok := element.Attr(name); ok {
+					element.SetAttr(name, absoluteUrl(link, art.url))
+				}
+			}
+		})
+
+		doc.Find("[srcset]").Each(func(_ int, element *goquery.Selection) {
+			srcset, _ := element.Attr("srcset")
+			candidates := strings.Split(srcset, ",")
+			for i, candidate := range candidates {
+				if fields := strings.Fields(candidate); len(fields) > 0 {
+					fields[0] = absoluteUrl(fields[0], art.url)
+					candidates[i] = strings.Join(fields, " ")
+				}
+			}
+			element.SetAttr("srcset", strings.Join(candidates, ", "))
+		})
+	})
+}
+
+func writeFeed() error {
+	return writeFeedFile(feedUrl, "", articles)
+}
+
+// feedFormatUrl is the url of the feed at u in another format, such as
+// /feed.rss next to /feed.xml.
+func feedFormatUrl(u string, format string) string {
+	return strings.TrimSuffix(u, path.Ext(u)) + "." + format
+}
+
+// writeFeedFile writes a feed of the given articles, as Atom and in the
+// other configured formats. The title is appended to the site title when not
+// empty.
+func writeFeedFile(u string, title string, list []article) error {
+	if config.BaseUrl == "" {
+		return fmt.Errorf("The feed needs base_url to be set")
+	}
+
+	tmplDoc, err := templateDocument()
+	if err != nil {
+		return err
+	}
+
+	var entries []article
+	for _, art := range list {
+		if !art.metadata.ExcludeFeed {
+			entries = append(entries, art)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].date.After(entries[j].date)
+	})
+
+	limit := config.Feed.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	entries = entries[:min(limit, len(entries))]
+
+	feed := feedData{
+		url:      u,
+		home:     absoluteUrl(strings.TrimSuffix(path.Dir(u), "/")+"/", "/"),
+		language: siteLanguage(tmplDoc),
+		author:   siteTitle(tmplDoc),
+		title:    siteTitle(tmplDoc),
+	}
+	if title != "" {
+		feed.title += ": " + title
+	}
+	for _, art := range entries {
+		content, err := feedContent(art)
+		if err != nil {
+			return err
+		}
+
+		// An article that was never revised was last updated when released.
+		updated := art.updated
+		if updated.IsZero() {
+			updated = art.date
+		}
+		if updated.After(feed.updated) {
+			feed.updated = updated
+		}
+		feed.entries = append(feed.entries, feedEntry{art, content, updated})
+	}
+
+	if err := writeFeedOutput(u, feed.atom()); err != nil {
+		return err
+	}
+	for _, format := range config.Feed.Formats {
+		if format == "atom" {
+			continue
+		}
+
+		var document []byte
+		switch format {
+		case "rss":
+			document = feed.rss()
+		case "json":
+			if document, err = feed.json(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("Unknown feed format: %s", format)
+		}
+		if err := writeFeedOutput(feedFormatUrl(u, format), document); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeFeedOutput(u string, document []byte) error {
+	target := targetPathFromUrl(u)
+	if err := claimOutput(target, "the feed "+u); err != nil {
+		return err
+	}
+	if err := createDir(filepath.Dir(target)); err != nil {
+		return err
+	}
+	writtenFeeds = append(writtenFeeds, u)
+	return writeOutputFile(target, document)
+}
+
+// feedData is what every format of a feed is written from.
+type feedData struct {
+	url      string
+	home     string
+	title    string
+	language string
+	author   string
+	updated  time.Time
+	entries  []feedEntry
+}
+
+type feedEntry struct {
+	art     article
+	content string
+	updated time.Time
+}
+
+func (f feedData) atom() []byte {
+	var entries strings.Builder
+	for _, entry := range f.entries {
+		art := entry.art
+		link := absoluteUrl(art.url, "/")
+		fmt.Fprintf(&entries, "  <entry>\n    <title>%s</title>\n    <id>%s</id>\n    <link href=\"%s\"/>\n",
+			xmlEscape(art.title), xmlEscape(link), xmlEscape(link))
+		if art.metadata.CanonicalUrl != "" {
+			fmt.Fprintf(&entries, "    <link rel=\"via\" href=\"%s\"/>\n", xmlEscape(art.metadata.CanonicalUrl))
+		}
+		fmt.Fprintf(&entries, "    <published>%s</published>\n    <updated>%s</updated>\n",
+			art.date.Format(time.RFC3339), entry.updated.Format(time.RFC3339))
+		for _, tag := range art.metadata.Tags {
+			fmt.Fprintf(&entries, "    <category term=\"%s\"/>\n", xmlEscape(tag))
+		}
+		if art.summary != "" {
+			fmt.Fprintf(&entries, "    <summary>%s</summary>\n", xmlEscape(art.summary))
+		}
+		fmt.Fprintf(&entries, "    <content type=\"html\">%s</content>\n  </entry>\n", xmlEscape(entry.content))
+	}
+
+	updateBase := ""
+	if !nextPublish.IsZero() {
+		updateBase = fmt.Sprintf("  <sy:updateBase>%s</sy:updateBase>\n", nextPublish.Format(time.RFC3339))
+	}
+	hub := ""
+	if websubHub() != "" {
+		hub = fmt.Sprintf("  <link rel=\"hub\" href=\"%s\"/>\n", xmlEscape(websubHub()))
+	}
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:sy="http://purl.org/rss/1.0/modules/syndication/" xml:lang="%s">
+  <title>%s</title>
+  <id>%s</id>
+  <link href="%s"/>
+  <link rel="self" href="%s"/>
+%s  <updated>%s</updated>
+  <author><name>%s</name></author>
+  <sy:updatePeriod>%s</sy:updatePeriod>
+  <sy:updateFrequency>1</sy:updateFrequency>
+%s%s</feed>
+`, xmlEscape(f.language), xmlEscape(f.title), xmlEscape(f.home), xmlEscape(f.home),
+		xmlEscape(absoluteUrl(f.url, "/")), hub, f.updated.Format(time.RFC3339), xmlEscape(f.author),
+		feedUpdatePeriod(), updateBase, entries.String()))
+}
+
+func (f feedData) rss() []byte {
+	var items strings.Builder
+	for _, entry := range f.entries {
+		art := entry.art
+		link := absoluteUrl(art.url, "/")
+		fmt.Fprintf(&items, "    <item>\n      <title>%s</title>\n      <link>%s</link>\n      <guid>%s</guid>\n",
+			xmlEscape(art.title), xmlEscape(link), xmlEscape(link))
+		fmt.Fprintf(&items, "      <pubDate>%s</pubDate>\n", art.date.Format(time.RFC1123Z))
+		for _, tag := range art.metadata.Tags {
+			fmt.Fprintf(&items, "      <category>%s</category>\n", xmlEscape(tag))
+		}
+		fmt.Fprintf(&items, "      <description>%s</description>\n    </item>\n", xmlEscape(entry.content))
+	}
+
+	var channel strings.Builder
+	if websubHub() != "" {
+		fmt.Fprintf(&channel, "    <atom:link rel=\"hub\" href=\"%s\"/>\n", xmlEscape(websubHub()))
+	}
+	if !nextPublish.IsZero() {
+		fmt.Fprintf(&channel, "    <sy:updateBase>%s</sy:updateBase>\n", nextPublish.Format(time.RFC3339))
+	}
+
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom" xmlns:sy="http://purl.org/rss/1.0/modules/syndication/">
+  <channel>
+    <title>%s</title>
+    <link>%s</link>
+    <description>%s</description>
+    <language>%s</language>
+    <lastBuildDate>%s</lastBuildDate>
+    <atom:link rel="self" type="application/rss+xml" href="%s"/>
+    <sy:updatePeriod>%s</sy:updatePeriod>
+    <sy:updateFrequency>1</sy:updateFrequency>
+%s%s  </channel>
+</rss>
+`, xmlEscape(f.title), xmlEscape(f.home), xmlEscape(f.title), xmlEscape(f.language),
+		f.updated.Format(time.RFC1123Z), xmlEscape(absoluteUrl(feedFormatUrl(f.url, "rss"), "/")),
+		feedUpdatePeriod(), channel.String(), items.String()))
+}
+
+// jsonFeed is a feed in the JSON Feed 1.1 format.
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageUrl string         `json:"home_page_url"`
+	FeedUrl     string         `json:"feed_url"`
+	Language    string         `json:"language"`
+	Authors     []jsonFeedName `json:"authors"`
+	Hubs        []jsonFeedHub  `json:"hubs,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedName struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedHub struct {
+	Type string `json:"type"`
+	Url  string `json:"url"`
+}
+
+type jsonFeedItem struct {
+	Id            string   `json:"id"`
+	Url           string   `json:"url"`
+	ExternalUrl   string   `json:"external_url,omitempty"`
+	Title         string   `json:"title"`
+	ContentHtml   string   `json:"content_html"`
+	Summary       string   `json:"summary,omitempty"`
+	DatePublished string   `json:"date_published"`
+	DateModified  string   `json:"date_modified"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+func (f feedData) json() ([]byte, error) {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.title,
+		HomePageUrl: f.home,
+		FeedUrl:     absoluteUrl(feedFormatUrl(f.url, "json"), "/"),
+		Language:    f.language,
+		Authors:     []jsonFeedName{{f.author}},
+		Items:       []jsonFeedItem{},
+	}
+	if websubHub() != "" {
+		feed.Hubs = []jsonFeedHub{{"WebSub", websubHub()}}
+	}
+	for _, entry := range f.entries {
+		art := entry.art
+		link := absoluteUrl(art.url, "/")
+		feed.Items = append(feed.Items, jsonFeedItem{
+			Id:            link,
+			Url:           link,
+			ExternalUrl:   art.metadata.CanonicalUrl,
+			Title:         art.title,
+			ContentHtml:   entry.content,
+			Summary:       art.summary,
+			DatePublished: art.date.Format(time.RFC3339),
+			DateModified:  entry.updated.Format(time.RFC3339),
+			Tags:          art.metadata.Tags,
+		})
+	}
+
+	data, err := json.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
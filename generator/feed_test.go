@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteFeedFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CONTENT_PATH", filepath.Join(dir, "content"))
+	t.Setenv("TARGET_PATH", filepath.Join(dir, "site"))
+	t.Setenv("TEMPLATE_PATH", "")
+	saved, savedOwners, savedFeeds := config, outputOwners, writtenFeeds
+	t.Cleanup(func() { config, outputOwners, writtenFeeds = saved, savedOwners, savedFeeds })
+	config = siteConfig{BaseUrl: "https://example.com/"}
+
+	released := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	revised := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		updated  time.Time
+		wantFeed string
+	}{
+		{"revised", revised, "2024-04-01T00:00:00Z"},
+		{"never revised", time.Time{}, "2024-03-05T00:00:00Z"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			outputOwners = make(map[string]string)
+			list := []article{{title: "A", url: "/articles/a/index.html", date: released, updated: test.updated, content: "<p>A</p>"}}
+			if err := writeFeedFile("/feed.xml", "", list); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(filepath.Join(dir, "site", "feed.xml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			feed := string(data)
+			if want := "<updated>" + test.wantFeed + "</updated>"; strings.Count(feed, want) != 2 {
+				t.Errorf("feed and entry should both be updated %s:\n%s", test.wantFeed, feed)
+			}
+			if strings.Contains(feed, "0001-01-01") {
+				t.Errorf("feed has a zero date:\n%s", feed)
+			}
+		})
+	}
+}
+
+func TestFeedHubs(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CONTENT_PATH", filepath.Join(dir, "content"))
+	t.Setenv("TARGET_PATH", filepath.Join(dir, "site"))
+	t.Setenv("TEMPLATE_PATH", "")
+	saved, savedOwners, savedFeeds := config, outputOwners, writtenFeeds
+	t.Cleanup(func() { config, outputOwners, writtenFeeds = saved, savedOwners, savedFeeds })
+	config = siteConfig{BaseUrl: "https://example.com/"}
+	config.Feed.Formats = []string{"rss", "json"}
+	config.Ping.Enabled, config.Ping.Hub = true, "https://hub.example.com/"
+	outputOwners, writtenFeeds = make(map[string]string), nil
+
+	released := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	list := []article{{title: "A", url: "/articles/a/index.html", date: released, updated: released, content: "<p>A</p>"}}
+	if err := writeFeedFile("/feed.xml", "", list); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		feed string
+		hub  string
+		self string
+	}{
+		{"feed.xml", `<link rel="hub" href="https://hub.example.com/"/>`, `<link rel="self" href="https://example.com/feed.xml"/>`},
+		{"feed.rss", `<atom:link rel="hub" href="https://hub.example.com/"/>`, `href="https://example.com/feed.rss"`},
+		{"feed.json", `"type": "WebSub",` + "\n      " + `"url": "https://hub.example.com/"`, `"feed_url": "https://example.com/feed.json"`},
+	}
+
+	for _, test := range tests {
+		data, err := os.ReadFile(filepath.Join(dir, "site", test.feed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), test.hub) || !strings.Contains(string(data), test.self) {
+			t.Errorf("%s does not declare the hub and itself:\n%s", test.feed, data)
+		}
+	}
+	if want := []string{"/feed.xml", "/feed.rss", "/feed.json"}; strings.Join(writtenFeeds, " ") != strings.Join(want, " ") {
+		t.Errorf("feeds for the hub = %v, want %v", writtenFeeds, want)
+	}
+
+	config.Feed.Formats = []string{"yaml"}
+	outputOwners = make(map[string]string)
+	if err := writeFeedFile("/feed.xml", "", list); err == nil || !strings.Contains(err.Error(), "yaml") {
+		t.Errorf("writeFeedFile with an unknown format = %v, want an error naming it", err)
+	}
+}
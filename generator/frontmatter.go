@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+	"gopkg.in/yaml.v3"
+)
+
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+func renderMarkdown(source string) (string, error) {
+	var out bytes.Buffer
+	if err := markdown.Convert([]byte(source), &out); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+// frontMatter is the metadata block at the top of a Hugo or Jekyll source.
+type frontMatter map[string]any
+
+// splitFrontMatter separates YAML (---) or TOML (+++) front matter from the
+// body. Files without front matter return an empty one.
+func splitFrontMatter(text string) (frontMatter, string, error) {
+	text = strings.TrimPrefix(strings.ReplaceAll(text, "\r\n", "\n"), "\ufeff")
+
+	for _, delimiter := range []string{"---", "+++"} {
+		if !strings.HasPrefix(text, delimiter+"\n") {
+			continue
+		}
+
+		rest := text[len(delimiter)+1:]
+		end := strings.Index(rest, "\n"+delimiter)
+		if end == -1 {
+			return nil, "", fmt.Errorf("Front matter is not closed")
+		}
+
+		block := rest[:end]
+		body := strings.TrimPrefix(rest[end+1+len(delimiter):], "\n")
+		matter := make(frontMatter)
+		var err error
+		if delimiter == "---" {
+			err = yaml.Unmarshal([]byte(block), &matter)
+		} else {
+			_, err = toml.Decode(block, &matter)
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("Invalid front matter: %w", err)
+		}
+		return matter, body, nil
+	}
+
+	return frontMatter{}, text, nil
+}
+
+func (matter frontMatter) string(key string) string {
+	value, ok := matter[key]
+	if !ok || value == nil {
+		return ""
+	}
+
+	return strings.TrimSpace(fmt.Sprint(value))
+}
+
+func (matter frontMatter) bool(key string, fallback bool) bool {
+	if value, ok := matter[key].(bool); ok {
+		return value
+	}
+
+	return fallback
+}
+
+// list reads a list, or a single value. With split, a string is split on
+// spaces as Jekyll does for tags and categories.
+func (matter frontMatter) list(key string, split bool) []string {
+	var items []string
+	switch value := matter[key].(type) {
+	case []any:
+		for _, item := range value {
+			if text := strings.TrimSpace(fmt.Sprint(item)); text != "" {
+				items = append(items, text)
+			}
+		}
+	case string:
+		if split {
+			items = strings.Fields(value)
+		} else if strings.TrimSpace(value) != "" {
+			items = []string{strings.TrimSpace(value)}
+		}
+	}
+
+	return items
+}
+
+var frontMatterDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	time.DateOnly,
+}
+
+func (matter frontMatter) date(key string) (time.Time, bool) {
+	if date, ok := matter[key].(time.Time); ok {
+		return date, true
+	}
+
+	text := matter.string(key)
+	for _, layout := range frontMatterDateLayouts {
+		if date, err := time.Parse(layout, text); err == nil {
+			return date, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Words read per minute when neither the config nor the language of a page
+// says otherwise.
+const wordsPerMinute = 200
+
+var (
+	filterArgument = regexp.MustCompile(`"[^"]*"|[^\s"]+`)
+
+	markdownCode   = regexp.MustCompile("`([^`]+)`")
+	markdownStrong = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	markdownEm     = regexp.MustCompile(`\*([^*]+)\*|_([^_]+)_`)
+	markdownLink   = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	markdownHeld   = regexp.MustCompile("\x00([0-9]+)\x00")
+)
+
+// A variableFilter transforms the value of a variable. It returns true when
+// its result is HTML that must not be escaped again. Filters are the
+// functions layouts call, as in {{ page.date | dateFormat "Jan 2" }}, since
+// templates are HTML documents edited with goquery, not html/template ones.
+type variableFilter func(value string, args []string) (string, bool, error)
+
+var variableFilters map[string]variableFilter
+
+func init() {
+	variableFilters = map[string]variableFilter{
+		"dateFormat":    dateFormatFilter,
+		"slugify":       textFilter(slugify),
+		"truncateWords": truncateWordsFilter,
+		"markdownify":   markdownifyFilter,
+		"absURL":        textFilter(func(value string) string { return absoluteUrl(value, "/") }),
+		"where":         whereFilter,
+		"first":         firstFilter,
+		"readingTime":   readingTimeFilter,
+	}
+}
+
+func textFilter(transform func(string) string) variableFilter {
+	return func(value string, args []string) (string, bool, error) {
+		if len(args) != 0 {
+			return "", false, fmt.Errorf("takes no arguments")
+		}
+		return transform(value), false, nil
+	}
+}
+
+// applyFilters runs a variable through a chain such as
+// | dateFormat "Jan 2, 2006" | slugify and returns it ready for the page.
+// The chain is unescaped first, since parsed content spells quotes as
+// entities.
+func applyFilters(value string, chain string) (string, error) {
+	chain = html.UnescapeString(chain)
+	safe := false
+	filters := strings.Split(chain, "|")[1:]
+	for i, filter := range filters {
+		fields := filterArgument.FindAllString(filter, -1)
+		if len(fields) == 0 {
+			return "", fmt.Errorf("empty filter")
+		}
+
+		apply, ok := variableFilters[fields[0]]
+		if !ok {
+			return "", fmt.Errorf("unknown filter %s", fields[0])
+		}
+		if safe {
+			return "", fmt.Errorf("%s cannot follow a filter that returns HTML", fields[0])
+		}
+
+		args := fields[1:]
+		for j, arg := range args {
+			args[j] = strings.Trim(arg, `"`)
+		}
+
+		var err error
+		value, safe, err = apply(value, args)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", fields[0], err)
+		}
+		if safe && i < len(filters)-1 {
+			return "", fmt.Errorf("%s must be the last filter", fields[0])
+		}
+	}
+
+	if safe {
+		return value, nil
+	}
+	return html.EscapeString(value), nil
+}
+
+func dateFormatFilter(value string, args []string) (string, bool, error) {
+	if len(args) != 1 {
+		return "", false, fmt.Errorf("needs a layout")
+	}
+
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return "", false, fmt.Errorf("%q is not a date", value)
+	}
+	return date.Format(args[0]), false, nil
+}
+
+func truncateWordsFilter(value string, args []string) (string, bool, error) {
+	if len(args) != 1 {
+		return "", false, fmt.Errorf("needs a word count")
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		return "", false, fmt.Errorf("invalid word count %q", args[0])
+	}
+
+	words := strings.Fields(value)
+	if len(words) <= n {
+		return value, false, nil
+	}
+	return strings.Join(words[:n], " ") + "…", false, nil
+}
+
+// safeLinkSchemes are the schemes markdownify makes links of. Others, like
+// javascript:, would run in the page, so their links are left as text.
+var safeLinkSchemes = []string{"http", "https", "mailto"}
+
+func isSafeLink(href string) bool {
+	u, err := url.Parse(html.UnescapeString(href))
+	return err == nil && (u.Scheme == "" || slices.Contains(safeLinkSchemes, strings.ToLower(u.Scheme)))
+}
+
+// markdownifyFilter renders the inline Markdown people put into titles and
+// descriptions: code, emphasis and links.
+func markdownifyFilter(value string, args []string) (string, bool, error) {
+	if len(args) != 0 {
+		return "", false, fmt.Errorf("takes no arguments")
+	}
+
+	// Code and link targets are held back while emphasis is applied, so an
+	// underscore in a URL or in code stays as written.
+	var held []string
+	hold := func(span string) string {
+		held = append(held, span)
+		return fmt.Sprintf("\x00%d\x00", len(held)-1)
+	}
+
+	text := html.EscapeString(strings.ReplaceAll(value, "\x00", ""))
+	text = markdownCode.ReplaceAllStringFunc(text, func(code string) string {
+		return hold("<code>" + markdownCode.FindStringSubmatch(code)[1] + "</code>")
+	})
+	text = markdownLink.ReplaceAllStringFunc(text, func(link string) string {
+		parts := markdownLink.FindStringSubmatch(link)
+		if !isSafeLink(parts[2]) {
+			return parts[1]
+		}
+		return hold(fmt.Sprintf(`<a href="%s">`, parts[2])) + parts[1] + hold("</a>")
+	})
+	text = markdownStrong.ReplaceAllString(text, "<strong>$1</strong>")
+	text = markdownEm.ReplaceAllString(text, "<em>$1$2</em>")
+	text = markdownHeld.ReplaceAllStringFunc(text, func(span string) string {
+		n, _ := strconv.Atoi(markdownHeld.FindStringSubmatch(span)[1])
+		return held[n]
+	})
+	return text, true, nil
+}
+
+// listItems splits a comma separated variable such as page.tags.
+func listItems(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// whereFilter keeps the items of a list equal to one of the arguments.
+func whereFilter(value string, args []string) (string, bool, error) {
+	if len(args) == 0 {
+		return "", false, fmt.Errorf("needs a value to match")
+	}
+
+	var kept []string
+	for _, item := range listItems(value) {
+		for _, arg := range args {
+			if item == arg {
+				kept = append(kept, item)
+				break
+			}
+		}
+	}
+	return strings.Join(kept, ", "), false, nil
+}
+
+func firstFilter(value string, args []string) (string, bool, error) {
+	n := 1
+	if len(args) > 1 {
+		return "", false, fmt.Errorf("takes at most one count")
+	}
+	if len(args) == 1 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 0 {
+			return "", false, fmt.Errorf("invalid count %q", args[0])
+		}
+	}
+
+	items := listItems(value)
+	return strings.Join(items[:min(n, len(items))], ", "), false, nil
+}
+
+// readingTimeFilter turns a word count into minutes, or the words of a text
+// when it is not a number.
+func readingTimeFilter(value string, args []string) (string, bool, error) {
+	if len(args) != 0 {
+		return "", false, fmt.Errorf("takes no arguments")
+	}
+
+	words, err := strconv.Atoi(value)
+	if err != nil {
+		words = countTokens(value)
+	}
+	minutes := int(math.Ceil(float64(words) / float64(readingRate(""))))
+	return strconv.Itoa(max(minutes, 1)), false, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestApplyFilters(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.BaseUrl = "https://example.com/"
+
+	tests := []struct {
+		value string
+		chain string
+		want  string
+	}{
+		{"2024-03-05", ` | dateFormat "Jan 2, 2006"`, "Mar 5, 2024"},
+		{"Hello, World!", " | slugify", "hello-world"},
+		{"one two three four", " | truncateWords 2", "one two…"},
+		{"a <b>", "", "a &lt;b&gt;"},
+		{"go, web, rust", ` | where "web" "rust" | first`, "web"},
+		{"400", " | readingTime", "2"},
+		{"**bold** and `code`", " | markdownify", "<strong>bold</strong> and <code>code</code>"},
+		{"[home](/index.html)", " | markdownify", `<a href="/index.html">home</a>`},
+		{"[site](https://example.org/?a=1&b=2)", " | markdownify", `<a href="https://example.org/?a=1&amp;b=2">site</a>`},
+		{"[mail](mailto:me@example.org)", " | markdownify", `<a href="mailto:me@example.org">mail</a>`},
+		{"[a](https://x.org/a_b_c)", " | markdownify", `<a href="https://x.org/a_b_c">a</a>`},
+		{"[*new* post](/a_b_c.html) and _more_", " | markdownify", `<a href="/a_b_c.html"><em>new</em> post</a> and <em>more</em>`},
+		{"`snake_case_name` and **bold**", " | markdownify", "<code>snake_case_name</code> and <strong>bold</strong>"},
+		{"[click](javascript:alert)", " | markdownify", "click"},
+		{"[click](JavaScript:alert)", " | markdownify", "click"},
+		{"[click](data:text/html,hi)", " | markdownify", "click"},
+		{"[click](vbscript:msgbox)", " | markdownify", "click"},
+	}
+
+	for _, test := range tests {
+		got, err := applyFilters(test.value, test.chain)
+		if err != nil || got != test.want {
+			t.Errorf("applyFilters(%q, %q) = %q, %v; want %q", test.value, test.chain, got, err, test.want)
+		}
+	}
+}
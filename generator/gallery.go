@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const galleryThumbnailWidth = 400
+
+var galleryShortcode = regexp.MustCompile(`(?:<p>\s*)?\{\{<\s*gallery((?:\s+\w+="[^"]*")*)\s*>\}\}(?:\s*</p>)?`)
+
+// thumbnails keeps the bytes of every resized image by the content path it
+// would have had, so the lite page and the EPUB can embed them too.
+var thumbnails = make(map[string][]byte)
+
+// decodeImage decodes an image upright, turning it by its Exif orientation
+// unless stripping the metadata has done so already.
+func decodeImage(path string, data []byte) (image.Image, string, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to decode %s: %w", path, err)
+	}
+	if format == "jpeg" && !config.StripImageMetadata {
+		orientation := 1
+		jpegSegments(data, func(marker byte, segment []byte) {
+			if o := exifOrientation(segment); marker == 0xE1 && o != 1 {
+				orientation = o
+			}
+		})
+		img = orientImage(img, orientation)
+	}
+
+	return img, format, nil
+}
+
+// imageThumbnail writes a copy of an image scaled down to width next to it
+// and returns its url with its size. Images already narrower are used as
+// they are.
+func imageThumbnail(path string, width int) (string, image.Point, error) {
+	data, err := readImageFile(path)
+	if err != nil {
+		return "", image.Point{}, err
+	}
+
+	img, format, err := decodeImage(path, data)
+	if err != nil {
+		return "", image.Point{}, err
+	}
+
+	if img.Bounds().Dx() <= width {
+		return urlFromContentPath(path), img.Bounds().Size(), nil
+	}
+
+	thumbnail := fmt.Sprintf("%s-%dw%s", strings.TrimSuffix(path, filepath.Ext(path)), width, filepath.Ext(path))
+	size := scaledSize(img.Bounds(), width)
+	u := urlFromContentPath(thumbnail)
+	if _, ok := thumbnails[thumbnail]; ok {
+		return u, size, nil
+	}
+
+	encoded, err := cachedBlob("thumbnails", imageCacheKey(data, width), func() ([]byte, error) {
+		return encodeImage(downscaleImage(img, width), format, 80)
+	})
+	if err != nil {
+		return "", image.Point{}, fmt.Errorf("Failed to encode %s: %w", path, err)
+	}
+
+	target := targetPathFromUrl(u)
+	if err := recordOutput(target, path); err != nil {
+		return "", image.Point{}, err
+	}
+	if err := createDir(filepath.Dir(target)); err != nil {
+		return "", image.Point{}, err
+	}
+	if err := writeOutputFile(target, encoded); err != nil {
+		return "", image.Point{}, err
+	}
+
+	thumbnails[thumbnail] = encoded
+	return u, size, nil
+}
+
+// galleryCaption turns a file name like my_first-photo.jpg into a caption.
+func galleryCaption(name string) string {
+	caption := strings.TrimSuffix(name, filepath.Ext(name))
+	caption = strings.NewReplacer("-", " ", "_", " ").Replace(caption)
+	if caption == "" {
+		return caption
+	}
+	return strings.ToUpper(caption[:1]) + caption[1:]
+}
+
+// renderGallery builds the thumbnail grid of the images in a directory,
+// relative to the source, in the order of their names. Every thumbnail
+// links to the full image, grouped for lightbox scripts by data-gallery,
+// and is captioned from alt.yaml or else from its file name.
+func renderGallery(args map[string]string, source string) (string, error) {
+	dir := args["dir"]
+	if dir == "" {
+		return "", fmt.Errorf("A gallery needs a dir")
+	}
+	width := galleryThumbnailWidth
+	if args["width"] != "" {
+		var err error
+		if width, err = strconv.Atoi(args["width"]); err != nil || width <= 0 {
+			return "", fmt.Errorf("Invalid gallery width %q", args["width"])
+		}
+	}
+
+	path := filepath.Join(filepath.Dir(source), filepath.FromSlash(dir))
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read gallery %s: %w", dir, err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && isImagePath(entry.Name()) && !isExcludedPath(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	altText, err := readAltText(filepath.Dir(source))
+	if err != nil {
+		return "", err
+	}
+
+	group := strings.Trim(filepath.ToSlash(dir), "/")
+	var gallery strings.Builder
+	fmt.Fprintf(&gallery, `<div class="gallery" data-gallery="%s">`, html.EscapeString(group))
+	for _, name := range names {
+		file := filepath.Join(path, name)
+		u, size, err := imageThumbnail(file, width)
+		if err != nil {
+			return "", err
+		}
+
+		caption := galleryCaption(name)
+		if alt, ok := altText[filepath.ToSlash(filepath.Join(dir, name))]; ok {
+			caption = alt
+		}
+		fmt.Fprintf(&gallery, `<figure class="gallery-item"><a href="%s" data-gallery="%s" data-caption="%s">`+
+			`<img src="%s" alt="%s" width="%d" height="%d" loading="lazy"></a></figure>`,
+			html.EscapeString(urlFromContentPath(file)), html.EscapeString(group), html.EscapeString(caption),
+			html.EscapeString(u), html.EscapeString(caption), size.X, size.Y)
+	}
+	gallery.WriteString("</div>")
+
+	return gallery.String(), nil
+}
+
+// expandGalleries replaces every {{< gallery dir="photos/" >}} with the
+// thumbnail grid of that directory.
+func expandGalleries(text string, source string) (string, error) {
+	var galleryErr error
+	text = galleryShortcode.ReplaceAllStringFunc(text, func(match string) string {
+		if galleryErr != nil {
+			return match
+		}
+
+		args := make(map[string]string)
+		parts := galleryShortcode.FindStringSubmatch(match)
+		for _, arg := range queryArgument.FindAllStringSubmatch(html.UnescapeString(parts[1]), -1) {
+			args[arg[1]] = arg[2]
+		}
+
+		gallery, err := renderGallery(args, source)
+		if err != nil {
+			galleryErr = directiveError(source, text, match, fmt.Errorf("Invalid gallery: %w", err))
+			return match
+		}
+		return gallery
+	})
+
+	return text, galleryErr
+}
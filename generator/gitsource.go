@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// contentRepoConfig builds the content of a Git repository instead of a local
+// directory. Ref is a branch, tag or commit, the default branch when empty;
+// Dir is the content directory inside the repository.
+type contentRepoConfig struct {
+	Url string `json:"url"`
+	Ref string `json:"ref"`
+	Dir string `json:"dir"`
+}
+
+// contentCheckout is the content directory inside the checkout, once the
+// repository has been fetched.
+var contentCheckout string
+
+func isGitUrl(value string) bool {
+	if location, _, _ := strings.Cut(value, "#"); isArchivePath(location) {
+		return false
+	}
+
+	for _, prefix := range []string{"https://", "http://", "ssh://", "git://", "file://", "git@"} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// contentRepository returns the repository to build from: CONTENT_PATH when
+// it holds a URL such as https://github.com/me/blog.git#main:content, with an
+// optional ref after # and directory after :, and content_repo otherwise.
+func contentRepository() (contentRepoConfig, bool) {
+	value := os.Getenv("CONTENT_PATH")
+	if !isGitUrl(value) {
+		return config.ContentRepo, config.ContentRepo.Url != ""
+	}
+
+	var repo contentRepoConfig
+	repo.Url, repo.Ref, _ = strings.Cut(value, "#")
+	repo.Ref, repo.Dir, _ = strings.Cut(repo.Ref, ":")
+	return repo, true
+}
+
+// checkoutDirectory is where the content from source is kept between builds,
+// one copy for every repository, archive or bucket built so far.
+func checkoutDirectory(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return filepath.Join(cacheDirectory(), "checkouts", hex.EncodeToString(sum[:8]))
+}
+
+func runGit(args ...string) error {
+	cmd := exec.Command("git", args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s failed: %w\n%s", strings.Join(args, " "), err, out)
+	}
+
+	return nil
+}
+
+// checkoutContent clones the content repository, or fetches it when it was
+// cloned before, and checks out the configured ref for the build. Offline
+// builds use the checkout the last build left behind.
+func checkoutContent() error {
+	repo, ok := contentRepository()
+	if !ok {
+		return nil
+	}
+
+	dir := checkoutDirectory(repo.Url)
+	if offline && !strings.HasPrefix(repo.Url, "file://") {
+		if !fileExists(filepath.Join(dir, ".git")) {
+			return requireNetwork("content repository", repo.Url)
+		}
+		contentCheckout = filepath.Join(dir, filepath.FromSlash(repo.Dir))
+		return nil
+	}
+
+	if !fileExists(filepath.Join(dir, ".git")) {
+		if err := runGit("clone", "--quiet", "--no-checkout", repo.Url, dir); err != nil {
+			return fmt.Errorf("Cannot clone content repository: %w", err)
+		}
+	}
+
+	ref := repo.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if err := runGit("-C", dir, "fetch", "--quiet", "--force", "origin", ref); err != nil {
+		return fmt.Errorf("Cannot fetch %s of content repository: %w", ref, err)
+	}
+	if err := runGit("-C", dir, "checkout", "--quiet", "--force", "--detach", "FETCH_HEAD"); err != nil {
+		return fmt.Errorf("Cannot check out content repository: %w", err)
+	}
+	if err := runGit("-C", dir, "clean", "--quiet", "-ffdx"); err != nil {
+		return err
+	}
+
+	contentCheckout = filepath.Join(dir, filepath.FromSlash(repo.Dir))
+	return nil
+}
@@ -3,7 +3,17 @@ module site-generator
 go 1.23.1
 
 require (
-	github.com/PuerkitoBio/goquery v1.10.3 // indirect
-	github.com/andybalholm/cascadia v1.3.3 // indirect
-	golang.org/x/net v0.39.0 // indirect
+	github.com/PuerkitoBio/goquery v1.10.3
+	golang.org/x/net v0.39.0
 )
+
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/alecthomas/chroma/v2 v2.14.0
+	github.com/andybalholm/cascadia v1.3.3
+	github.com/niklasfasching/go-org v1.9.1
+	github.com/yuin/goldmark v1.7.8
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require github.com/dlclark/regexp2 v1.11.0 // indirect
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sortArticles orders the articles of a list page by date, updated, title or
+// weight, with a leading - for descending order. The newest come first by
+// default.
+func sortArticles(list []article, order string) error {
+	if order == "" {
+		order = "-date"
+	}
+
+	descending := strings.HasPrefix(order, "-")
+	var less func(a, b article) bool
+	switch strings.TrimPrefix(order, "-") {
+	case "date":
+		less = func(a, b article) bool { return a.date.Before(b.date) }
+	case "updated":
+		less = func(a, b article) bool { return a.updated.Before(b.updated) }
+	case "title":
+		less = func(a, b article) bool { return strings.ToLower(a.title) < strings.ToLower(b.title) }
+	case "weight":
+		less = func(a, b article) bool { return a.metadata.Weight < b.metadata.Weight }
+	default:
+		return fmt.Errorf("Unknown sort order %q", order)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		if descending {
+			return less(list[j], list[i])
+		}
+		return less(list[i], list[j])
+	})
+	return nil
+}
+
+// listGroup is a run of articles of a list page released in the same year
+// or month, rendered under a heading of its own.
+type listGroup struct {
+	title    string
+	datetime string
+	articles []article
+	groups   []listGroup
+}
+
+// groupArticles splits a list sorted by date into years, and those into
+// months when list_groups is "month". Without grouping the whole list is one
+// group without a heading.
+func groupArticles(list []article) ([]listGroup, error) {
+	switch config.ListGroups {
+	case "":
+		return []listGroup{{articles: list}}, nil
+	case "year", "month":
+	default:
+		return nil, fmt.Errorf("Unknown list_groups: %s", config.ListGroups)
+	}
+
+	var years []listGroup
+	for _, art := range list {
+		year := art.date.Format("2006")
+		if len(years) == 0 || years[len(years)-1].datetime != year {
+			years = append(years, listGroup{title: year, datetime: year})
+		}
+		group := &years[len(years)-1]
+		group.articles = append(group.articles, art)
+
+		if config.ListGroups != "month" {
+			continue
+		}
+		month := art.date.Format("2006-01")
+		if len(group.groups) == 0 || group.groups[len(group.groups)-1].datetime != month {
+			group.groups = append(group.groups, listGroup{title: art.date.Format("January"), datetime: month})
+		}
+		group.groups[len(group.groups)-1].articles = append(group.groups[len(group.groups)-1].articles, art)
+	}
+
+	return years, nil
+}
+
+// renderGroup writes the previews of a group under a heading of the given
+// level, or of its subgroups under the next one.
+func renderGroup(out *strings.Builder, group listGroup, level int) error {
+	if group.title != "" {
+		fmt.Fprintf(out, `<section class="article-group"><h%d><time datetime="%s">%s</time></h%d>`+"\n",
+			level, group.datetime, group.title, level)
+	}
+
+	if len(group.groups) > 0 {
+		for _, sub := range group.groups {
+			if err := renderGroup(out, sub, level+1); err != nil {
+				return err
+			}
+		}
+	} else {
+		for _, a := range group.articles {
+			preview, err := articlePreview(a)
+			if err != nil {
+				return err
+			}
+
+			out.WriteString(preview)
+			out.WriteString("\n")
+		}
+	}
+
+	if group.title != "" {
+		out.WriteString("</section>\n")
+	}
+	return nil
+}
+
+// renderListing previews the articles of a list page, grouped by date when
+// list_groups is set and the list is in date order.
+func renderListing(list []article, order string) (string, error) {
+	groups := []listGroup{{articles: list}}
+	if order == "" || strings.TrimPrefix(order, "-") == "date" {
+		var err error
+		if groups, err = groupArticles(list); err != nil {
+			return "", err
+		}
+	}
+
+	var out strings.Builder
+	for _, group := range groups {
+		if err := renderGroup(&out, group, 2); err != nil {
+			return "", err
+		}
+	}
+
+	return out.String(), nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"github.com/PuerkitoBio/goquery"
+)
+
+// headTag is an element a feature puts into the head of a page.
+type headTag struct {
+	key      string
+	selector string
+	html     string
+	first    bool
+}
+
+// pageHead collects what the features add to the head of one page, so they
+// no longer edit it behind each other's backs. A tag replaces an earlier
+// one with the same key, and the template has the last word: a tag is left
+// out when the head already holds an element matching its selector.
+type pageHead struct {
+	tags    []headTag
+	applied int
+}
+
+func (h *pageHead) set(tag headTag) {
+	for i := h.applied; i < len(h.tags); i++ {
+		if h.tags[i].key == tag.key {
+			h.tags[i] = tag
+			return
+		}
+	}
+
+	h.tags = append(h.tags, tag)
+}
+
+// add puts a tag at the end of the head.
+func (h *pageHead) add(key string, selector string, html string) {
+	h.set(headTag{key: key, selector: selector, html: html})
+}
+
+// addFirst puts a tag at the start of the head, for what must come before
+// everything else, like the content security policy.
+func (h *pageHead) addFirst(key string, selector string, html string) {
+	h.set(headTag{key: key, selector: selector, html: html, first: true})
+}
+
+// apply writes the tags added since the last call into the head.
+func (h *pageHead) apply(doc *goquery.Document) {
+	head := doc.Find("head")
+	for _, tag := range h.tags[h.applied:] {
+		if tag.selector != "" && head.Find(tag.selector).Length() > 0 {
+			continue
+		}
+		if tag.first {
+			head.PrependHtml(tag.html)
+		} else {
+			head.AppendHtml(tag.html)
+		}
+	}
+
+	h.applied = len(h.tags)
+}
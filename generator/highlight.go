@@ -0,0 +1,35 @@
+package main
+
+import (
+	"html"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+var highlightFormatter = chromahtml.New(chromahtml.WithClasses(false), chromahtml.PreventSurroundingPre(false))
+
+// highlightCode renders code as a pre block colored for its language. Code
+// in a language chroma does not know is escaped as is.
+func highlightCode(code string, language string) string {
+	plain := `<pre><code class="language-` + html.EscapeString(language) + `">` + html.EscapeString(code) + `</code></pre>`
+
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		return plain
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		return plain
+	}
+
+	var out strings.Builder
+	if err := highlightFormatter.Format(&out, styles.Get("github"), iterator); err != nil {
+		return plain
+	}
+	return out.String()
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	hugoRef       = regexp.MustCompile(`\{\{[<%]\s*(?:rel)?ref\s+"([^"]+)"\s*[>%]\}\}`)
+	hugoShortcode = regexp.MustCompile(`\{\{[<%]\s*/?\s*\w+`)
+)
+
+// hugoSite is the part of a Hugo config the import needs.
+type hugoSite struct {
+	ContentDir string            `toml:"contentDir" yaml:"contentDir"`
+	StaticDir  any               `toml:"staticDir" yaml:"staticDir"`
+	Permalinks map[string]string `toml:"permalinks" yaml:"permalinks"`
+}
+
+func readHugoSite(root string) (hugoSite, error) {
+	site := hugoSite{ContentDir: "content"}
+	for _, name := range []string{"hugo.toml", "hugo.yaml", "hugo.yml", "config.toml", "config.yaml", "config.yml"} {
+		data, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			continue
+		}
+
+		if strings.HasSuffix(name, ".toml") {
+			_, err = toml.Decode(string(data), &site)
+		} else {
+			err = yaml.Unmarshal(data, &site)
+		}
+		if err != nil {
+			return site, fmt.Errorf("Cannot parse Hugo config %s: %w", name, err)
+		}
+		break
+	}
+
+	return site, nil
+}
+
+func (site hugoSite) staticDirs() []string {
+	switch value := site.StaticDir.(type) {
+	case string:
+		return []string{value}
+	case []any:
+		var dirs []string
+		for _, dir := range value {
+			dirs = append(dirs, fmt.Sprint(dir))
+		}
+		return dirs
+	default:
+		return []string{"static"}
+	}
+}
+
+// hugoRefName turns the target of a Hugo ref into the name of the imported
+// article: posts/first.md and posts/first/index.md both become first.
+func hugoRefName(target string) string {
+	target = strings.SplitN(target, "#", 2)[0]
+	target = strings.TrimSuffix(target, path.Ext(target))
+	if path.Base(target) == "index" {
+		target = path.Dir(target)
+	}
+
+	return path.Base(target)
+}
+
+func (imp *importer) importHugoFile(site hugoSite, contentDir string, file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	matter, body, err := splitFrontMatter(string(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	rel, _ := filepath.Rel(contentDir, file)
+	rel = filepath.ToSlash(rel)
+	section := strings.SplitN(rel, "/", 2)[0]
+
+	name := strings.TrimSuffix(path.Base(rel), path.Ext(rel))
+	bundle := name == "index"
+	if bundle {
+		name = path.Base(path.Dir(rel))
+	}
+	slug := matter.string("slug")
+	if slug == "" {
+		slug = name
+	}
+
+	title := matter.string("title")
+	if title == "" {
+		title = name
+	}
+
+	date, ok := matter.date("date")
+	if !ok {
+		if date, ok = matter.date("publishDate"); !ok {
+			fmt.Fprintf(os.Stderr, "Skipping %s: no date\n", file)
+			imp.skipped++
+			return nil
+		}
+	}
+
+	body = hugoRef.ReplaceAllStringFunc(body, func(match string) string {
+		return imp.articleUrl(hugoRefName(hugoRef.FindStringSubmatch(match)[1]))
+	})
+	if hugoShortcode.MatchString(body) {
+		fmt.Fprintf(os.Stderr, "%s uses Hugo shortcodes that need converting by hand\n", file)
+	}
+
+	content := body
+	if ext := path.Ext(rel); ext == ".md" || ext == ".markdown" {
+		if content, err = renderMarkdown(body); err != nil {
+			return fmt.Errorf("Cannot render %s: %w", file, err)
+		}
+	}
+
+	post := importedPost{
+		slug:    slug,
+		title:   title,
+		date:    date,
+		html:    content,
+		tags:    append(matter.list("tags", false), matter.list("categories", false)...),
+		draft:   matter.bool("draft", false),
+		aliases: matter.list("aliases", false),
+	}
+	if series := matter.list("series", false); len(series) > 0 {
+		post.series = series[0]
+	}
+
+	permalink := matter.string("url")
+	if permalink == "" {
+		pattern := site.Permalinks[section]
+		if pattern == "" {
+			pattern = "/:section/:slug/"
+		}
+		values := permalinkValues(date, title, slug)
+		values["section"] = section
+		values["filename"] = name
+		values["contentbasename"] = name
+		permalink = expandPermalink(pattern, values)
+	}
+	post.aliases = append(post.aliases, permalink)
+
+	if bundle {
+		post.resources = make(map[string]string)
+		dir := filepath.Dir(file)
+		err := filepath.WalkDir(dir, func(resource string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || resource == file {
+				return err
+			}
+			if ext := filepath.Ext(resource); ext == ".md" || ext == ".markdown" {
+				return nil
+			}
+			name, _ := filepath.Rel(dir, resource)
+			post.resources[name] = resource
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return imp.writePost(post)
+}
+
+// importHugo imports every post of a Hugo site, and its static files.
+// Pages at the top of the content directory and section lists are left out.
+func (imp *importer) importHugo(root string) error {
+	site, err := readHugoSite(root)
+	if err != nil {
+		return err
+	}
+
+	contentDir := filepath.Join(root, site.ContentDir)
+	var files []string
+	err = filepath.WalkDir(contentDir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		switch filepath.Ext(file) {
+		case ".md", ".markdown", ".html":
+		default:
+			return nil
+		}
+		if !strings.HasPrefix(filepath.Base(file), "_index.") && filepath.Dir(file) != contentDir {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = imp.collectSlugs(files, func(file string) string {
+		rel, _ := filepath.Rel(contentDir, file)
+		return hugoRefName(filepath.ToSlash(rel))
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := imp.importHugoFile(site, contentDir, file); err != nil {
+			return err
+		}
+	}
+
+	for _, dir := range site.staticDirs() {
+		static := filepath.Join(root, dir)
+		if _, err := os.Stat(static); err != nil {
+			continue
+		}
+		if err := imp.copyStaticTree(static, imp.content, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cleanImages remembers the cleaned bytes of every image, since the same
+// file is read for the page, the lite page, the PDF and the EPUB.
+var cleanImages = make(map[string][]byte)
+
+func isImagePath(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	default:
+		return false
+	}
+}
+
+// readImageFile returns the content of an image, with its metadata removed
+// when strip_image_metadata is set.
+func readImageFile(path string) ([]byte, error) {
+	if data, ok := cleanImages[path]; ok {
+		return data, nil
+	}
+	if data, ok := sourceAsset(path); ok {
+		return data, nil
+	}
+	if data, ok := thumbnails[path]; ok {
+		return data, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || !config.StripImageMetadata {
+		return data, err
+	}
+
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		data, err = cleanJpeg(data)
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		data = cleanPng(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cleanImages[path] = data
+	return data, nil
+}
+
+func handleImageFile(path string) error {
+	data, err := readImageFile(path)
+	if err != nil {
+		return fmt.Errorf("Failed to clean image %s: %w", path, err)
+	}
+
+	target := targetPathFromContentPath(path)
+	if err := recordOutput(target, path); err != nil {
+		return err
+	}
+	return writeOutputFile(target, data)
+}
+
+// jpegSegments calls visit with every marker segment before the image data
+// and returns the offset where the image data starts.
+func jpegSegments(data []byte, visit func(marker byte, segment []byte)) int {
+	i := 2
+	for i+4 <= len(data) && data[i] == 0xFF {
+		marker := data[i+1]
+		if marker == 0xDA {
+			return i
+		}
+		if marker == 0x01 || marker >= 0xD0 && marker <= 0xD7 || marker == 0xFF {
+			i += 2
+			continue
+		}
+
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) {
+			return i
+		}
+		visit(marker, data[i:end])
+		i = end
+	}
+
+	return i
+}
+
+// exifOrientation reads the orientation tag from an Exif segment, 1 when
+// there is none.
+func exifOrientation(segment []byte) int {
+	if len(segment) < 18 || string(segment[4:10]) != "Exif\x00\x00" {
+		return 1
+	}
+
+	tiff := segment[10:]
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+
+	return 1
+}
+
+// orientImage turns an image stored with the given Exif orientation into one
+// that displays correctly without it.
+func orientImage(src image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, src.At(bounds.Min.X+sx, bounds.Min.Y+sy))
+		}
+	}
+
+	return dst
+}
+
+// cleanJpeg drops the Exif, XMP, IPTC and comment segments. A rotated photo
+// is re-encoded upright, since the orientation lived in the Exif data.
+func cleanJpeg(data []byte) ([]byte, error) {
+	orientation := 1
+	var cleaned bytes.Buffer
+	cleaned.Write(data[:2])
+
+	start := jpegSegments(data, func(marker byte, segment []byte) {
+		switch marker {
+		case 0xE1:
+			if o := exifOrientation(segment); o != 1 {
+				orientation = o
+			}
+		case 0xED, 0xFE:
+		default:
+			cleaned.Write(segment)
+		}
+	})
+	cleaned.Write(data[start:])
+
+	if orientation == 1 {
+		return cleaned.Bytes(), nil
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, orientImage(img, orientation), &jpeg.Options{Quality: 90}); err != nil {
+		return nil, err
+	}
+	return encoded.Bytes(), nil
+}
+
+// cleanPng drops the Exif and text chunks.
+func cleanPng(data []byte) []byte {
+	var cleaned bytes.Buffer
+	cleaned.Write(data[:8])
+
+	for i := 8; i+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length
+		if end > len(data) {
+			cleaned.Write(data[i:])
+			break
+		}
+
+		switch string(data[i+4 : i+8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			cleaned.Write(data[i:end])
+		}
+		i = end
+	}
+
+	return cleaned.Bytes()
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// importedPost is an article read from another blog, ready to be written as
+// a bundle in the content directory.
+type importedPost struct {
+	slug    string
+	title   string
+	date    time.Time
+	html    string
+	tags    []string
+	series  string
+	draft   bool
+	aliases []string
+	// resources are copied into the article directory, by their name there.
+	resources map[string]string
+}
+
+// importedMetadata is the metadata.json of an imported article. It leaves
+// out what the import does not know.
+type importedMetadata struct {
+	ReleaseDate   string   `json:"release_date"`
+	WordCount     int      `json:"word_count"`
+	EstimatedTime int      `json:"estimated_time"`
+	Tags          []string `json:"tags,omitempty"`
+	Series        string   `json:"series,omitempty"`
+	Draft         bool     `json:"draft,omitempty"`
+	Aliases       []string `json:"aliases,omitempty"`
+}
+
+type importer struct {
+	content  string
+	download bool
+	imported int
+	skipped  int
+	// slugs maps the file names of the posts of the imported site to the
+	// names of their articles, for the links between them.
+	slugs map[string]string
+}
+
+func runImport(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("Usage: import wordpress|hugo|jekyll|medium|substack [--content dir] [--no-images] source")
+	}
+
+	flags := flag.NewFlagSet("import "+args[0], flag.ContinueOnError)
+	content := flags.String("content", os.Getenv("CONTENT_PATH"), "content directory to import into, CONTENT_PATH by default")
+	noImages := flags.Bool("no-images", false, "keep linking to remote images instead of downloading them")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Usage: import %s [--content dir] [--no-images] source", args[0])
+	}
+	if *content == "" {
+		return fmt.Errorf("Set CONTENT_PATH or --content to import into")
+	}
+
+	imp := &importer{content: *content, download: !*noImages, slugs: make(map[string]string)}
+	var err error
+	switch args[0] {
+	case "wordpress":
+		err = imp.importWordpress(flags.Arg(0))
+	case "hugo":
+		err = imp.importHugo(flags.Arg(0))
+	case "jekyll":
+		err = imp.importJekyll(flags.Arg(0))
+	case "medium":
+		err = imp.importMedium(flags.Arg(0))
+	case "substack":
+		err = imp.importSubstack(flags.Arg(0))
+	default:
+		return fmt.Errorf("Unknown import source: %s", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %d articles, skipped %d\n", imp.imported, imp.skipped)
+	return nil
+}
+
+func countWords(html string) int {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return 0
+	}
+
+	return len(strings.Fields(doc.Text()))
+}
+
+// downloadImage saves a remote image into the article directory and returns
+// its new name.
+func downloadImage(src string, dir string, taken map[string]bool) (string, error) {
+	response, err := httpClient.Get(src)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s", response.Status)
+	}
+
+	u, _ := url.Parse(src)
+	name := slugify(strings.TrimSuffix(path.Base(u.Path), path.Ext(u.Path))) + strings.ToLower(path.Ext(u.Path))
+	if name == "" || strings.HasPrefix(name, ".") {
+		name = "image" + name
+	}
+	base, ext := strings.TrimSuffix(name, path.Ext(name)), path.Ext(name)
+	for i := 2; taken[name]; i++ {
+		name = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+	taken[name] = true
+
+	file, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, response.Body); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// localizeImages downloads the remote images of a post next to it. Images
+// that fail to download keep their remote address.
+func (imp *importer) localizeImages(html string, dir string) (string, error) {
+	if !imp.download {
+		return html, nil
+	}
+
+	taken := make(map[string]bool)
+	return modifyHtml(html, func(doc *goquery.Document) {
+		doc.Find("img[src]").Each(func(_ int, img *goquery.Selection) {
+			src, _ := img.Attr("src")
+			if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+				return
+			}
+
+			name, err := downloadImage(src, dir, taken)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Cannot download %s: %s\n", src, err)
+				return
+			}
+			img.SetAttr("src", name)
+			img.RemoveAttr("srcset")
+			img.RemoveAttr("sizes")
+		})
+	})
+}
+
+// writePost writes a post as articles/<slug> with its metadata. Existing
+// articles are never overwritten.
+func (imp *importer) writePost(post importedPost) error {
+	slug := slugify(post.slug)
+	if slug == "" {
+		slug = slugify(post.title)
+	}
+	if slug == "" {
+		return fmt.Errorf("Post %q has no usable name", post.title)
+	}
+
+	dir := filepath.Join(imp.content, "articles", slug)
+	if _, err := os.Stat(dir); err == nil {
+		fmt.Fprintf(os.Stderr, "Skipping %s: %s already exists\n", post.title, dir)
+		imp.skipped++
+		return nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := createDir(dir); err != nil {
+		return err
+	}
+
+	for name, source := range post.resources {
+		if err := copyImportedFile(source, filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+
+	body, err := imp.localizeImages(post.html, dir)
+	if err != nil {
+		return err
+	}
+
+	page := fmt.Sprintf("<h1>%s</h1>\n%s\n", html.EscapeString(post.title), strings.TrimSpace(body))
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte(page), 0644); err != nil {
+		return err
+	}
+
+	words := countWords(body)
+	metadata := importedMetadata{
+		ReleaseDate:   post.date.Format(time.DateOnly),
+		WordCount:     words,
+		EstimatedTime: max(int(math.Ceil(float64(words)/wordsPerMinute)), 1),
+		Tags:          post.tags,
+		Series:        post.series,
+		Draft:         post.draft,
+		Aliases:       post.aliases,
+	}
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "metadata.json"), append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	imp.imported++
+	return nil
+}
+
+func copyImportedFile(source string, target string) error {
+	if err := createDir(filepath.Dir(target)); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(target, data, 0644)
+}
+
+// copyStaticTree copies the static files of another generator into the
+// content directory. Files that already exist there are kept.
+func (imp *importer) copyStaticTree(source string, target string, skip func(path string) bool) error {
+	return filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if skip != nil && path != source && skip(path) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		destination := filepath.Join(target, rel)
+		if _, err := os.Stat(destination); err == nil {
+			fmt.Fprintf(os.Stderr, "Keeping existing %s\n", destination)
+			return nil
+		}
+		return copyImportedFile(path, destination)
+	})
+}
+
+// expandPermalink fills a permalink pattern such as /:year/:month/:title/
+// the way Hugo and Jekyll do.
+func expandPermalink(pattern string, values map[string]string) string {
+	var names []string
+	for name := range values {
+		names = append(names, name)
+	}
+	// Longer names first, so :title is not taken for a prefix of :titles.
+	sort.Slice(names, func(i, j int) bool {
+		return len(names[i]) > len(names[j])
+	})
+
+	for _, name := range names {
+		pattern = strings.ReplaceAll(pattern, ":"+name, values[name])
+	}
+	for strings.Contains(pattern, "//") {
+		pattern = strings.ReplaceAll(pattern, "//", "/")
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		pattern = "/" + pattern
+	}
+
+	return pattern
+}
+
+func permalinkValues(date time.Time, title string, slug string) map[string]string {
+	return map[string]string{
+		"year":            date.Format("2006"),
+		"short_year":      date.Format("06"),
+		"month":           date.Format("01"),
+		"i_month":         date.Format("1"),
+		"day":             date.Format("02"),
+		"i_day":           date.Format("2"),
+		"y_day":           fmt.Sprintf("%03d", date.YearDay()),
+		"title":           slug,
+		"slug":            slug,
+		"slugified_title": slugify(title),
+	}
+}
+
+// collectSlugs reads the slugs the posts set in their front matter, before
+// any is imported, so links to posts later in the import resolve too.
+func (imp *importer) collectSlugs(files []string, name func(file string) string) error {
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		matter, _, err := splitFrontMatter(string(data))
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if slug := matter.string("slug"); slug != "" {
+			imp.slugs[name(file)] = slug
+		}
+	}
+
+	return nil
+}
+
+// articleUrl is where the post with the given file name ends up, used to
+// rewrite the links between posts of the imported site.
+func (imp *importer) articleUrl(name string) string {
+	if slug, ok := imp.slugs[name]; ok {
+		name = slug
+	}
+
+	return "/articles/" + slugify(name) + "/index.html"
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWordpressParagraphs(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"plain text", "one\n\ntwo", "<p>one</p>\n<p>two</p>"},
+		{"line breaks", "one\ntwo", "<p>one<br>\ntwo</p>"},
+		{"block tags kept", "<h2>Title</h2>\n\ntext", "<h2>Title</h2>\n<p>text</p>"},
+		{"block comments dropped", "<!-- wp:paragraph -->\n<p>text</p>\n<!-- /wp:paragraph -->", "<p>text</p>"},
+		{"windows line endings", "one\r\n\r\ntwo", "<p>one</p>\n<p>two</p>"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := wordpressParagraphs(test.content); got != test.want {
+				t.Errorf("wordpressParagraphs(%q) = %q, want %q", test.content, got, test.want)
+			}
+		})
+	}
+}
+
+func TestExpandPermalink(t *testing.T) {
+	values := permalinkValues(time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), "Hello World", "hello")
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"/:year/:month/:day/:title/", "/2024/03/05/hello/"},
+		{":year/:slug", "/2024/hello"},
+		{"/:i_month/:i_day/:slugified_title/", "/3/5/hello-world/"},
+		{"/:short_year/:y_day//:title", "/24/065/hello"},
+	}
+
+	for _, test := range tests {
+		if got := expandPermalink(test.pattern, values); got != test.want {
+			t.Errorf("expandPermalink(%q) = %q, want %q", test.pattern, got, test.want)
+		}
+	}
+}
+
+func TestHugoRefName(t *testing.T) {
+	tests := map[string]string{
+		"posts/first.md":          "first",
+		"posts/first/index.md":    "first",
+		"/posts/first.md#section": "first",
+		"first":                   "first",
+	}
+
+	for target, want := range tests {
+		if got := hugoRefName(target); got != want {
+			t.Errorf("hugoRefName(%q) = %q, want %q", target, got, want)
+		}
+	}
+}
+
+func TestJekyllPostBaseName(t *testing.T) {
+	tests := map[string]string{
+		"_posts/2024-03-05-hello-world.md": "hello-world",
+		"_drafts/hello.markdown":           "hello",
+	}
+
+	for file, want := range tests {
+		if got := jekyllPostBaseName(file); got != want {
+			t.Errorf("jekyllPostBaseName(%q) = %q, want %q", file, got, want)
+		}
+	}
+}
+
+func TestImportWordpress(t *testing.T) {
+	export := `<?xml version="1.0"?>
+<rss xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:wp="http://wordpress.org/export/1.2/">
+<channel>
+<item>
+	<title>Hello World</title>
+	<link>https://example.com/2024/03/hello-world/</link>
+	<content:encoded><![CDATA[First paragraph.
+
+Second paragraph.]]></content:encoded>
+	<wp:post_name>hello-world</wp:post_name>
+	<wp:post_date>2024-03-05 10:00:00</wp:post_date>
+	<wp:status>publish</wp:status>
+	<wp:post_type>post</wp:post_type>
+	<category domain="post_tag">go</category>
+	<category domain="category">Uncategorized</category>
+</item>
+<item>
+	<title>Draft</title>
+	<link>https://example.com/?p=2</link>
+	<content:encoded>Not yet.</content:encoded>
+	<wp:post_name>draft</wp:post_name>
+	<wp:post_date>2024-03-06 10:00:00</wp:post_date>
+	<wp:status>draft</wp:status>
+	<wp:post_type>post</wp:post_type>
+</item>
+<item>
+	<title>Trashed</title>
+	<wp:post_date>2024-03-07 10:00:00</wp:post_date>
+	<wp:status>trash</wp:status>
+	<wp:post_type>post</wp:post_type>
+</item>
+<item>
+	<title>About</title>
+	<wp:post_date>2024-03-07 10:00:00</wp:post_date>
+	<wp:status>publish</wp:status>
+	<wp:post_type>page</wp:post_type>
+</item>
+</channel>
+</rss>`
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "export.xml")
+	if err := os.WriteFile(path, []byte(export), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	content := filepath.Join(dir, "content")
+	imp := &importer{content: content, slugs: make(map[string]string)}
+	if err := imp.importWordpress(path); err != nil {
+		t.Fatal(err)
+	}
+	if imp.imported != 2 {
+		t.Fatalf("imported %d posts, want 2", imp.imported)
+	}
+
+	tests := []struct {
+		slug string
+		want importedMetadata
+	}{
+		{"hello-world", importedMetadata{ReleaseDate: "2024-03-05", WordCount: 4, EstimatedTime: 1, Tags: []string{"go"}, Aliases: []string{"/2024/03/hello-world/"}}},
+		{"draft", importedMetadata{ReleaseDate: "2024-03-06", WordCount: 2, EstimatedTime: 1, Draft: true}},
+	}
+	for _, test := range tests {
+		data, err := os.ReadFile(filepath.Join(content, "articles", test.slug, "metadata.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got importedMetadata
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("metadata of %s = %+v, want %+v", test.slug, got, test.want)
+		}
+	}
+
+	page, err := os.ReadFile(filepath.Join(content, "articles", "hello-world", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), "<h1>Hello World</h1>") || !strings.Contains(string(page), "<p>Second paragraph.</p>") {
+		t.Errorf("unexpected page:\n%s", page)
+	}
+}
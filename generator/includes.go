@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var includeDirective = regexp.MustCompile(`\{\{<\s*include\s+"([^"]+)"\s*>\}\}`)
+
+// fragmentsDirectory holds the content that is only ever included into other
+// pages and is not built on its own.
+func fragmentsDirectory() string {
+	name := config.Fragments
+	if name == "" {
+		name = "snippets"
+	}
+
+	return filepath.Join(contentDirectory(), filepath.FromSlash(name))
+}
+
+func isFragmentPath(path string) bool {
+	return filepath.Clean(path) == fragmentsDirectory()
+}
+
+// expandIncludes replaces every include directive with the named file,
+// resolved against the content directory. Included files may include others;
+// stack holds the chain of files being expanded to catch cycles.
+func expandIncludes(text string, stack []string) (string, error) {
+	var expandErr error
+	text = includeDirective.ReplaceAllStringFunc(text, func(match string) string {
+		if expandErr != nil {
+			return match
+		}
+
+		name := includeDirective.FindStringSubmatch(match)[1]
+		path := filepath.Join(contentDirectory(), filepath.FromSlash(strings.TrimPrefix(name, "/")))
+		if slices.Contains(stack, path) {
+			expandErr = directiveError(stack[len(stack)-1], text, match,
+				fmt.Errorf("Include cycle: %s -> %s", strings.Join(stack, " -> "), path))
+			return match
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			expandErr = directiveError(stack[len(stack)-1], text, match, fmt.Errorf("Cannot include %s: %w", name, err))
+			return match
+		}
+
+		included, err := expandIncludes(string(data), append(stack, path))
+		if err != nil {
+			expandErr = err
+			return match
+		}
+		return included
+	})
+
+	return text, expandErr
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	jekyllPostName  = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)$`)
+	jekyllPostUrl   = regexp.MustCompile(`\{%\s*(?:post_url\s+(?:\S+/)?\d{4}-\d{2}-\d{2}-(\S+?)|link\s+_posts/(?:\S+/)?\d{4}-\d{2}-\d{2}-(\S+?)\.\w+)\s*%\}`)
+	jekyllHighlight = regexp.MustCompile(`(?s)\{%\s*highlight\s+(\w+)[^%]*%\}\n?(.*?)\{%\s*endhighlight\s*%\}`)
+	jekyllBaseUrl   = regexp.MustCompile(`\{\{\s*site\.(?:baseurl|url)\s*\}\}`)
+	jekyllLiquid    = regexp.MustCompile(`\{%|\{\{`)
+)
+
+var jekyllPermalinkStyles = map[string]string{
+	"date":    "/:categories/:year/:month/:day/:title.html",
+	"pretty":  "/:categories/:year/:month/:day/:title/",
+	"ordinal": "/:categories/:year/:y_day/:title.html",
+	"none":    "/:categories/:title.html",
+}
+
+type jekyllSite struct {
+	Permalink string   `yaml:"permalink"`
+	Exclude   []string `yaml:"exclude"`
+}
+
+func readJekyllSite(root string) (jekyllSite, error) {
+	var site jekyllSite
+	data, err := os.ReadFile(filepath.Join(root, "_config.yml"))
+	if err != nil {
+		return site, nil
+	}
+
+	if err := yaml.Unmarshal(data, &site); err != nil {
+		return site, fmt.Errorf("Cannot parse _config.yml: %w", err)
+	}
+	return site, nil
+}
+
+// jekyllLiquidToHtml converts the Liquid tags Jekyll posts commonly use and
+// warns about the rest.
+func (imp *importer) jekyllLiquidToHtml(body string, file string) string {
+	body = jekyllPostUrl.ReplaceAllStringFunc(body, func(match string) string {
+		parts := jekyllPostUrl.FindStringSubmatch(match)
+		return imp.articleUrl(parts[1] + parts[2])
+	})
+	body = jekyllHighlight.ReplaceAllString(body, "\n```$1\n$2```\n")
+	body = jekyllBaseUrl.ReplaceAllString(body, "")
+
+	if jekyllLiquid.MatchString(body) {
+		fmt.Fprintf(os.Stderr, "%s uses Liquid tags that need converting by hand\n", file)
+	}
+	return body
+}
+
+// jekyllPostBaseName returns the name of a post file without its date and
+// extension.
+func jekyllPostBaseName(file string) string {
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	if parts := jekyllPostName.FindStringSubmatch(name); parts != nil {
+		return parts[2]
+	}
+
+	return name
+}
+
+func (imp *importer) importJekyllFile(site jekyllSite, file string, draft bool) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	matter, body, err := splitFrontMatter(string(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	name := jekyllPostBaseName(file)
+	var date time.Time
+	if parts := jekyllPostName.FindStringSubmatch(filepath.Base(file)); parts != nil {
+		date, _ = time.Parse(time.DateOnly, parts[1])
+	}
+	if fromMatter, ok := matter.date("date"); ok {
+		date = fromMatter
+	}
+	if date.IsZero() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		date = info.ModTime()
+	}
+
+	slug := matter.string("slug")
+	if slug == "" {
+		slug = name
+	}
+	title := matter.string("title")
+	if title == "" {
+		title = strings.ReplaceAll(name, "-", " ")
+	}
+
+	body = imp.jekyllLiquidToHtml(body, file)
+	content := body
+	if ext := filepath.Ext(file); ext == ".md" || ext == ".markdown" {
+		if content, err = renderMarkdown(body); err != nil {
+			return fmt.Errorf("Cannot render %s: %w", file, err)
+		}
+	}
+
+	categories := append(matter.list("categories", true), matter.list("category", true)...)
+	post := importedPost{
+		slug:  slug,
+		title: title,
+		date:  date,
+		html:  content,
+		tags:  append(append(matter.list("tags", true), matter.list("tag", true)...), categories...),
+		draft: draft || !matter.bool("published", true),
+	}
+
+	if !draft {
+		permalink := matter.string("permalink")
+		if permalink == "" {
+			permalink = site.Permalink
+		}
+		if style, ok := jekyllPermalinkStyles[permalink]; ok || permalink == "" {
+			if !ok {
+				style = jekyllPermalinkStyles["date"]
+			}
+			permalink = style
+		}
+
+		var slugs []string
+		for _, category := range categories {
+			slugs = append(slugs, slugify(category))
+		}
+		values := permalinkValues(date, title, slug)
+		values["categories"] = strings.Join(slugs, "/")
+		values["output_ext"] = ".html"
+		post.aliases = []string{expandPermalink(permalink, values)}
+	}
+
+	return imp.writePost(post)
+}
+
+// importJekyll imports the posts and drafts of a Jekyll site and copies its
+// static directories. Pages and layouts are left out.
+func (imp *importer) importJekyll(root string) error {
+	site, err := readJekyllSite(root)
+	if err != nil {
+		return err
+	}
+
+	drafts := make(map[string]bool)
+	var files []string
+	for _, dir := range []string{"_posts", "_drafts"} {
+		posts := filepath.Join(root, dir)
+		if _, err := os.Stat(posts); err != nil {
+			continue
+		}
+
+		err := filepath.WalkDir(posts, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			switch filepath.Ext(file) {
+			case ".md", ".markdown", ".html":
+				files = append(files, file)
+				drafts[file] = dir == "_drafts"
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = imp.collectSlugs(files, jekyllPostBaseName)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := imp.importJekyllFile(site, file, drafts[file]); err != nil {
+			return err
+		}
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".") ||
+			name == "node_modules" || name == "vendor" || slices.Contains(site.Exclude, name) {
+			continue
+		}
+
+		err := imp.copyStaticTree(filepath.Join(root, name), filepath.Join(imp.content, name), func(path string) bool {
+			switch filepath.Ext(path) {
+			case ".md", ".markdown", ".html", ".liquid":
+				return true
+			}
+			return strings.HasPrefix(filepath.Base(path), "_") || strings.HasPrefix(filepath.Base(path), ".")
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
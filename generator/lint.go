@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// lintConfig sets the limits lint holds images to: max_image_kb, 1024 by
+// default, and max_image_width when it is set.
+type lintConfig struct {
+	MaxImageKb    int `json:"max_image_kb"`
+	MaxImageWidth int `json:"max_image_width"`
+}
+
+const defaultMaxImageKb = 1024
+
+// lintProblem records a problem found by lint. Unlike the warnings of a
+// build, they fail it.
+func lintProblem(check string, file string, line int, format string, args ...any) {
+	recordDiagnostic(diagnostic{File: file, Line: line, Check: check, Level: "error", Message: fmt.Sprintf(format, args...)})
+}
+
+// lintError records an error at the file and position it points at, or at
+// file when it points nowhere.
+func lintError(check string, file string, err error) {
+	var located *sourceError
+	if !errors.As(err, &located) {
+		lintProblem(check, file, 0, "%s", err)
+		return
+	}
+
+	line := 0
+	if located.position != nil {
+		line = located.position.line
+	}
+	lintProblem(check, located.path, line, "%s", located.err)
+}
+
+// lineOf is the line of the first needle in a file, or 0 when it is not
+// there.
+func lineOf(path string, text string, needle string) int {
+	if position, ok := locate(path, text, needle); ok {
+		return position.line
+	}
+	return 0
+}
+
+// changedContentFiles lists the content files changed in a git revision
+// range, leaving out the deleted ones.
+func changedContentFiles(revisions string) ([]string, error) {
+	cmd := exec.Command("git", "-C", contentDirectory(), "diff", "--name-only", "--relative", "--diff-filter=d", "-z", revisions)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot list the files changed in %s: %w\n%s", revisions, err, stderr.String())
+	}
+
+	var files []string
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" {
+			files = append(files, filepath.Join(contentDirectory(), filepath.FromSlash(name)))
+		}
+	}
+	return files, nil
+}
+
+func allContentFiles() ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(contentDirectory(), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && (isFragmentPath(path) || isRawPath(path)) {
+			return filepath.SkipDir
+		}
+		if isExcludedPath(path) {
+			return skipExcluded(entry)
+		}
+		if !entry.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+
+	return files, err
+}
+
+// builtPath reports whether a content file is built into the site, rather
+// than left out or only included into other pages.
+func builtPath(path string) bool {
+	rel, err := filepath.Rel(contentDirectory(), path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+
+	for dir := path; dir != filepath.Clean(contentDirectory()); dir = filepath.Dir(dir) {
+		if isExcludedPath(dir) || isFragmentPath(dir) || isRawPath(dir) {
+			return false
+		}
+	}
+	return true
+}
+
+// linkTargetExists reports whether a link into the content finds a file, a
+// directory, an asset of a converted source or the page of a source.
+func linkTargetExists(path string) bool {
+	if fileExists(path) {
+		return true
+	}
+	if _, ok := sourceAsset(path); ok {
+		return true
+	}
+	if filepath.Ext(path) == ".html" {
+		for ext := range sourceConverters {
+			if fileExists(strings.TrimSuffix(path, ".html") + ext) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// lintLink checks that a link into the content of the site goes somewhere.
+// Root-relative links are only checked below the directories of the
+// content, since pages generated at the root cannot be told from missing
+// ones.
+func lintLink(source string, link string, images map[string]bool) {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		lintProblem("link", source, lineOf(source, "", link), "Malformed link %s", link)
+		return
+	}
+	if u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return
+	}
+	if strings.HasPrefix(u.Path, "/") {
+		dir, _, nested := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+		if info, err := os.Stat(filepath.Join(contentDirectory(), dir)); !nested || err != nil || !info.IsDir() {
+			return
+		}
+	}
+
+	path, _ := contentPathFromUrl(link, source)
+	if !linkTargetExists(path) {
+		lintProblem("link", source, lineOf(source, "", link), "Link to missing file %s", link)
+		return
+	}
+	if isImagePath(path) {
+		images[path] = true
+	}
+}
+
+func lintArticleMetadata(source string) {
+	path := filepath.Join(filepath.Dir(source), "metadata.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		lintProblem("metadata", source, 0, "Cannot read metadata.json of the article")
+		return
+	}
+
+	var metadata articleInfo
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&metadata); err != nil {
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			lintProblem("metadata", path, lineOf(path, string(data), field), "Unknown field %s", field)
+		} else {
+			lintError("metadata", path, jsonError(path, data, err))
+		}
+		return
+	}
+
+	text := string(data)
+	if _, err := time.Parse(time.DateOnly, metadata.ReleaseDate); err != nil {
+		lintProblem("metadata", path, lineOf(path, text, `"release_date"`), "release_date %q is not a date like 2006-01-02", metadata.ReleaseDate)
+	}
+	for _, entry := range metadata.Changelog {
+		if _, err := time.Parse(time.DateOnly, entry.Date); err != nil {
+			lintProblem("metadata", path, lineOf(path, text, fmt.Sprintf("%q", entry.Date)), "Changelog date %q is not a date like 2006-01-02", entry.Date)
+		}
+	}
+	for _, alias := range metadata.Aliases {
+		if !strings.HasPrefix(alias, "/") {
+			lintProblem("metadata", path, lineOf(path, text, fmt.Sprintf("%q", alias)), "Alias %q does not start with /", alias)
+		}
+	}
+	if metadata.CanonicalUrl != "" {
+		if u, err := url.Parse(metadata.CanonicalUrl); err != nil || u.Host == "" {
+			lintProblem("metadata", path, lineOf(path, text, `"canonical_url"`), "canonical_url %q is not an absolute url", metadata.CanonicalUrl)
+		}
+	}
+	if cover, ok := contentPathFromUrl(metadata.Cover, source); ok && !linkTargetExists(cover) {
+		lintProblem("metadata", path, lineOf(path, text, `"cover"`), "Cover %s does not exist", metadata.Cover)
+	}
+}
+
+// lintSource checks the metadata of a page or article and the links in it.
+// Directives are expanded as in a build, except for galleries, which would
+// write thumbnails.
+func lintSource(path string, images map[string]bool) {
+	if isArticlePath(path) {
+		lintArticleMetadata(path)
+	} else if _, err := getPageMetadata(path); err != nil {
+		lintError("metadata", path, err)
+	}
+
+	text, err := readSourceText(path)
+	for _, expand := range []func(string, string) (string, error){expandQueries, expandRefs, expandDetails} {
+		if err != nil {
+			break
+		}
+		text, err = expand(text, path)
+	}
+	if err != nil {
+		lintError("source", path, err)
+		return
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(text))
+	if err != nil {
+		lintProblem("source", path, 0, "Failed to parse source: %s", err)
+		return
+	}
+	if config.WikiLinks {
+		resolveWikiLinks(doc, path)
+	}
+
+	doc.Find("[href], [src], [poster]").Each(func(_ int, element *goquery.Selection) {
+		for _, name := range []string{"href", "src", "poster"} {
+			if link, ok := element.Attr(name); ok {
+				lintLink(path, link, images)
+			}
+		}
+	})
+	doc.Find("[srcset]").Each(func(_ int, element *goquery.Selection) {
+		srcset, _ := element.Attr("srcset")
+		for _, candidate := range strings.Split(srcset, ",") {
+			if fields := strings.Fields(candidate); len(fields) > 0 {
+				lintLink(path, fields[0], images)
+			}
+		}
+	})
+}
+
+func lintImage(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	limit := config.Lint.MaxImageKb
+	if limit <= 0 {
+		limit = defaultMaxImageKb
+	}
+	if kb := info.Size() / 1024; kb > int64(limit) {
+		lintProblem("image-size", path, 0, "Image is %d KB, over the limit of %d KB", kb, limit)
+	}
+
+	if config.Lint.MaxImageWidth > 0 {
+		file, err := os.Open(path)
+		if err != nil {
+			return
+		}
+		defer file.Close()
+
+		decoded, _, err := image.DecodeConfig(file)
+		if err == nil && decoded.Width > config.Lint.MaxImageWidth {
+			lintProblem("image-size", path, 0, "Image is %d pixels wide, over the limit of %d", decoded.Width, config.Lint.MaxImageWidth)
+		}
+	}
+}
+
+// metadataPage is the source of the page a page metadata file describes.
+func metadataPage(path string) string {
+	for ext := range sourceConverters {
+		if page := strings.TrimSuffix(path, ".json") + ext; fileExists(page) {
+			return page
+		}
+	}
+	return strings.TrimSuffix(path, ".json") + ".html"
+}
+
+// lintFiles checks the sources, metadata and images among files. Metadata
+// is checked through the page or article it belongs to, so a change to it
+// checks the links of its page too.
+func lintFiles(files []string) {
+	sources := make(map[string]bool)
+	images := make(map[string]bool)
+	for _, path := range files {
+		if !builtPath(path) {
+			continue
+		}
+
+		switch {
+		case filepath.Base(path) == "metadata.json" && isArticlePath(directoryIndex(filepath.Dir(path))):
+			sources[directoryIndex(filepath.Dir(path))] = true
+		case isPageMetadataPath(path):
+			sources[metadataPage(path)] = true
+		case isSourcePath(path):
+			sources[path] = true
+		case isImagePath(path):
+			images[path] = true
+		}
+	}
+
+	var list []string
+	for path := range sources {
+		list = append(list, path)
+	}
+	sort.Strings(list)
+	for _, path := range list {
+		lintSource(path, images)
+	}
+	for path := range images {
+		lintImage(path)
+	}
+}
+
+// runLint checks the content, or the content files changed in a revision
+// range, so a pull request can be reviewed with its problems annotated.
+func runLint(args []string) error {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.StringVar(&buildEnvironment, "env", buildEnvironment, "config environment to use")
+	changed := flags.String("changed", "", "git revision range to check the changed content files of, like main...HEAD")
+	format := flags.String("format", "text", "write the problems as text, json, sarif or github")
+	output := flags.String("output", "", "file to write the problems to instead of standard output")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := checkDiagnosticsFormat(*format); err != nil {
+		return err
+	}
+
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	if err := fetchContent(); err != nil {
+		return err
+	}
+
+	// The index only has to be good enough to expand queries with. Lint
+	// finds the problems of the files it cannot read again for the files it
+	// checks.
+	keepGoing = true
+	if err := loadSiteIndex(); err != nil {
+		return err
+	}
+	diagnostics, seenDiagnostic = nil, make(map[diagnostic]bool)
+
+	files, err := allContentFiles()
+	if *changed != "" {
+		files, err = changedContentFiles(*changed)
+	}
+	if err != nil {
+		return err
+	}
+	lintFiles(files)
+
+	if *output == "" {
+		err = writeDiagnostics(os.Stdout, *format)
+	} else {
+		err = reportDiagnostics(*format, *output)
+	}
+	if err != nil {
+		return err
+	}
+
+	if problems := countErrors(); problems > 0 {
+		return fmt.Errorf("Lint found %d problems", problems)
+	}
+	return nil
+}
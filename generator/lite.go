@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+const liteImageWidth = 480
+
+const liteStyle = `body{max-width:40em;margin:0 auto;padding:1em;font-family:serif;line-height:1.5;color:#222;background:#fff}` +
+	`img{max-width:100%;height:auto}pre{white-space:pre-wrap;overflow-wrap:anywhere}.article-info{color:#555}`
+
+// liteImages remembers the downscaled copy of every image so pages sharing an
+// image only encode it once.
+var liteImages = make(map[string]string)
+
+func liteUrl(u string) string {
+	return "/lite" + u
+}
+
+func targetPathFromUrl(u string) string {
+	return filepath.Join(targetDirectory(), filepath.FromSlash(strings.TrimPrefix(u, "/")))
+}
+
+// scaledSize is the size downscaleImage gives an image of the given bounds.
+func scaledSize(bounds image.Rectangle, maxWidth int) image.Point {
+	if bounds.Dx() <= maxWidth {
+		return bounds.Size()
+	}
+
+	return image.Pt(maxWidth, max(1, bounds.Dy()*maxWidth/bounds.Dx()))
+}
+
+func downscaleImage(src image.Image, maxWidth int) image.Image {
+	bounds := src.Bounds()
+	if bounds.Dx() <= maxWidth {
+		return src
+	}
+
+	size := scaledSize(bounds, maxWidth)
+	width, height := size.X, size.Y
+	dst := image.NewRGBA64(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*bounds.Dy()/height)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(x0+1, bounds.Min.X+(x+1)*bounds.Dx()/width)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(b / n), uint16(a / n)})
+		}
+	}
+
+	return dst
+}
+
+// encodeImage encodes a JPEG at quality and anything else as PNG.
+func encodeImage(img image.Image, format string, quality int) ([]byte, error) {
+	var encoded bytes.Buffer
+	var err error
+	if format == "jpeg" {
+		err = jpeg.Encode(&encoded, img, &jpeg.Options{Quality: quality})
+	} else {
+		err = png.Encode(&encoded, img)
+	}
+
+	return encoded.Bytes(), err
+}
+
+// imageCacheKey names a resized image by the bytes of the original.
+func imageCacheKey(data []byte, width int) string {
+	return fmt.Sprintf("%x %d", sha256.Sum256(data), width)
+}
+
+func liteImage(path string) (string, error) {
+	if u, ok := liteImages[path]; ok {
+		return u, nil
+	}
+
+	data, err := readImageFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	// Formats the standard library cannot decode are linked as they are.
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		liteImages[path] = urlFromContentPath(path)
+		return liteImages[path], nil
+	}
+
+	u := liteUrl(urlFromContentPath(path))
+	target := targetPathFromUrl(u)
+	if err := createDir(filepath.Dir(target)); err != nil {
+		return "", err
+	}
+
+	encoded, err := cachedBlob("lite", imageCacheKey(data, liteImageWidth), func() ([]byte, error) {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return encodeImage(downscaleImage(img, liteImageWidth), format, 60)
+	})
+	if err != nil {
+		return "", fmt.Errorf("Failed to encode %s: %w", path, err)
+	}
+
+	if err := recordOutput(target, path); err != nil {
+		return "", err
+	}
+	if err := writeOutputFile(target, encoded); err != nil {
+		return "", err
+	}
+
+	liteImages[path] = u
+	return u, nil
+}
+
+// liteLink points local links at the lite variant of an article when there is
+// one and at the full site otherwise.
+func liteLink(href string, page string) (string, bool) {
+	path, ok := contentPathFromUrl(href, page)
+	if !ok {
+		return "", false
+	}
+	if filepath.Ext(path) == "" {
+		path = filepath.Join(path, "index.html")
+	}
+
+	link := urlFromContentPath(path)
+	if isArticlePath(path) {
+		link = liteUrl(link)
+	}
+	if u, err := url.Parse(href); err == nil && u.Fragment != "" {
+		link += "#" + u.Fragment
+	}
+
+	return link, true
+}
+
+func writeLitePage(art article) error {
+	tmplDoc, err := templateDocument()
+	if err != nil {
+		return err
+	}
+
+	var imageErr error
+	content, err := modifyHtml(art.content, func(doc *goquery.Document) {
+		doc.Find("script, style, noscript, link, object, embed").Remove()
+
+		doc.Find("iframe, video, audio").Each(func(_ int, media *goquery.Selection) {
+			src, _ := media.Attr("src")
+			if link, ok := liteLink(src, art.source); ok {
+				src = link
+			}
+			media.ReplaceWithHtml(fmt.Sprintf(`<p><a href="%s">Embedded media</a></p>`, html.EscapeString(src)))
+		})
+
+		removeEventHandlers(doc)
+
+		doc.Find("img").Each(func(_ int, img *goquery.Selection) {
+			img.RemoveAttr("srcset")
+			src, _ := img.Attr("src")
+			path, ok := contentPathFromUrl(src, art.source)
+			if !ok {
+				alt, _ := img.Attr("alt")
+				if alt == "" {
+					alt = "image"
+				}
+				img.ReplaceWithHtml(fmt.Sprintf(`<a href="%s">[%s]</a>`, html.EscapeString(src), html.EscapeString(alt)))
+				return
+			}
+
+			u, err := liteImage(path)
+			if err != nil {
+				imageErr = fmt.Errorf("%s in %s: %w", elementSelector(img), art.source, err)
+				return
+			}
+			img.SetAttr("src", u)
+			img.SetAttr("loading", "lazy")
+		})
+
+		doc.Find("a[href]").Each(func(_ int, link *goquery.Selection) {
+			href, _ := link.Attr("href")
+			if rewritten, ok := liteLink(href, art.source); ok {
+				link.SetAttr("href", rewritten)
+			}
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if imageErr != nil {
+		return imageErr
+	}
+
+	// The critical rules of the site are what it looks like before its
+	// stylesheets arrive, which is all the lite page gets.
+	style := liteStyle
+	if config.CriticalCss != "" {
+		if style, err = loadCriticalCss(); err != nil {
+			return err
+		}
+	}
+
+	lang := art.lang
+	if lang == "" {
+		lang = siteLanguage(tmplDoc)
+	}
+	attributes := fmt.Sprintf(`lang="%s"`, html.EscapeString(lang))
+	if art.dir != "" {
+		attributes += fmt.Sprintf(` dir="%s"`, html.EscapeString(art.dir))
+	}
+
+	page := fmt.Sprintf(`<!DOCTYPE html>
+<html %s>
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<style>%s</style>
+</head>
+<body>
+<p><a href="%s">Full version</a></p>
+<main><article>%s</article></main>
+</body>
+</html>
+`, attributes, html.EscapeString(art.title), style, html.EscapeString(art.url), content)
+
+	target := targetPathFromUrl(liteUrl(art.url))
+	if err := createDir(filepath.Dir(target)); err != nil {
+		return err
+	}
+
+	if err := recordOutput(target, art.source); err != nil {
+		return err
+	}
+	return writeOutputFile(target, []byte(page))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteLitePage(t *testing.T) {
+	tests := []struct {
+		name     string
+		critical string
+		art      article
+		want     []string
+	}{
+		{
+			name: "site language",
+			art:  article{title: "Hello", url: "/articles/hello/index.html", content: "<p>Hi</p>"},
+			want: []string{`<html lang="en">`, "<style>" + liteStyle + "</style>", `<a href="/articles/hello/index.html">Full version</a>`},
+		},
+		{
+			name: "language and direction of the article",
+			art:  article{title: "Salam", url: "/articles/salam/index.html", content: "<p>Salam</p>", lang: "fa", dir: "rtl"},
+			want: []string{`<html lang="fa" dir="rtl">`},
+		},
+		{
+			name:     "critical CSS of the site",
+			critical: "body{color:red}",
+			art:      article{title: "Red", url: "/articles/red/index.html", content: "<p>Red</p>"},
+			want:     []string{"<style>body{color:red}</style>"},
+		},
+		{
+			name: "url escaped",
+			art:  article{title: "Quote", url: `/articles/a"b/index.html`, content: "<p>Quote</p>"},
+			want: []string{`<a href="/articles/a&#34;b/index.html">Full version</a>`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("CONTENT_PATH", filepath.Join(dir, "content"))
+			t.Setenv("TARGET_PATH", filepath.Join(dir, "site"))
+			t.Setenv("CONFIG_PATH", filepath.Join(dir, "config.json"))
+			t.Setenv("TEMPLATE_PATH", "")
+			saved, savedCss := config, criticalCss
+			t.Cleanup(func() { config, criticalCss = saved, savedCss })
+			criticalCss = nil
+
+			if test.critical != "" {
+				writeTestFiles(t, dir, map[string]string{"critical.css": test.critical})
+				config.CriticalCss = "critical.css"
+			}
+
+			if err := writeLitePage(test.art); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(targetPathFromUrl(liteUrl(test.art.url)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("lite page lacks %s:\n%s", want, data)
+				}
+			}
+		})
+	}
+}
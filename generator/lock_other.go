@@ -0,0 +1,57 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// errLocked is returned by lockFile when another process holds the lock.
+var errLocked = errors.New("locked")
+
+// staleLock reports whether the process that wrote a lock file is gone.
+// Finding a process fails on these systems when it does not exist.
+func staleLock(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return false
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return true
+	}
+	process.Release()
+	return false
+}
+
+// lockFile creates the file at path, which must not exist yet, with the id
+// of the process in it. Without advisory locks, a file left by a process
+// that was killed is taken over once the process is gone.
+func lockFile(path string) (func(), error) {
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if errors.Is(err, fs.ErrExist) {
+			if staleLock(path) && os.Remove(path) == nil {
+				continue
+			}
+			return nil, errLocked
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(file, "%d\n", os.Getpid())
+		file.Close()
+		return func() { os.Remove(path) }, nil
+	}
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"html"
 	"io"
 	"io/fs"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
@@ -14,16 +18,45 @@ import (
 	"github.com/PuerkitoBio/goquery"
 )
 
+// articleInfo is the metadata.json of an article. Of its flags, noindex
+// keeps the article out of search engines and out of the sitemap,
+// exclude_from_feed out of the feeds and exclude_from_home off the home page.
 type articleInfo struct {
-	ReleaseDate   string `json:"release_date"`
-	WordCount     int    `json:"word_count"`
-	EstimatedTime int    `json:"estimated_time"`
+	Title         string           `json:"title"`
+	ReleaseDate   string           `json:"release_date"`
+	WordCount     int              `json:"word_count"`
+	EstimatedTime int              `json:"estimated_time"`
+	Tags          []string         `json:"tags"`
+	Series        string           `json:"series"`
+	Discussions   []string         `json:"discussions"`
+	Draft         bool             `json:"draft"`
+	NoAnalytics   bool             `json:"no_analytics"`
+	NoComments    bool             `json:"no_comments"`
+	Changelog     []changelogEntry `json:"changelog"`
+	Audio         *audioInfo       `json:"audio"`
+	Aliases       []string         `json:"aliases"`
+	CanonicalUrl  string           `json:"canonical_url"`
+	Noindex       bool             `json:"noindex"`
+	ExcludeFeed   bool             `json:"exclude_from_feed"`
+	ExcludeHome   bool             `json:"exclude_from_home"`
+	Template      string           `json:"template"`
+	Weight        int              `json:"weight"`
+	Summary       string           `json:"summary"`
+	Cover         string           `json:"cover"`
 }
 
 type article struct {
-	date time.Time
-	content string
-	url string
+	date     time.Time
+	updated  time.Time
+	content  string
+	url      string
+	lang     string
+	dir      string
+	title    string
+	source   string
+	hash     string
+	summary  string
+	metadata articleInfo
 }
 
 var articles []article
@@ -47,6 +80,10 @@ func deleteDirIfExists(path string) error {
 }
 
 func contentDirectory() string {
+	if contentCheckout != "" {
+		return contentCheckout
+	}
+
 	path := os.Getenv("CONTENT_PATH")
 	if path == "" {
 		panic("CONTENT_PATH is not set")
@@ -55,32 +92,64 @@ func contentDirectory() string {
 	return path
 }
 
-func targetDirectory() string {
+// outputDirectory is the configured target. While a build runs, its files
+// go to the staging directory instead.
+func outputDirectory() string {
 	path := os.Getenv("TARGET_PATH")
 	if path == "" {
 		panic("TARGET_PATH is not set")
 	}
+	if previewBranch != "" {
+		path = filepath.Join(path, filepath.FromSlash(previewDirectory()))
+	}
 
-	return path
+	return filepath.Clean(path)
 }
 
-func templatePath() string {
-	path := os.Getenv("TEMPLATE_PATH")
-	if path == "" {
-		panic("TEMPLATE_PATH is not set")
+func targetDirectory() string {
+	if stagingDirectory != "" {
+		return stagingDirectory
 	}
 
-	return path
+	return outputDirectory()
 }
 
+// templatePath is the template of the site, empty for the built-in one.
+func templatePath() string {
+	return os.Getenv("TEMPLATE_PATH")
+}
+
+// targetPathFromContentPath maps a file of the content directory to its place
+// in the target. Paths are compared with filepath, never as strings, so the
+// mapping holds with either kind of separator.
 func targetPathFromContentPath(path string) string {
-	targetDir := targetDirectory()
-	contentDir := contentDirectory()
+	rel, err := filepath.Rel(contentDirectory(), pagePath(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
 
-	if after, ok := strings.CutPrefix(path, contentDir); ok {
-		return targetDir + after
+	return filepath.Join(targetDirectory(), filepath.FromSlash(sanitizeUrlPath(filepath.ToSlash(rel))))
+}
+
+func urlFromContentPath(path string) string {
+	rel, err := filepath.Rel(contentDirectory(), pagePath(path))
+	if err != nil {
+		return path
 	}
-	return path
+
+	return sanitizeUrlPath("/" + filepath.ToSlash(rel))
+}
+
+func contentPathFromUrl(link string, page string) (string, bool) {
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return "", false
+	}
+
+	if strings.HasPrefix(u.Path, "/") {
+		return filepath.Join(contentDirectory(), filepath.FromSlash(u.Path)), true
+	}
+	return filepath.Join(filepath.Dir(page), filepath.FromSlash(u.Path)), true
 }
 
 func handleDirectory(path string) error {
@@ -91,121 +160,514 @@ func getArticleMetadata(path string) (articleInfo, error) {
 	var metadata articleInfo
 
 	metadataPath := filepath.Join(path, "metadata.json")
-	file, err := os.Open(metadataPath)
+	data, err := os.ReadFile(metadataPath)
 	if err != nil {
 		return metadata, fmt.Errorf("Cannot read metadata file for article: %s",
 			metadataPath)
 	}
-	defer file.Close()
 
-	decoder := json.NewDecoder(file)
+	decoder := json.NewDecoder(bytes.NewReader(data))
 	if err := decoder.Decode(&metadata); err != nil {
-		return metadata, fmt.Errorf("Cannot decode metadata: %s", err)
+		return metadata, fmt.Errorf("Cannot decode metadata: %w", jsonError(metadataPath, data, err))
 	}
 
 	return metadata, nil
 }
 
-func addMetadataToArticle(metadata articleInfo, html string) string {
-	metadataText := fmt.Sprintf("%s • %d words • %d minutes",
+func addMetadataToArticle(metadata articleInfo, content string) string {
+	metadataText := fmt.Sprintf(`<time datetime="%s">%s</time> • %d words • %d minutes`,
+		metadata.ReleaseDate,
 		metadata.ReleaseDate,
 		metadata.WordCount,
 		metadata.EstimatedTime)
+	if metadata.CanonicalUrl != "" {
+		metadataText += fmt.Sprintf(` • Originally published at <a class="original-link" href="%s">%s</a>`,
+			html.EscapeString(metadata.CanonicalUrl), html.EscapeString(canonicalSite(metadata.CanonicalUrl)))
+	}
 	metadataTag := "<div class=\"article-info\"><p>" + metadataText + "</p></div>"
-	return metadataTag + html
+	return metadataTag + content
 }
 
-func convertArticlePathToUrl(path string) string {
-	const marker = "/articles/"
-	i := strings.Index(path, marker)
-	if i == -1 {
-		return path
+// isArticlePath matches articles/<name>/index.html, or an index in another
+// source format, in the content directory.
+func isArticlePath(path string) bool {
+	rel, err := filepath.Rel(contentDirectory(), path)
+	if err != nil {
+		return false
 	}
 
-	return path[i:]
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	return len(parts) == 3 && parts[0] == "articles" && strings.TrimSuffix(parts[2], filepath.Ext(parts[2])) == "index" &&
+		isSourcePath(path)
 }
 
-func handleHtmlFile(path string) error {
-	tmplFile, err := os.Open(templatePath())
+// readSourceText returns a source file as HTML with its includes expanded.
+func readSourceText(path string) (string, error) {
+	source, err := convertSource(path)
+	if err != nil {
+		return "", err
+	}
+
+	return expandIncludes(source.html, []string{path})
+}
+
+func readSourceHtml(path string) (string, error) {
+	text, err := readSourceText(path)
 	if err != nil {
-		return fmt.Errorf("Failed to open template: %w", err)
+		return "", err
 	}
-	defer tmplFile.Close()
 
-	tmplDoc, err := goquery.NewDocumentFromReader(tmplFile)
+	text, err = expandQueries(text, path)
 	if err != nil {
-		return fmt.Errorf("Failed to parse template: %w", err)
+		return "", err
 	}
 
-	srcFile, err := os.Open(path)
+	text, err = expandRefs(text, path)
 	if err != nil {
-		return fmt.Errorf("Failed to open source: %w", err)
+		return "", err
 	}
-	defer srcFile.Close()
 
-	srcDoc, err := goquery.NewDocumentFromReader(srcFile)
+	text, err = expandDetails(text, path)
 	if err != nil {
-		return fmt.Errorf("Failed to parse source: %w", err)
+		return "", err
+	}
+
+	text, err = expandGalleries(text, path)
+	if err != nil {
+		return "", err
+	}
+
+	srcDoc, err := goquery.NewDocumentFromReader(strings.NewReader(text))
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse source: %w", err)
+	}
+
+	if config.WikiLinks {
+		resolveWikiLinks(srcDoc, path)
 	}
 
 	html, err := srcDoc.Find("body").Html()
 	if err != nil || html == "" {
 		html, err = srcDoc.Html()
 		if err != nil {
-			return fmt.Errorf("Failed to extract HTML: %w", err)
+			return "", fmt.Errorf("Failed to extract HTML: %w", err)
+		}
+	}
+
+	return html, nil
+}
+
+// articleTitle is the title in the metadata, else the first h1 of the
+// article, else the name of its directory.
+func articleTitle(metadata articleInfo, html string, path string) string {
+	if metadata.Title != "" {
+		return metadata.Title
+	}
+	if title := headingTitle(html); title != "" {
+		return title
+	}
+
+	return humanizeName(filepath.Base(filepath.Dir(path)))
+}
+
+func loadArticle(path string, html string) (article, error) {
+	metadata, err := getArticleMetadata(filepath.Dir(path))
+	if err != nil {
+		return article{}, fmt.Errorf("Cannot add metadata: %s", err)
+	}
+
+	metadata.Tags = normalizeTags(metadata.Tags)
+	lang, dir := sourceLanguage(path)
+	if config.WordCount.Enabled {
+		if metadata.WordCount, metadata.EstimatedTime, err = measureArticle(html, lang); err != nil {
+			return article{}, err
 		}
 	}
 
-	if strings.Contains(path, "articles") && filepath.Base(path) == "index.html" {
-		metadata, err := getArticleMetadata(filepath.Dir(path))
+	releaseDate, err := time.Parse("2006-01-02", metadata.ReleaseDate)
+	if err != nil {
+		return article{}, fmt.Errorf("Invalid date found in %s: %s", path, metadata.ReleaseDate)
+	}
+
+	updated, err := lastUpdated(releaseDate, metadata.Changelog, path)
+	if err != nil {
+		return article{}, err
+	}
+
+	art := article{
+		url:      urlFromContentPath(path),
+		date:     releaseDate,
+		updated:  updated,
+		title:    articleTitle(metadata, html, path),
+		source:   path,
+		metadata: metadata,
+	}
+	art.lang, art.dir = lang, dir
+
+	html = withHeading(html, art.title)
+
+	html, err = substituteVariables(html, articleVariables(art), path)
+	if err != nil {
+		return article{}, err
+	}
+
+	art.content = addMetadataToArticle(metadata, html)
+	if art.summary, err = articleSummary(art); err != nil {
+		return article{}, err
+	}
+	return art, nil
+}
+
+func modifyHtml(html string, modify func(doc *goquery.Document)) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse HTML: %w", err)
+	}
+
+	modify(doc)
+
+	return doc.Find("body").Html()
+}
+
+func addArticleInfoLink(art *article, label string, href string) error {
+	content, err := modifyHtml(art.content, func(doc *goquery.Document) {
+		doc.Find(".article-info p").First().AppendHtml(
+			fmt.Sprintf(` • <a href="%s">%s</a>`, href, label))
+	})
+	if err != nil {
+		return err
+	}
+
+	art.content = content
+	return nil
+}
+
+func handleArticle(path string, html string) (article, string, error) {
+	art, err := loadArticle(path, html)
+	if err != nil {
+		return art, "", err
+	}
+
+	if err := applyAltText(&art); err != nil {
+		return art, "", err
+	}
+
+	if config.Stats.Enabled {
+		if err := collectReadability(art); err != nil {
+			return art, "", err
+		}
+	}
+
+	if config.Duplicates.Enabled {
+		if err := collectSignature(art); err != nil {
+			return art, "", err
+		}
+	}
+
+	if config.Archive.Enabled {
+		if err := archiveArticleLinks(&art); err != nil {
+			return art, "", err
+		}
+	}
+
+	if err := addAudioPlayer(&art); err != nil {
+		return art, "", err
+	}
+
+	if len(art.metadata.Changelog) > 0 {
+		art.content += revisionHistorySection(art.metadata.Changelog)
+	}
+
+	if config.Backlinks && len(backlinks[path]) > 0 {
+		art.content += backlinksSection(backlinks[path])
+	}
+
+	if len(art.metadata.Discussions) > 0 {
+		art.content += discussionsSection(art.metadata.Discussions)
+	}
+
+	if config.Checksums.Enabled {
+		art.hash = contentHash(art.content)
+	}
+
+	if config.Pdf {
+		written, err := writeArticlePdf(art)
 		if err != nil {
-			return fmt.Errorf("Cannot add metadata: %s", err)
+			return art, "", fmt.Errorf("Failed to render PDF: %w", err)
+		}
+		if written {
+			if err := addArticleInfoLink(&art, "PDF", pdfUrl(art)); err != nil {
+				return art, "", err
+			}
 		}
+	}
+
+	if config.Lite {
+		if err := writeLitePage(art); err != nil {
+			return art, "", fmt.Errorf("Failed to render lite page: %w", err)
+		}
+		if err := addArticleInfoLink(&art, "Lite", liteUrl(art.url)); err != nil {
+			return art, "", err
+		}
+	}
+
+	if config.Json {
+		if err := writeArticleJson(art); err != nil {
+			return art, "", fmt.Errorf("Failed to write article JSON: %w", err)
+		}
+	}
+
+	articles = append(articles, art)
+
+	if config.Print.Enabled {
+		page, err := addPrintMarkup(art.content)
+		return art, page, err
+	}
+	return art, art.content, nil
+}
+
+// finalizePage runs the steps that need the complete page, after the content
+// has been placed into the template, and writes the head the features put
+// together.
+func finalizePage(doc *goquery.Document, head *pageHead, url string, metadata *articleInfo) error {
+	// Reader modes look for the main landmark to find the content.
+	if doc.Find("main").Length() == 0 {
+		doc.Find("#content").SetAttr("role", "main")
+	}
 
-		html = addMetadataToArticle(metadata, html)
+	if config.SanitizePaths.Enabled {
+		sanitizeLinks(doc)
+	}
+
+	if len(menuItems) > 0 {
+		injectMenu(doc, url)
+	}
+
+	if config.Noindex {
+		head.add("robots", `meta[name="robots"]`, `<meta name="robots" content="noindex, nofollow">`)
+	} else if metadata != nil && metadata.Noindex {
+		head.add("robots", `meta[name="robots"]`, `<meta name="robots" content="noindex">`)
+	}
+
+	addCanonicalLink(head, url, metadata)
+
+	if config.Feed.Enabled {
+		head.add("alternate "+feedUrl, fmt.Sprintf(`link[rel="alternate"][href="%s"]`, feedUrl),
+			fmt.Sprintf(`<link rel="alternate" type="application/atom+xml" href="%s">`, feedUrl))
+		for _, format := range config.Feed.Formats {
+			if mediaType, ok := feedMediaTypes[format]; ok {
+				u := feedFormatUrl(feedUrl, format)
+				head.add("alternate "+u, fmt.Sprintf(`link[rel="alternate"][href="%s"]`, u),
+					fmt.Sprintf(`<link rel="alternate" type="%s" href="%s">`, mediaType, u))
+			}
+		}
+	}
+
+	if config.Validate.Enabled {
+		validateIds(doc, url)
+		validateAltText(doc, url)
+	}
+
+	if err := injectAnalytics(head, url, metadata); err != nil {
+		return err
+	}
 
-		releaseDate, err := time.Parse("2006-01-02", metadata.ReleaseDate)
+	if config.CriticalCss != "" {
+		if err := inlineCriticalCss(doc, head); err != nil {
+			return err
+		}
+	}
+
+	head.apply(doc)
+
+	if config.Sri.Enabled {
+		applySri(doc)
+	}
+
+	if config.Csp.Enabled {
+		if err := applyCsp(doc, head, url); err != nil {
+			return err
+		}
+		head.apply(doc)
+	}
+
+	return nil
+}
+
+func isDraft(path string) bool {
+	if !isArticlePath(path) {
+		return false
+	}
+
+	metadata, err := getArticleMetadata(filepath.Dir(path))
+	return err == nil && metadata.Draft
+}
+
+func handleHtmlFile(path string) error {
+	if (isDraft(path) || isHeldBack(path)) && !config.Drafts {
+		return nil
+	}
+
+	if config.Validate.Enabled && filepath.Ext(path) == ".html" {
+		if err := validateSource(path); err != nil {
+			return err
+		}
+	}
+
+	html, err := readSourceHtml(path)
+	if err != nil {
+		return err
+	}
+
+	url := urlFromContentPath(path)
+	var art article
+	var metadata *articleInfo
+	var variables map[string]string
+	var lang, dir string
+	template := templatePath()
+	if isArticlePath(path) {
+		art, html, err = handleArticle(path, html)
 		if err != nil {
-			return fmt.Errorf("Invalid date found in %s: %s", path, metadata.ReleaseDate)
+			return err
+		}
+		html = "<article>" + html + "</article>"
+		if template, err = pageTemplatePath(art.metadata.Template); err != nil {
+			return err
 		}
-		art := article{
-			content: html,
-			url: convertArticlePathToUrl(path),
-			date: releaseDate,
+		metadata = &art.metadata
+		variables = articleVariables(art)
+		lang, dir = art.lang, art.dir
+		if art.metadata.CanonicalUrl == "" && !art.metadata.Noindex {
+			sitemapPages[url] = variables["updated"]
+		}
+	} else {
+		info, err := getPageMetadata(path)
+		if err != nil {
+			return err
+		}
+		if template, err = pageTemplatePath(info.Template); err != nil {
+			return err
+		}
+
+		title, err := pageTitle(path)
+		if err != nil {
+			return err
+		}
+		variables = pageVariables(url, title)
+		sitemapPages[url] = ""
+
+		html, err = substituteVariables(html, variables, path)
+		if err != nil {
+			return err
 		}
-		articles = append(articles, art)
 	}
 
+	tmplDoc, err := templateFileWithVariables(template, variables)
+	if err != nil {
+		return err
+	}
+
+	head := &pageHead{}
 	tmplDoc.Find("#content").SetHtml(html)
+	if config.Video.Enabled {
+		if err := processVideos(tmplDoc.Find("#content"), path); err != nil {
+			return err
+		}
+	}
+
+	if metadata != nil {
+		resolveBundleLinks(tmplDoc.Find("#content"), path)
+		if config.Microformats.Enabled {
+			markEntry(tmplDoc.Find("#content > article"))
+		}
+		if lang != "" {
+			tmplDoc.Find("#content").SetAttr("lang", lang)
+		}
+		if dir != "" {
+			tmplDoc.Find("#content").SetAttr("dir", dir)
+		}
+		if err := injectComments(tmplDoc, art); err != nil {
+			return err
+		}
+		if config.ShortUrls.Enabled {
+			if err := addShortUrl(head, art); err != nil {
+				return err
+			}
+		}
+		addSummaryTags(head, art)
+		if config.CardImage.Enabled {
+			if err := addCardImageTags(head, art); err != nil {
+				return err
+			}
+		}
+		if art.hash != "" {
+			head.add("content-hash", `meta[name="content-hash"]`, fmt.Sprintf(`<meta name="content-hash" content="%s">`, art.hash))
+		}
+	}
+
+	if err := finalizePage(tmplDoc, head, url, metadata); err != nil {
+		return err
+	}
 
 	final, err := tmplDoc.Html()
 	if err != nil {
-		return fmt.Errorf("Failed to serialize HTML: %w", err)
+		return &sourceError{path: path, err: fmt.Errorf("Failed to serialize HTML: %w", err)}
 	}
 
-	err = os.WriteFile(targetPathFromContentPath(path), []byte(final), 0644)
+	if err := recordOutput(targetPathFromContentPath(path), path); err != nil {
+		return err
+	}
+
+	err = writeOutputFile(targetPathFromContentPath(path), []byte(final))
 	if err != nil {
 		return fmt.Errorf("Failed to write output: %w", err)
 	}
 
+	if err := writeSourceAssets(path); err != nil {
+		return err
+	}
+
+	if metadata != nil {
+		for _, alias := range metadata.Aliases {
+			if err := writeAliasRedirect(alias, url); err != nil {
+				return err
+			}
+		}
+	}
+
+	if config.SanitizePaths.Enabled {
+		return writePathRedirect(path)
+	}
+
 	return nil
 }
 
 func handleNormalFile(path string) error {
+	if reuseAsset(path) {
+		return nil
+	}
+	if config.StripImageMetadata && isImagePath(path) {
+		return handleImageFile(path)
+	}
+
+	return copyFile(path, targetPathFromContentPath(path))
+}
+
+func copyFile(path string, target string) error {
 	src, err := os.Open(path)
 	if err != nil {
 		return err
 	}
 	defer src.Close()
 
-	dst, err := os.Create(targetPathFromContentPath(path))
-	if err != nil {
+	if err := recordOutput(target, path); err != nil {
 		return err
 	}
-	defer dst.Close()
 
-	_, err = io.Copy(dst, src)
-	return err
+	return writeOutputWith(target, func(dst *os.File) error {
+		_, err := io.Copy(dst, src)
+		return err
+	})
 }
 
 func contentFileHandler(path string, entry fs.DirEntry, err error) error {
@@ -214,63 +676,166 @@ func contentFileHandler(path string, entry fs.DirEntry, err error) error {
 	}
 
 	if entry.IsDir() {
+		if isFragmentPath(path) {
+			return filepath.SkipDir
+		}
+		if isRawPath(path) {
+			if err := copyRawDirectory(path); err != nil {
+				return err
+			}
+			return filepath.SkipDir
+		}
+		if isExcludedPath(path) {
+			return filepath.SkipDir
+		}
 		return handleDirectory(path)
 	}
+	if isExcludedPath(path) {
+		return nil
+	}
 
-	if filepath.Ext(path) == ".html" {
+	if isSourcePath(path) {
+		raw, err := isRawPage(path)
+		if err != nil {
+			return err
+		}
+		if raw {
+			return copyFile(path, rawTarget(path))
+		}
 		return handleHtmlFile(path)
 	}
+	if isPageMetadataPath(path) || isAltTextPath(path) {
+		return nil
+	}
 	return handleNormalFile(path)
 }
 
+// buildContentFile builds a file of the content walk. A file that fails is
+// skipped, along with everything in it for a directory, unless the build
+// stops at the first failure.
+func buildContentFile(path string, entry fs.DirEntry, err error) error {
+	handlerErr := contentFileHandler(path, entry, err)
+	if handlerErr == nil || handlerErr == filepath.SkipDir {
+		return handlerErr
+	}
+	if err := skipFailure(path, handlerErr); err != nil {
+		return err
+	}
+	if entry.IsDir() {
+		return filepath.SkipDir
+	}
+	return nil
+}
+
 func generateHomePage() error {
 	sort.Slice(articles, func(i, j int) bool {
 		return articles[i].date.After(articles[j].date)
 	})
 
-	var previews strings.Builder
-	for _, a := range articles {
-		doc, err := goquery.NewDocumentFromReader(strings.NewReader(a.content))
-		if err != nil {
-			panic(err)
-		}
+	home := listPage{
+		url:      "/index.html",
+		title:    config.Site["title"],
+		articles: homeArticles(),
+		order:    config.HomeSort,
+		offset:   config.HomeOffset,
+		limit:    config.HomeLimit,
+		owner:    "the home page",
+	}
+	if config.OnThisDay.Enabled {
+		home.lead = renderOnThisDay()
+	}
+	if config.HomeLimit <= 0 {
+		return generateListPage(home)
+	}
 
-		doc.Find("h1").Each(func(i int, h1 *goquery.Selection) {
-			titleText := h1.Text() 
-			h1.SetHtml(fmt.Sprintf(`<a class="article-title-link" href="%s">%s</a>`, a.url, titleText))
-		})
+	// The home page only shows the latest posts, so all of them go to an
+	// archive page it links to.
+	archive := listPage{
+		url:      config.ArchiveUrl,
+		title:    "All posts",
+		articles: home.articles,
+		order:    config.HomeSort,
+		owner:    "the archive page",
+	}
+	if archive.url == "" {
+		archive.url = "/archive/index.html"
+	}
+	home.more = archive.url
 
-		modifiedHTML, err := doc.Html()
-		if err != nil {
-			panic(err)
-		}
+	if err := generateListPage(home); err != nil {
+		return err
+	}
+	return generateListPage(archive)
+}
+
+// listPage is a page previewing articles in the given order, after the lead.
+// With a limit it shows that many of them, starting at offset, and links to
+// more for the rest. Feed is the feed of the articles, when they have one.
+type listPage struct {
+	url      string
+	title    string
+	lead     string
+	articles []article
+	order    string
+	offset   int
+	limit    int
+	more     string
+	feed     string
+	owner    string
+}
+
+func generateListPage(page listPage) error {
+	u, title, feed := page.url, page.title, page.feed
 
-		previews.WriteString(fmt.Sprintf(
-			`<div class="article-preview">%s</div>`,
-			modifiedHTML,
-		))
-		previews.WriteString("\n")	
+	list := append([]article(nil), page.articles...)
+	if err := sortArticles(list, page.order); err != nil {
+		return err
+	}
+	list = list[min(max(page.offset, 0), len(list)):]
+	truncated := page.limit > 0 && page.limit < len(list)
+	if truncated {
+		list = list[:page.limit]
 	}
 
-	tmplFile, err := os.Open(templatePath())
+	previews, err := renderListing(list, page.order)
 	if err != nil {
 		return err
 	}
-	defer tmplFile.Close()
+	if truncated && page.more != "" {
+		previews += fmt.Sprintf(`<p class="all-posts"><a href="%s">All posts &rarr;</a></p>`, html.EscapeString(page.more))
+	}
 
-	tmpl, err := goquery.NewDocumentFromReader(tmplFile)
+	tmpl, err := templateWithVariables(pageVariables(u, title))
 	if err != nil {
-		return fmt.Errorf("Failed to parse template: %w", err)
+		return err
 	}
 
-	tmpl.Find("#content").SetHtml(previews.String())
+	head := &pageHead{}
+	tmpl.Find("#content").SetHtml(page.lead + previews)
+	if feed != "" && config.Feed.Enabled {
+		head.add("alternate "+feed, fmt.Sprintf(`link[rel="alternate"][href="%s"]`, feed),
+			fmt.Sprintf(`<link rel="alternate" type="application/atom+xml" title="%s" href="%s">`, html.EscapeString(title), feed))
+	}
+
+	if err := finalizePage(tmpl, head, u, nil); err != nil {
+		return err
+	}
 
 	final, err := tmpl.Html()
 	if err != nil {
-		return fmt.Errorf("Failed to serialize HTML: %w", err)
+		return fmt.Errorf("Failed to serialize HTML of %s: %w", u, err)
+	}
+
+	target := targetPathFromUrl(u)
+	if err := claimOutput(target, page.owner); err != nil {
+		return err
+	}
+	if err := createDir(filepath.Dir(target)); err != nil {
+		return err
 	}
+	sitemapPages[u] = buildTime.UTC().Format("2006-01-02")
 
-	err = os.WriteFile(filepath.Join(targetDirectory(), "index.html"), []byte(final), 0644)
+	err = writeOutputFile(target, []byte(final))
 	if err != nil {
 		return fmt.Errorf("Failed to write output: %w", err)
 	}
@@ -278,17 +843,360 @@ func generateHomePage() error {
 	return nil
 }
 
-func main() {
-	if err := deleteDirIfExists(targetDirectory()); err != nil {
+func build(args []string) {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	flags.StringVar(&buildEnvironment, "env", buildEnvironment, "config environment to build for")
+	strict := flags.Bool("strict", false, "validate the pages and fail on any problem")
+	output := flags.String("output", "dir", "write the site to a directory, or a zip, tar or tar.gz archive")
+	flags.BoolVar(&offline, "offline", false, "build from caches only and fail when the network is needed")
+	profile := flags.String("profile", "", "record a cpu, mem or trace profile of the build")
+	profileFile := flags.String("profile-file", "", "file to write the profile to, a temporary file by default")
+	format := flags.String("format", "text", "write diagnostics as text, json, sarif or github")
+	diagnosticsPath := flags.String("diagnostics", "", "file to write diagnostics to instead of standard error")
+	failFast := flags.Bool("fail-fast", false, "stop at the first source file that fails instead of building the rest")
+	flags.StringVar(&previewBranch, "preview", "", "build a preview of a branch into previews/<branch>/, kept out of search engines")
+	flags.BoolVar(&incremental, "incremental", false, "reuse the assets of the previous build that did not change")
+	flags.Parse(args)
+	keepGoing = !*failFast
+
+	if err := checkDiagnosticsFormat(*format); err != nil {
 		panic(err)
 	}
 
-	if err := filepath.WalkDir(contentDirectory(), contentFileHandler); err != nil {
+	stopProfile, err := startProfile(*profile, *profileFile)
+	if err != nil {
 		panic(err)
 	}
+	defer func() {
+		if err := stopProfile(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write profile: %s\n", err)
+		}
+	}()
+
+	sink, err := newOutputSink(*output)
+	if err != nil {
+		panic(err)
+	}
+	siteSink = sink
+
+	if err := loadConfig(); err != nil {
+		panic(err)
+	}
+	if err := fetchContent(); err != nil {
+		panic(err)
+	}
+	if err := applyPreview(); err != nil {
+		panic(err)
+	}
+	if *strict {
+		config.Validate.Enabled = true
+		config.Validate.Strict = true
+	}
+
+	if err := checkTemplate(); err != nil {
+		panic(err)
+	}
+
+	if err := checkSections(); err != nil {
+		panic(err)
+	}
+
+	if err := loadMenu(); err != nil {
+		panic(err)
+	}
+
+	unlock, err := lockTarget()
+	if err != nil {
+		panic(err)
+	}
+	defer unlock()
+
+	if err := stageTarget(); err != nil {
+		panic(err)
+	}
+	defer deleteDirIfExists(stagingDirectory)
+
+	if err := loadBuildState(); err != nil {
+		panic(err)
+	}
+
+	if err := loadSiteIndex(); err != nil {
+		panic(err)
+	}
+
+	if config.Backlinks {
+		if err := loadBacklinks(); err != nil {
+			panic(err)
+		}
+	}
+
+	if err := filepath.WalkDir(contentDirectory(), buildContentFile); err != nil {
+		panic(err)
+	}
+
+	if templatePath() == "" {
+		if err := writeDefaultStylesheet(); err != nil {
+			panic(err)
+		}
+	}
 
 	if err := generateHomePage(); err != nil {
 		panic(err)
 	}
+
+	if err := generateSectionPages(); err != nil {
+		panic(err)
+	}
+
+	if config.Feed.Enabled {
+		if err := writeFeed(); err != nil {
+			panic(err)
+		}
+	}
+
+	if config.Json {
+		if err := writeArticlesJson(); err != nil {
+			panic(err)
+		}
+	}
+
+	if config.Random.Enabled {
+		if err := writeRandomPage(); err != nil {
+			panic(err)
+		}
+	}
+
+	if config.TagCloud.Enabled {
+		if err := writeTagCloud(); err != nil {
+			panic(err)
+		}
+	}
+
+	if config.Podcast.Enabled {
+		if err := writePodcastFeed(); err != nil {
+			panic(err)
+		}
+	}
+
+	if config.Blogroll.Enabled {
+		if err := writeBlogroll(); err != nil {
+			panic(err)
+		}
+	}
+
+	if config.Sitemap && !config.Noindex {
+		if err := writeSitemap(); err != nil {
+			panic(err)
+		}
+	}
+
+	if err := writeHeadersFile(); err != nil {
+		panic(err)
+	}
+
+	if config.Noindex {
+		robots := []byte("User-agent: *\nDisallow: /\n")
+		target := filepath.Join(targetDirectory(), "robots.txt")
+		if err := claimOutput(target, "the noindex robots.txt"); err != nil {
+			panic(err)
+		}
+		if err := writeOutputFile(target, robots); err != nil {
+			panic(err)
+		}
+	}
+
+	reportTagMerges()
+
+	if config.Duplicates.Enabled {
+		reportDuplicates()
+	}
+
+	if config.Stats.Enabled {
+		if err := reportReadability(); err != nil {
+			panic(err)
+		}
+	}
+
+	if config.CssReport.Enabled {
+		if err := reportUnusedCss(); err != nil {
+			panic(err)
+		}
+	}
+
+	if config.Budget.Enabled {
+		if err := checkBudget(); err != nil {
+			panic(err)
+		}
+	}
+
+	if config.Orphans.Report || config.Orphans.Exclude {
+		if err := reportOrphanedAssets(); err != nil {
+			panic(err)
+		}
+	}
+
+	if err := reportDiagnostics(*format, *diagnosticsPath); err != nil {
+		panic(err)
+	}
+	if err := reportValidation(); err != nil {
+		panic(err)
+	}
+
+	if err := rewritePreviewLinks(); err != nil {
+		panic(err)
+	}
+
+	// Pings go out once the site is in place, but an offline build has to
+	// know before that whether it can send them.
+	if offline && config.Ping.Enabled && !config.Ping.Publish {
+		requireNetwork("update pings", "the ping endpoints")
+	}
+	if err := checkOffline(); err != nil {
+		panic(err)
+	}
+
+	if config.Checksums.Enabled {
+		if err := writeChecksums(); err != nil {
+			panic(err)
+		}
+	}
+
+	if err := writeManifest(); err != nil {
+		panic(err)
+	}
+
+	if !nextPublish.IsZero() {
+		fmt.Printf("Next scheduled article: %s\n", nextPublish.Format("2006-01-02"))
+	}
+
+	if err := promoteTarget(); err != nil {
+		panic(err)
+	}
+
+	// A site built past failed sources is put in place without them, but is
+	// not announced.
+	if config.Ping.Enabled && !config.Ping.Publish && len(failedSources) == 0 {
+		announceUpdates(writtenFeeds)
+	}
+
+	if err := saveBuildState(); err != nil {
+		panic(err)
+	}
+
+	if err := saveCommentCounts(); err != nil {
+		panic(err)
+	}
+
+	if err := saveSriHashes(); err != nil {
+		panic(err)
+	}
+
+	if err := saveConvertedHtml(); err != nil {
+		panic(err)
+	}
+
+	if err := saveBlogrollFeeds(); err != nil {
+		panic(err)
+	}
+
+	if err := saveArchivedLinks(); err != nil {
+		panic(err)
+	}
+
+	if err := saveShortCodes(); err != nil {
+		panic(err)
+	}
+
+	if err := evictCache(); err != nil {
+		panic(err)
+	}
+
+	if err := checkFailures(); err != nil {
+		panic(err)
+	}
 }
 
+func main() {
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") {
+		build(os.Args[1:])
+		return
+	}
+
+	switch os.Args[1] {
+	case "build":
+		build(os.Args[2:])
+	case "export":
+		if err := runExport(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "import":
+		if err := runImport(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "publish":
+		if err := runPublish(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "daemon":
+		if err := runDaemon(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "cache":
+		if err := runCache(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "report":
+		if err := runReport(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "diff":
+		if err := runDiff(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "lint":
+		if err := runLint(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "meta":
+		if err := runMeta(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "mv":
+		if err := runMv(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "tags":
+		if err := runTags(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "benchmark":
+		if err := runBenchmark(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "version":
+		if err := runVersion(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "self-update":
+		if err := runSelfUpdate(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", os.Args[1])
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type manifestEntry struct {
+	Path         string `json:"path"`
+	Hash         string `json:"hash"`
+	Size         int64  `json:"size"`
+	Source       string `json:"source,omitempty"`
+	Type         string `json:"type"`
+	CacheControl string `json:"cache_control"`
+}
+
+// outputSources maps every output file to the content file it was built
+// from. Files generated from the whole site have no entry.
+var outputSources = make(map[string]string)
+
+func recordOutput(target string, source string) error {
+	if err := claimOutput(target, source); err != nil {
+		return err
+	}
+
+	outputSources[target] = source
+	return nil
+}
+
+func outputType(path string) string {
+	switch filepath.Ext(path) {
+	case ".html":
+		return "page"
+	case ".xml", ".rss", ".atom":
+		return "feed"
+	default:
+		return "asset"
+	}
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// cacheControl suggests a Cache-Control header for an output file. Pages
+// and feeds expire by the next scheduled article.
+func cacheControl(path string) string {
+	if outputType(path) == "asset" {
+		return "public, max-age=86400"
+	}
+
+	return fmt.Sprintf("public, max-age=%d", pageCacheSeconds())
+}
+
+// writeManifest lists every file of the finished build for deploy tooling.
+func writeManifest() error {
+	manifestPath := filepath.Join(targetDirectory(), "manifest.json")
+
+	entries := []manifestEntry{}
+	err := filepath.WalkDir(targetDirectory(), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || path == manifestPath {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(targetDirectory(), path)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, manifestEntry{
+			Path:         "/" + filepath.ToSlash(rel),
+			Hash:         hash,
+			Size:         info.Size(),
+			Source:       filepath.ToSlash(outputSources[path]),
+			Type:         outputType(path),
+			CacheControl: cacheControl(path),
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	manifest := map[string]any{"built_at": buildTime.UTC().Format(time.RFC3339), "files": entries}
+	if !nextPublish.IsZero() {
+		manifest["next_publish"] = nextPublish.Format(time.RFC3339)
+	}
+	if len(shortUrls) > 0 {
+		manifest["short_urls"] = shortUrls
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeOutputFile(manifestPath, data)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// markdownConfig turns on the GitHub flavored extensions for Markdown
+// sources. Without them, sources are read as plain CommonMark.
+type markdownConfig struct {
+	Tables          bool `json:"tables"`
+	TaskLists       bool `json:"task_lists"`
+	Strikethrough   bool `json:"strikethrough"`
+	Autolinks       bool `json:"autolinks"`
+	DefinitionLists bool `json:"definition_lists"`
+}
+
+var (
+	sourceMarkdown       goldmark.Markdown
+	shortcode            = regexp.MustCompile(`\{\{<.*?>\}\}`)
+	shortcodePlaceholder = regexp.MustCompile(`shortcode-placeholder-(\d+)`)
+)
+
+// convertMarkdown renders a Markdown source with the extensions the config
+// enables. Imported posts and notebooks keep the full GitHub dialect they
+// were written in.
+func convertMarkdown(path string, data []byte) (convertedSource, error) {
+	if sourceMarkdown == nil {
+		var extensions []goldmark.Extender
+		for _, enabled := range []struct {
+			on        bool
+			extension goldmark.Extender
+		}{
+			{config.Markdown.Tables, extension.Table},
+			{config.Markdown.TaskLists, extension.TaskList},
+			{config.Markdown.Strikethrough, extension.Strikethrough},
+			{config.Markdown.Autolinks, extension.Linkify},
+			{config.Markdown.DefinitionLists, extension.DefinitionList},
+		} {
+			if enabled.on {
+				extensions = append(extensions, enabled.extension)
+			}
+		}
+
+		sourceMarkdown = goldmark.New(
+			goldmark.WithExtensions(extensions...),
+			goldmark.WithRendererOptions(html.WithUnsafe()),
+		)
+	}
+
+	// Shortcodes are set aside while rendering, or their brackets would be
+	// escaped as text.
+	var shortcodes [][]byte
+	data = shortcode.ReplaceAllFunc(data, func(match []byte) []byte {
+		shortcodes = append(shortcodes, match)
+		return []byte(fmt.Sprintf("shortcode-placeholder-%d", len(shortcodes)-1))
+	})
+
+	var out bytes.Buffer
+	if err := sourceMarkdown.Convert(data, &out); err != nil {
+		return convertedSource{}, err
+	}
+
+	rendered := shortcodePlaceholder.ReplaceAllStringFunc(out.String(), func(match string) string {
+		i, _ := strconv.Atoi(shortcodePlaceholder.FindStringSubmatch(match)[1])
+		return string(shortcodes[i])
+	})
+	return convertedSource{html: rendered}, nil
+}
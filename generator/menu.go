@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type menuItem struct {
+	Label   string `json:"label"`
+	Url     string `json:"url"`
+	Weight  int    `json:"weight"`
+	Section string `json:"section"`
+}
+
+var menuItems []menuItem
+
+func pageTitle(path string) (string, error) {
+	if isArticlePath(path) {
+		metadata, err := getArticleMetadata(filepath.Dir(path))
+		if err == nil && metadata.Title != "" {
+			return metadata.Title, nil
+		}
+	} else {
+		metadata, err := getPageMetadata(path)
+		if err != nil {
+			return "", err
+		}
+		if metadata.Title != "" {
+			return metadata.Title, nil
+		}
+	}
+
+	// Queries and refs are left alone, the site index is built from titles.
+	source, err := readSourceText(path)
+	if err != nil {
+		return "", err
+	}
+
+	if title := headingTitle(source); title != "" {
+		return title, nil
+	}
+
+	name := filepath.Base(path)
+	if strings.TrimSuffix(name, filepath.Ext(name)) == "index" {
+		name = filepath.Base(filepath.Dir(path))
+	}
+	return humanizeName(strings.TrimSuffix(name, filepath.Ext(name))), nil
+}
+
+// headingTitle is the text of the first h1 of a page, empty when it has none.
+func headingTitle(source string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(source))
+	if err != nil {
+		return ""
+	}
+
+	return strings.Join(strings.Fields(doc.Find("h1").First().Text()), " ")
+}
+
+// withHeading puts the title at the start of an article without a heading,
+// so it shows wherever the content does.
+func withHeading(content string, title string) string {
+	if headingTitle(content) != "" {
+		return content
+	}
+
+	return "<h1>" + html.EscapeString(title) + "</h1>\n" + content
+}
+
+// humanizeName turns a file or directory name like "my-first_post" into a
+// title like "My first post".
+func humanizeName(name string) string {
+	name = strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_' || unicode.IsSpace(r)
+	}), " ")
+	if name == "" {
+		return name
+	}
+
+	first, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(first)) + name[size:]
+}
+
+// sectionMenuItems lists the pages of a content directory, one item each.
+func sectionMenuItems(item menuItem) ([]menuItem, error) {
+	var items []menuItem
+	root := filepath.Join(contentDirectory(), filepath.FromSlash(item.Section))
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if isExcludedPath(path) {
+			return skipExcluded(entry)
+		}
+		if entry.IsDir() || !isSourcePath(path) {
+			return nil
+		}
+
+		title, err := pageTitle(path)
+		if err != nil {
+			return err
+		}
+		items = append(items, menuItem{Label: title, Url: urlFromContentPath(path), Weight: item.Weight})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Cannot populate menu section %s: %w", item.Section, err)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Label < items[j].Label
+	})
+
+	return items, nil
+}
+
+func loadMenu() error {
+	menuItems = nil
+	for _, item := range config.Menu {
+		if item.Section == "" {
+			menuItems = append(menuItems, item)
+			continue
+		}
+
+		items, err := sectionMenuItems(item)
+		if err != nil {
+			return err
+		}
+		menuItems = append(menuItems, items...)
+	}
+
+	items, err := pageMenuItems()
+	if err != nil {
+		return err
+	}
+	menuItems = append(menuItems, items...)
+
+	sort.SliceStable(menuItems, func(i, j int) bool {
+		return menuItems[i].Weight < menuItems[j].Weight
+	})
+
+	return nil
+}
+
+func menuItemActive(item string, page string) bool {
+	item = strings.TrimSuffix(item, "index.html")
+	page = strings.TrimSuffix(page, "index.html")
+	if item == page {
+		return true
+	}
+
+	return strings.HasSuffix(item, "/") && item != "/" && strings.HasPrefix(page, item)
+}
+
+func injectMenu(doc *goquery.Document, url string) {
+	var links strings.Builder
+	for _, item := range menuItems {
+		active := ""
+		if menuItemActive(item.Url, url) {
+			active = ` class="active" aria-current="page"`
+		}
+		fmt.Fprintf(&links, `<a href="%s"%s>%s</a>`+"\n", html.EscapeString(item.Url), active, html.EscapeString(item.Label))
+	}
+
+	doc.Find("#nav").SetHtml(links.String())
+}
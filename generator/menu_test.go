@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestPageMenu(t *testing.T) {
+	saved, savedItems := config, menuItems
+	t.Cleanup(func() { config, menuItems = saved, savedItems })
+	config = siteConfig{}
+
+	const template = `<html><head><title></title></head><body><nav id="nav"><a href="/index.html">Recent</a></nav><main id="content"></main></body></html>`
+
+	tests := []struct {
+		name  string
+		items []menuItem
+		want  string
+	}{
+		{"template links without a menu", nil, `<a href="/index.html">Recent</a>`},
+		{"configured menu", []menuItem{{Label: "Posts", Url: "/posts/"}, {Label: "About", Url: "/about.html"}},
+			`<a href="/posts/" class="active" aria-current="page">Posts</a>` + "\n" + `<a href="/about.html">About</a>` + "\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			menuItems = test.items
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(template))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := finalizePage(doc, &pageHead{}, "/posts/a.html", nil); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := doc.Find("#nav").Html(); got != test.want {
+				t.Errorf("nav = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// jsonMember is a member of a JSON object, by where its key and value are
+// in the text.
+type jsonMember struct {
+	key        string
+	keyStart   int
+	valueStart int
+	valueEnd   int
+}
+
+func skipJsonSpace(text string, i int) int {
+	for i < len(text) && strings.ContainsRune(" \t\r\n", rune(text[i])) {
+		i++
+	}
+	return i
+}
+
+// skipJsonValue returns where the JSON value starting at i ends. The text
+// is known to be valid.
+func skipJsonValue(text string, i int) int {
+	depth := 0
+	for ; i < len(text); i++ {
+		switch text[i] {
+		case '"':
+			for i++; text[i] != '"'; i++ {
+				if text[i] == '\\' {
+					i++
+				}
+			}
+			if depth == 0 {
+				return i + 1
+			}
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+			if depth < 0 {
+				return i
+			}
+		case ',', ' ', '\t', '\r', '\n':
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return i
+}
+
+// jsonMembers lists the members of the object in text, or the elements of
+// the array, from start, the position of its opening brace or bracket.
+// Elements have no key.
+func jsonMembers(text string, start int) ([]jsonMember, int) {
+	var members []jsonMember
+	object := text[start] == '{'
+	i := skipJsonSpace(text, start+1)
+	for i < len(text) && text[i] != '}' && text[i] != ']' {
+		member := jsonMember{keyStart: i}
+		if object {
+			end := skipJsonValue(text, i)
+			json.Unmarshal([]byte(text[i:end]), &member.key)
+			i = skipJsonSpace(text, end) + 1
+		}
+		member.valueStart = skipJsonSpace(text, i)
+		member.valueEnd = skipJsonValue(text, member.valueStart)
+		members = append(members, member)
+
+		i = skipJsonSpace(text, member.valueEnd)
+		if text[i] == ',' {
+			i = skipJsonSpace(text, i+1)
+		}
+	}
+
+	return members, i
+}
+
+// insertJsonMember adds an entry before the end of an object or array,
+// separated from the one before it like the entries already there are.
+func insertJsonMember(text string, members []jsonMember, end int, entry string) string {
+	if len(members) == 0 {
+		return text[:end] + entry + text[end:]
+	}
+
+	last := members[len(members)-1]
+	separator := ", "
+	if len(members) > 1 {
+		separator = text[members[len(members)-2].valueEnd:last.keyStart]
+	} else if before := text[:last.keyStart]; strings.Contains(text[last.keyStart:end], "\n") || strings.HasSuffix(strings.TrimRight(before, " \t"), "\n") {
+		separator = ",\n" + before[strings.LastIndex(before, "\n")+1:]
+	}
+	return text[:last.valueEnd] + separator + entry + text[last.valueEnd:]
+}
+
+// removeJsonMember takes an entry out of an object or array along with the
+// separator before it, or after it for the first one.
+func removeJsonMember(text string, members []jsonMember, index int) string {
+	member := members[index]
+	switch {
+	case len(members) == 1:
+		// The object or array is left empty, without the space it had
+		// around the entry.
+		start := member.keyStart
+		for start > 0 && strings.ContainsRune(" \t\r\n", rune(text[start-1])) {
+			start--
+		}
+		return text[:start] + text[skipJsonSpace(text, member.valueEnd):]
+	case index == 0:
+		return text[:member.keyStart] + text[members[1].keyStart:]
+	default:
+		return text[:members[index-1].valueEnd] + text[member.valueEnd:]
+	}
+}
+
+// metadataField finds the field of articleInfo a metadata key sets.
+func metadataField(key string) (reflect.StructField, bool) {
+	fields := reflect.TypeOf(articleInfo{})
+	for i := range fields.NumField() {
+		field := fields.Field(i)
+		if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name == key {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// metadataValue turns the value of a key=value argument into JSON for a
+// field of the type t. JSON is taken as it is, except for strings, which are
+// always the text given, and lists of strings, which are also taken from a
+// comma-separated list.
+func metadataValue(t reflect.Type, value string) (string, error) {
+	var encoded []byte
+	var err error
+	switch {
+	case t.Kind() == reflect.String:
+		encoded, err = json.Marshal(value)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String && !json.Valid([]byte(value)):
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		encoded, err = json.Marshal(items)
+	default:
+		encoded = []byte(value)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := json.Unmarshal(encoded, reflect.New(t).Interface()); err != nil {
+		return "", fmt.Errorf("Invalid value %s for a field of type %s", value, t)
+	}
+	return string(encoded), nil
+}
+
+// setMetadata applies an assignment to the text of a metadata file: key=value
+// replaces the value, key+=value adds an item to a list when it is not in it
+// yet and key-=value removes it. Everything else in the text is kept as it
+// is.
+func setMetadata(text string, assignment string) (string, error) {
+	key, value, ok := strings.Cut(assignment, "=")
+	if !ok {
+		return "", fmt.Errorf("Expected key=value, not %s", assignment)
+	}
+	op := ""
+	if strings.HasSuffix(key, "+") || strings.HasSuffix(key, "-") {
+		key, op = key[:len(key)-1], key[len(key)-1:]
+	}
+
+	field, ok := metadataField(key)
+	if !ok {
+		return "", fmt.Errorf("Unknown metadata field: %s", key)
+	}
+
+	start := skipJsonSpace(text, 0)
+	members, end := jsonMembers(text, start)
+	index := -1
+	for i, member := range members {
+		if member.key == key {
+			index = i
+		}
+	}
+
+	if op == "" {
+		encoded, err := metadataValue(field.Type, value)
+		if err != nil {
+			return "", err
+		}
+		if index >= 0 {
+			return text[:members[index].valueStart] + encoded + text[members[index].valueEnd:], nil
+		}
+
+		colon := ": "
+		if len(members) > 0 {
+			colon = text[skipJsonValue(text, members[0].keyStart):members[0].valueStart]
+		}
+		name, _ := json.Marshal(key)
+		return insertJsonMember(text, members, end, string(name)+colon+encoded), nil
+	}
+
+	if field.Type.Kind() != reflect.Slice {
+		return "", fmt.Errorf("%s is not a list", key)
+	}
+	item, err := metadataValue(field.Type.Elem(), value)
+	if err != nil {
+		return "", err
+	}
+	if index < 0 {
+		if op == "-" {
+			return text, nil
+		}
+		return setMetadata(text, key+"=["+item+"]")
+	}
+
+	list := members[index]
+	if text[list.valueStart] != '[' {
+		return setMetadata(text, key+"=["+item+"]")
+	}
+	items, itemsEnd := jsonMembers(text, list.valueStart)
+	for i, existing := range items {
+		var a, b any
+		json.Unmarshal([]byte(text[existing.valueStart:existing.valueEnd]), &a)
+		json.Unmarshal([]byte(item), &b)
+		if reflect.DeepEqual(a, b) {
+			if op == "-" {
+				return removeJsonMember(text, items, i), nil
+			}
+			return text, nil
+		}
+	}
+	if op == "-" {
+		return text, nil
+	}
+	return insertJsonMember(text, items, itemsEnd, item), nil
+}
+
+// articleMetadataAt is the metadata file of the article whose directory,
+// source or metadata file is at path.
+func articleMetadataAt(path string) (string, bool) {
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		return "", false
+	case info.IsDir():
+		path = filepath.Join(path, "metadata.json")
+	case filepath.Base(path) != "metadata.json":
+		path = filepath.Join(filepath.Dir(path), "metadata.json")
+	}
+
+	return path, fileExists(path)
+}
+
+// metadataPath finds the metadata of an article given by its path, or by
+// its name in the content.
+func metadataPath(name string) (string, error) {
+	if path, ok := articleMetadataAt(name); ok {
+		return path, nil
+	}
+	if contentCheckout == "" && os.Getenv("CONTENT_PATH") == "" {
+		return "", fmt.Errorf("Cannot find article %s, and CONTENT_PATH is not set to look in", name)
+	}
+	if path, ok := articleMetadataAt(filepath.Join(contentDirectory(), "articles", name)); ok {
+		return path, nil
+	}
+
+	return "", fmt.Errorf("Cannot find the metadata of article %s", name)
+}
+
+// editMetadata applies assignments to a metadata file and writes it back
+// when the result still decodes as article metadata.
+func editMetadata(path string, assignments []string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !json.Valid(data) {
+		var value any
+		return fmt.Errorf("Cannot edit metadata: %w", jsonError(path, data, json.Unmarshal(data, &value)))
+	}
+
+	text := string(data)
+	for _, assignment := range assignments {
+		if text, err = setMetadata(text, assignment); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&articleInfo{}); err != nil {
+		return fmt.Errorf("Edited metadata is invalid: %w", jsonError(path, []byte(text), err))
+	}
+	if text == string(data) {
+		return nil
+	}
+
+	return rewriteFile(path, []byte(text))
+}
+
+// rewriteFile replaces the contents of a file, keeping its mode.
+func rewriteFile(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, info.Mode().Perm())
+}
+
+// printMetadata writes the values of keys of a metadata file one per line,
+// strings as they are and everything else as JSON, or the whole file without
+// keys.
+func printMetadata(path string, keys []string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	var metadata map[string]json.RawMessage
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return fmt.Errorf("Cannot decode metadata: %w", jsonError(path, data, err))
+	}
+	for _, key := range keys {
+		raw, ok := metadata[key]
+		if !ok {
+			fmt.Println()
+			continue
+		}
+
+		var text string
+		if json.Unmarshal(raw, &text) != nil {
+			var compact bytes.Buffer
+			json.Compact(&compact, raw)
+			text = compact.String()
+		}
+		fmt.Println(text)
+	}
+	return nil
+}
+
+// runMeta reads and changes article metadata without touching the rest of
+// the file, so that bulk fixes can be scripted:
+//
+//	meta get <article> [key...]
+//	meta set <article>... key=value|key+=item|key-=item...
+func runMeta(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("Usage: meta get article [key...] | meta set article... key=value...")
+	}
+
+	if err := loadConfig(); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "get":
+		path, err := metadataPath(args[1])
+		if err != nil {
+			return err
+		}
+		return printMetadata(path, args[2:])
+	case "set":
+		var names, assignments []string
+		for _, arg := range args[1:] {
+			if strings.Contains(arg, "=") {
+				assignments = append(assignments, arg)
+			} else {
+				names = append(names, arg)
+			}
+		}
+		if len(names) == 0 || len(assignments) == 0 {
+			return fmt.Errorf("Usage: meta set article... key=value...")
+		}
+
+		for _, name := range names {
+			path, err := metadataPath(name)
+			if err != nil {
+				return err
+			}
+			if err := editMetadata(path, assignments); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("Unknown meta command: %s", args[0])
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetMetadata(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		assignment string
+		want       string
+	}{
+		{"replace", `{"title": "Old", "draft": false}`, "title=New", `{"title": "New", "draft": false}`},
+		{"replace keeps the layout", "{\n  \"draft\":false\n}\n", "draft=true", "{\n  \"draft\":true\n}\n"},
+		{"add to an empty object", `{}`, "draft=true", `{"draft": true}`},
+		{"add after the others", `{"title": "A"}`, "weight=3", `{"title": "A", "weight": 3}`},
+		{"add on its own line", "{\n  \"title\": \"A\"\n}\n", "weight=3", "{\n  \"title\": \"A\",\n  \"weight\": 3\n}\n"},
+		{"add with the colon used", `{"title":"A"}`, "weight=3", `{"title":"A", "weight":3}`},
+		{"string taken as text", `{}`, "title=42", `{"title": "42"}`},
+		{"comma separated list", `{}`, "tags=go, web", `{"tags": ["go","web"]}`},
+		{"JSON list", `{}`, `tags=["go"]`, `{"tags": ["go"]}`},
+		{"append", `{"tags": ["go"]}`, "tags+=web", `{"tags": ["go", "web"]}`},
+		{"append to a missing list", `{"title": "A"}`, "tags+=web", `{"title": "A", "tags": ["web"]}`},
+		{"append what is there", `{"tags": ["go"]}`, "tags+=go", `{"tags": ["go"]}`},
+		{"remove the first", `{"tags": ["go", "web"]}`, "tags-=go", `{"tags": ["web"]}`},
+		{"remove a later one", `{"tags": ["go", "web", "rust"]}`, "tags-=web", `{"tags": ["go", "rust"]}`},
+		{"remove the last one", `{"tags": [ "go" ]}`, "tags-=go", `{"tags": []}`},
+		{"remove the last one on its own line", "{\n  \"tags\": [\n    \"go\"\n  ]\n}\n", "tags-=go", "{\n  \"tags\": []\n}\n"},
+		{"remove what is not there", `{"tags": ["go"]}`, "tags-=web", `{"tags": ["go"]}`},
+		{"escaped string", `{"title": "a \"b\" c", "draft": false}`, "draft=true", `{"title": "a \"b\" c", "draft": true}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := setMetadata(test.text, test.assignment)
+			if err != nil || got != test.want {
+				t.Errorf("setMetadata(%q, %q) = %q, %v; want %q", test.text, test.assignment, got, err, test.want)
+			}
+		})
+	}
+
+	for _, assignment := range []string{"title", "nonsense=1", "weight=heavy", "title+=x"} {
+		if _, err := setMetadata(`{}`, assignment); err == nil {
+			t.Errorf("setMetadata(%q) succeeded", assignment)
+		}
+	}
+}
+
+func TestRunMeta(t *testing.T) {
+	content := t.TempDir()
+	t.Setenv("CONTENT_PATH", content)
+	t.Setenv("CONFIG_PATH", "")
+	saved := config
+	t.Cleanup(func() { config = saved })
+
+	writeTestFiles(t, content, map[string]string{
+		"articles/a/index.md":      "# A\n",
+		"articles/a/metadata.json": "{\n  \"tags\": [\"go\"]\n}\n",
+	})
+	metadata := filepath.Join(content, "articles", "a", "metadata.json")
+	os.Chmod(metadata, 0600)
+
+	if err := runMeta([]string{"set", "a", "tags-=go", "draft=true"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(metadata)
+	if want := "{\n  \"tags\": [],\n  \"draft\": true\n}\n"; err != nil || string(data) != want {
+		t.Errorf("metadata = %q, %v; want %q", data, err, want)
+	}
+	info, err := os.Stat(metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode of the metadata = %v, want 0600", info.Mode().Perm())
+	}
+
+	if err := runMeta([]string{"set", "missing", "draft=true"}); err == nil {
+		t.Error("editing a missing article succeeded")
+	}
+
+	t.Setenv("CONTENT_PATH", "")
+	err = runMeta([]string{"get", "a"})
+	if err == nil || !strings.Contains(err.Error(), "CONTENT_PATH") {
+		t.Errorf("meta get without CONTENT_PATH = %v, want an error naming it", err)
+	}
+}
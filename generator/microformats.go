@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"html"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type microformatsConfig struct {
+	Enabled bool         `json:"enabled"`
+	Author  authorConfig `json:"author"`
+}
+
+type authorConfig struct {
+	Name  string `json:"name"`
+	Url   string `json:"url"`
+	Photo string `json:"photo"`
+}
+
+func authorCard() string {
+	author := config.Microformats.Author
+	if author.Name == "" {
+		return ""
+	}
+
+	photo := ""
+	if author.Photo != "" {
+		photo = fmt.Sprintf(`<img class="u-photo" src="%s" alt="">`, html.EscapeString(author.Photo))
+	}
+	href := author.Url
+	if href == "" {
+		href = "/"
+	}
+
+	return fmt.Sprintf(`<a class="p-author h-card" href="%s" hidden>%s%s</a>`,
+		html.EscapeString(href), photo, html.EscapeString(author.Name))
+}
+
+// markEntry adds the microformats2 h-entry classes to an article element
+// holding the metadata line, the title and the content.
+func markEntry(entry *goquery.Selection) {
+	entry.AddClass("h-entry")
+	entry.Find("h1").First().AddClass("p-name")
+	entry.Find(".article-info time").First().AddClass("dt-published")
+	entry.Find(".article-title-link").First().AddClass("u-url")
+
+	if card := authorCard(); card != "" {
+		entry.Find(".article-info p").First().AppendHtml(card)
+	}
+
+	entry.Children().Not(".article-info").WrapAllHtml(`<div class="e-content"></div>`)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// articleMove rewrites the references to an article whose directory moves
+// from oldDir to newDir.
+type articleMove struct {
+	oldDir  string
+	newDir  string
+	oldSlug string
+	newSlug string
+}
+
+// linkPrefixes maps the ways a file can start a link into the old directory
+// of the article to the same link into the new one.
+func (move articleMove) linkPrefixes(file string) map[string]string {
+	oldRel, _ := filepath.Rel(contentDirectory(), move.oldDir)
+	newRel, _ := filepath.Rel(contentDirectory(), move.newDir)
+	prefixes := map[string]string{
+		"/" + filepath.ToSlash(oldRel): "/" + filepath.ToSlash(newRel),
+	}
+	if config.BaseUrl != "" {
+		base := strings.TrimSuffix(config.BaseUrl, "/")
+		prefixes[base+"/"+filepath.ToSlash(oldRel)] = base + "/" + filepath.ToSlash(newRel)
+	}
+
+	// Files of the article link to each other the same way wherever it is,
+	// and files outside the content, like the config, only link from the
+	// root.
+	dir := filepath.Dir(file)
+	if inside, err := filepath.Rel(move.oldDir, dir); err == nil && !strings.HasPrefix(inside, "..") {
+		return prefixes
+	}
+	if inside, err := filepath.Rel(contentDirectory(), dir); err != nil || strings.HasPrefix(inside, "..") {
+		return prefixes
+	}
+	oldLink, err := filepath.Rel(dir, move.oldDir)
+	if err != nil {
+		return prefixes
+	}
+	newLink, _ := filepath.Rel(dir, move.newDir)
+	prefixes[filepath.ToSlash(oldLink)] = filepath.ToSlash(newLink)
+
+	return prefixes
+}
+
+// rewriteLinks points the links of a file into the article at its new
+// directory. Links are found by what they start with, between the quotes,
+// brackets or spaces links are written in, in any source format.
+func (move articleMove) rewriteLinks(text string, file string) string {
+	for oldPrefix, newPrefix := range move.linkPrefixes(file) {
+		link := regexp.MustCompile(`(?m)(^|[\s"'(<=\[,])` + regexp.QuoteMeta(oldPrefix) + `([/"'#?)\s>\],]|$)`)
+		// A match takes the character after it, which may be the one the
+		// next link starts after.
+		for {
+			next := link.ReplaceAllString(text, "${1}"+strings.ReplaceAll(newPrefix, "$", "$$")+"${2}")
+			if next == text {
+				break
+			}
+			text = next
+		}
+	}
+
+	oldRel, _ := filepath.Rel(contentDirectory(), move.oldDir)
+	newRel, _ := filepath.Rel(contentDirectory(), move.newDir)
+	text = refDirective.ReplaceAllStringFunc(text, func(match string) string {
+		name := refDirective.FindStringSubmatch(match)[1]
+		switch rest, ok := strings.CutPrefix(strings.TrimPrefix(name, "/"), filepath.ToSlash(oldRel)); {
+		case name == move.oldSlug:
+			return strings.Replace(match, `"`+name+`"`, `"`+move.newSlug+`"`, 1)
+		case ok && (rest == "" || strings.HasPrefix(rest, "/")):
+			moved := strings.Replace(name, filepath.ToSlash(oldRel), filepath.ToSlash(newRel), 1)
+			return strings.Replace(match, `"`+name+`"`, `"`+moved+`"`, 1)
+		}
+		return match
+	})
+
+	if config.WikiLinks {
+		text = wikiLink.ReplaceAllStringFunc(text, func(match string) string {
+			name := wikiLink.FindStringSubmatch(match)[1]
+			if strings.TrimSpace(name) != move.oldSlug {
+				return match
+			}
+			return "[[" + strings.Replace(name, move.oldSlug, move.newSlug, 1) + match[2+len(name):]
+		})
+	}
+
+	return text
+}
+
+// referencingFiles lists the files that may link to an article: the
+// sources and JSON files of the content, included and raw ones too, and the
+// config.
+func referencingFiles() ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(contentDirectory(), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if isExcludedPath(path) {
+			return skipExcluded(entry)
+		}
+		if !entry.IsDir() && (isSourcePath(path) || filepath.Ext(path) == ".json") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if configPath() != "" {
+		files = append(files, configPath())
+	}
+
+	return files, err
+}
+
+// runMv moves an article to a new slug without breaking the links to it:
+//
+//	mv [--dry-run] <article> <new-slug>
+//
+// Links to the article from the content and the config are pointed at the
+// new place, and its old url becomes an alias, so the build writes a
+// redirect from it.
+func runMv(args []string) error {
+	flags := flag.NewFlagSet("mv", flag.ContinueOnError)
+	flags.StringVar(&buildEnvironment, "env", buildEnvironment, "config environment to use")
+	dryRun := flags.Bool("dry-run", false, "list what would change without changing it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return fmt.Errorf("Usage: mv [--dry-run] article new-slug")
+	}
+
+	if err := loadConfig(); err != nil {
+		return err
+	}
+
+	metadata, err := metadataPath(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	// The article may be given by a path from elsewhere than the content
+	// directory is.
+	content, _ := filepath.Abs(contentDirectory())
+	dir, _ := filepath.Abs(filepath.Dir(metadata))
+	rel, err := filepath.Rel(content, dir)
+	if err != nil {
+		return err
+	}
+	oldDir := filepath.Join(contentDirectory(), rel)
+	if !isArticlePath(directoryIndex(oldDir)) {
+		return fmt.Errorf("%s is not an article", flags.Arg(0))
+	}
+
+	slug := flags.Arg(1)
+	if slug == "" || slug == "." || slug == ".." || strings.ContainsAny(slug, `/\`) {
+		return fmt.Errorf("Invalid slug: %q", slug)
+	}
+	newDir := filepath.Join(filepath.Dir(oldDir), slug)
+	if isExcludedPath(newDir) {
+		return fmt.Errorf("Articles named %s are left out of the build", slug)
+	}
+	if fileExists(newDir) {
+		return fmt.Errorf("%s already exists", newDir)
+	}
+
+	move := articleMove{oldDir: oldDir, newDir: newDir, oldSlug: filepath.Base(oldDir), newSlug: slug}
+	index := directoryIndex(oldDir)
+	oldUrl := urlFromContentPath(index)
+	newUrl := urlFromContentPath(filepath.Join(newDir, filepath.Base(index)))
+
+	files, err := referencingFiles()
+	if err != nil {
+		return err
+	}
+	rewritten := make(map[string][]byte)
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if text := move.rewriteLinks(string(data), path); text != string(data) {
+			rewritten[path] = []byte(text)
+		}
+	}
+
+	if !*dryRun {
+		if err := os.Rename(oldDir, newDir); err != nil {
+			return fmt.Errorf("Cannot move article: %w", err)
+		}
+	}
+
+	// The links are only changed once the article is in its new place, so a
+	// failed move leaves the site as it was.
+	var failed []string
+	for _, path := range slices.Sorted(maps.Keys(rewritten)) {
+		target := path
+		if rel, err := filepath.Rel(oldDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			target = filepath.Join(newDir, rel)
+		}
+
+		fmt.Printf("Updated links in %s\n", target)
+		if !*dryRun {
+			if err := rewriteFile(target, rewritten[path]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed = append(failed, target)
+			}
+		}
+	}
+
+	fmt.Printf("Moved %s to %s, with a redirect from %s\n", oldUrl, newUrl, oldUrl)
+	if *dryRun {
+		return nil
+	}
+
+	// An alias left from moving the article away from its new place would
+	// now clash with it.
+	metadata = filepath.Join(newDir, "metadata.json")
+	if err := editMetadata(metadata, []string{"aliases+=" + oldUrl, "aliases-=" + newUrl}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		failed = append(failed, metadata)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("Moved the article, but could not update %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRewriteLinks(t *testing.T) {
+	content := t.TempDir()
+	t.Setenv("CONTENT_PATH", content)
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.BaseUrl = "https://example.com/"
+	config.WikiLinks = true
+
+	move := articleMove{
+		oldDir:  filepath.Join(content, "articles", "old"),
+		newDir:  filepath.Join(content, "articles", "new"),
+		oldSlug: "old",
+		newSlug: "new",
+	}
+	other := filepath.Join(content, "articles", "other", "index.md")
+	page := filepath.Join(content, "about.html")
+	own := filepath.Join(move.oldDir, "index.md")
+
+	tests := []struct {
+		name string
+		file string
+		text string
+		want string
+	}{
+		{"root-relative", other, "[a](/articles/old/)", "[a](/articles/new/)"},
+		{"root-relative page", other, `<a href="/articles/old/index.html#top">`, `<a href="/articles/new/index.html#top">`},
+		{"absolute", other, "<https://example.com/articles/old/>", "<https://example.com/articles/new/>"},
+		{"relative between articles", other, "![p](../old/photo.png)", "![p](../new/photo.png)"},
+		{"relative from a page", page, `<img src="articles/old/photo.png">`, `<img src="articles/new/photo.png">`},
+		{"adjacent links", other, "/articles/old /articles/old", "/articles/new /articles/new"},
+		{"srcset", other, `srcset="/articles/old/a.png 1x,/articles/old/b.png 2x"`, `srcset="/articles/new/a.png 1x,/articles/new/b.png 2x"`},
+		{"longer name left alone", other, "[a](/articles/old-2/)", "[a](/articles/old-2/)"},
+		{"other site left alone", other, "https://elsewhere.com/articles/old/", "https://elsewhere.com/articles/old/"},
+		{"ref by name", other, `{{< ref "old" >}}`, `{{< ref "new" >}}`},
+		{"ref by path", other, `{{< ref "/articles/old/index.md" >}}`, `{{< ref "/articles/new/index.md" >}}`},
+		{"wiki link", other, "[[old|the old post]] and [[older]]", "[[new|the old post]] and [[older]]"},
+		{"own relative links kept", own, "![p](photo.png) and [s](../sibling/)", "![p](photo.png) and [s](../sibling/)"},
+		{"own root-relative links", own, "![p](/articles/old/photo.png)", "![p](/articles/new/photo.png)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := move.rewriteLinks(test.text, test.file); got != test.want {
+				t.Errorf("rewriteLinks(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func writeTestFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, text := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRunMv(t *testing.T) {
+	content := t.TempDir()
+	t.Setenv("CONTENT_PATH", content)
+	t.Setenv("CONFIG_PATH", "")
+	saved := config
+	t.Cleanup(func() { config = saved })
+
+	writeTestFiles(t, content, map[string]string{
+		"articles/old/index.md":        "# Old\n\n![p](photo.png)\n",
+		"articles/old/metadata.json":   "{\n  \"release_date\": \"2024-01-01\"\n}\n",
+		"articles/old/photo.png":       "png",
+		"articles/other/index.md":      "[old](/articles/old/)\n",
+		"articles/other/metadata.json": "{\"release_date\": \"2024-01-02\"}\n",
+		"articles/taken/index.md":      "# Taken\n",
+	})
+	os.Chmod(filepath.Join(content, "articles", "other", "index.md"), 0600)
+
+	if err := runMv([]string{"old", "taken"}); err == nil {
+		t.Fatal("moving onto an existing article succeeded")
+	}
+	if err := runMv([]string{"old", "a/b"}); err == nil {
+		t.Fatal("moving to a nested slug succeeded")
+	}
+	if err := runMv([]string{"old", "new"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"articles/new/index.md", "# Old\n\n![p](photo.png)\n"},
+		{"articles/new/photo.png", "png"},
+		{"articles/new/metadata.json", "{\n  \"release_date\": \"2024-01-01\",\n  \"aliases\": [\"/articles/old/index.html\"]\n}\n"},
+		{"articles/other/index.md", "[old](/articles/new/)\n"},
+	}
+	for _, test := range tests {
+		data, err := os.ReadFile(filepath.Join(content, filepath.FromSlash(test.name)))
+		if err != nil || string(data) != test.want {
+			t.Errorf("%s = %q, %v; want %q", test.name, data, err, test.want)
+		}
+	}
+
+	if fileExists(filepath.Join(content, "articles", "old")) {
+		t.Error("the old directory is still there")
+	}
+	info, err := os.Stat(filepath.Join(content, "articles", "other", "index.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode of a rewritten file = %v, want 0600", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// offline forbids the build to use the network, set with --offline. Features
+// fall back to their caches, and whatever still needed the network is
+// listed when the build fails.
+var offline bool
+
+var errOffline = errors.New("the network is off limits for an offline build")
+
+// offlineNeeds lists the features that wanted the network, with the link
+// they wanted.
+var offlineNeeds []string
+
+// requireNetwork is called by a feature before it goes to the network and
+// fails for offline builds.
+func requireNetwork(feature string, link string) error {
+	if !offline {
+		return nil
+	}
+
+	need := feature + ": " + link
+	if !slices.Contains(offlineNeeds, need) {
+		offlineNeeds = append(offlineNeeds, need)
+	}
+	return fmt.Errorf("Cannot fetch %s: %w", link, errOffline)
+}
+
+// networkTransport backs every HTTP client of the build, so a request
+// that skipped requireNetwork is refused all the same.
+type networkTransport struct{}
+
+func (networkTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := requireNetwork("request", req.URL.String()); err != nil {
+		return nil, err
+	}
+
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// checkOffline fails an offline build that needed the network.
+func checkOffline() error {
+	if len(offlineNeeds) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("The offline build needs the network for:\n  %s", strings.Join(offlineNeeds, "\n  "))
+}
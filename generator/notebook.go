@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*[A-Za-z]")
+
+// notebookText is a string that notebooks store either whole or as a list
+// of lines.
+type notebookText string
+
+func (text *notebookText) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err == nil {
+		*text = notebookText(strings.Join(lines, ""))
+		return nil
+	}
+
+	var whole string
+	if err := json.Unmarshal(data, &whole); err != nil {
+		return err
+	}
+	*text = notebookText(whole)
+	return nil
+}
+
+type notebookOutput struct {
+	OutputType string                  `json:"output_type"`
+	Stream     string                  `json:"name"`
+	Text       notebookText            `json:"text"`
+	Data       map[string]notebookText `json:"data"`
+	Ename      string                  `json:"ename"`
+	Evalue     string                  `json:"evalue"`
+	Traceback  []string                `json:"traceback"`
+}
+
+type notebookCell struct {
+	CellType    string                             `json:"cell_type"`
+	Source      notebookText                       `json:"source"`
+	Outputs     []notebookOutput                   `json:"outputs"`
+	Attachments map[string]map[string]notebookText `json:"attachments"`
+}
+
+type notebook struct {
+	Cells    []notebookCell `json:"cells"`
+	Metadata struct {
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+		Kernelspec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+	} `json:"metadata"`
+}
+
+var notebookImageTypes = map[string]string{"image/png": ".png", "image/jpeg": ".jpg", "image/gif": ".gif"}
+
+// notebookImage decodes a base64 image of a notebook into the assets and
+// returns its file name.
+func notebookImage(assets map[string][]byte, name string, mediaType string, data notebookText) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(data)), ""))
+	if err != nil {
+		return "", fmt.Errorf("Invalid %s in %s: %w", mediaType, name, err)
+	}
+
+	name += notebookImageTypes[mediaType]
+	assets[name] = decoded
+	return name, nil
+}
+
+// notebookOutputHtml renders the richest form of an output this page can
+// show.
+func notebookOutputHtml(output notebookOutput, assets map[string][]byte, name string) (string, error) {
+	switch output.OutputType {
+	case "stream":
+		return fmt.Sprintf(`<pre class="notebook-%s">%s</pre>`, html.EscapeString(output.Stream), html.EscapeString(string(output.Text))), nil
+	case "error":
+		traceback := ansiEscape.ReplaceAllString(strings.Join(output.Traceback, "\n"), "")
+		if traceback == "" {
+			traceback = output.Ename + ": " + output.Evalue
+		}
+		return fmt.Sprintf(`<pre class="notebook-error">%s</pre>`, html.EscapeString(traceback)), nil
+	}
+
+	if value, ok := output.Data["text/html"]; ok {
+		return string(value), nil
+	}
+	if value, ok := output.Data["image/svg+xml"]; ok {
+		return string(value), nil
+	}
+	for _, mediaType := range []string{"image/png", "image/jpeg", "image/gif"} {
+		if value, ok := output.Data[mediaType]; ok {
+			file, err := notebookImage(assets, name, mediaType, value)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf(`<img src="%s" alt="">`, html.EscapeString(file)), nil
+		}
+	}
+	if value, ok := output.Data["text/markdown"]; ok {
+		return renderMarkdown(string(value))
+	}
+	if value, ok := output.Data["text/plain"]; ok {
+		return fmt.Sprintf(`<pre>%s</pre>`, html.EscapeString(string(value))), nil
+	}
+
+	return "", nil
+}
+
+// convertNotebook renders a Jupyter notebook: Markdown cells as text, code
+// cells highlighted and followed by their outputs. Images in outputs and
+// attachments become files next to the page.
+func convertNotebook(path string, data []byte) (convertedSource, error) {
+	var book notebook
+	if err := json.Unmarshal(data, &book); err != nil {
+		return convertedSource{}, fmt.Errorf("Invalid notebook: %w", err)
+	}
+
+	language := book.Metadata.LanguageInfo.Name
+	if language == "" {
+		language = book.Metadata.Kernelspec.Language
+	}
+
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	assets := make(map[string][]byte)
+	var out strings.Builder
+	for i, cell := range book.Cells {
+		switch cell.CellType {
+		case "markdown":
+			source := string(cell.Source)
+			for name, attachment := range cell.Attachments {
+				for mediaType, value := range attachment {
+					if _, ok := notebookImageTypes[mediaType]; !ok {
+						continue
+					}
+					file, err := notebookImage(assets, fmt.Sprintf("%s-%d-%s", base, i, slugify(strings.TrimSuffix(name, filepath.Ext(name)))), mediaType, value)
+					if err != nil {
+						return convertedSource{}, err
+					}
+					source = strings.ReplaceAll(source, "attachment:"+name, file)
+				}
+			}
+
+			rendered, err := renderMarkdown(source)
+			if err != nil {
+				return convertedSource{}, err
+			}
+			out.WriteString(rendered)
+		case "code":
+			if strings.TrimSpace(string(cell.Source)) == "" {
+				continue
+			}
+			out.WriteString(`<div class="notebook-cell">`)
+			out.WriteString(highlightCode(string(cell.Source), language))
+			for j, output := range cell.Outputs {
+				rendered, err := notebookOutputHtml(output, assets, fmt.Sprintf("%s-%d-%d", base, i, j))
+				if err != nil {
+					return convertedSource{}, err
+				}
+				if rendered != "" {
+					out.WriteString(`<div class="notebook-output">` + rendered + `</div>`)
+				}
+			}
+			out.WriteString("</div>\n")
+		case "raw":
+			out.WriteString(string(cell.Source))
+		}
+	}
+
+	return convertedSource{html: out.String(), assets: assets}, nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+
+	"github.com/niklasfasching/go-org/org"
+)
+
+// convertOrg renders an Org mode file. Its #+TITLE becomes the title of the
+// page, and source blocks are highlighted like notebook code.
+func convertOrg(path string, data []byte) (convertedSource, error) {
+	configuration := org.New()
+	configuration.Log = log.New(io.Discard, "", 0)
+	configuration.DefaultSettings["OPTIONS"] = "toc:nil <:t e:t f:t pri:t todo:t tags:t title:t ealb:nil"
+
+	document := configuration.Parse(bytes.NewReader(data), path)
+	if document.Error != nil {
+		return convertedSource{}, document.Error
+	}
+
+	writer := org.NewHTMLWriter()
+	writer.HighlightCodeBlock = func(source string, language string, inline bool, params map[string]string) string {
+		return highlightCode(source, language)
+	}
+
+	rendered, err := document.Write(writer)
+	if err != nil {
+		return convertedSource{}, err
+	}
+	return convertedSource{html: rendered}, nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type orphansConfig struct {
+	Report  bool     `json:"report"`
+	Exclude bool     `json:"exclude"`
+	Keep    []string `json:"keep"`
+}
+
+// Files browsers and hosts look for on their own are never orphans.
+var implicitAssets = []string{"/favicon.ico", "/robots.txt", "/CNAME", "/.well-known/"}
+
+var cssUrl = regexp.MustCompile(`url\(\s*['"]?([^'")]+)['"]?\s*\)`)
+
+// addReference records the target path a link points to, resolved against
+// the url of the file it appears in.
+func addReference(references map[string]bool, base string, link string) {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+	if u.Host != "" && (config.BaseUrl == "" || !strings.HasPrefix(link, config.BaseUrl)) {
+		return
+	}
+
+	baseUrl, err := url.Parse(base)
+	if err != nil {
+		return
+	}
+	references[baseUrl.ResolveReference(u).Path] = true
+}
+
+func pageReferences(references map[string]bool, base string, doc *goquery.Document) {
+	doc.Find("[href], [src], [poster], object[data]").Each(func(_ int, element *goquery.Selection) {
+		for _, name := range []string{"href", "src", "poster", "data"} {
+			if link, ok := element.Attr(name); ok {
+				addReference(references, base, link)
+			}
+		}
+	})
+
+	doc.Find("[srcset]").Each(func(_ int, element *goquery.Selection) {
+		srcset, _ := element.Attr("srcset")
+		for _, candidate := range strings.Split(srcset, ",") {
+			if fields := strings.Fields(candidate); len(fields) > 0 {
+				addReference(references, base, fields[0])
+			}
+		}
+	})
+
+	doc.Find("style, [style]").Each(func(_ int, element *goquery.Selection) {
+		css := element.Text()
+		if style, ok := element.Attr("style"); ok {
+			css = style
+		}
+		cssReferences(references, base, css)
+	})
+}
+
+func cssReferences(references map[string]bool, base string, css string) {
+	for _, match := range cssUrl.FindAllStringSubmatch(css, -1) {
+		addReference(references, base, match[1])
+	}
+}
+
+// referencedUrls collects every local url linked from the generated pages
+// and stylesheets.
+func referencedUrls() (map[string]bool, error) {
+	pages, err := generatedPages()
+	if err != nil {
+		return nil, err
+	}
+
+	references := make(map[string]bool)
+	for path, doc := range pages {
+		pageReferences(references, outputUrl(path), doc)
+	}
+
+	for target := range outputSources {
+		if filepath.Ext(target) != ".css" {
+			continue
+		}
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return nil, err
+		}
+		cssReferences(references, outputUrl(target), string(data))
+	}
+
+	return references, nil
+}
+
+func outputUrl(path string) string {
+	rel, err := filepath.Rel(targetDirectory(), path)
+	if err != nil {
+		return path
+	}
+
+	return "/" + filepath.ToSlash(rel)
+}
+
+func keptAsset(u string) bool {
+	for _, prefix := range append(implicitAssets, config.Orphans.Keep...) {
+		if u == prefix || strings.HasSuffix(prefix, "/") && strings.HasPrefix(u, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reportOrphanedAssets lists the copied content files no generated page
+// links to and, when configured, drops them from the output.
+func reportOrphanedAssets() error {
+	references, err := referencedUrls()
+	if err != nil {
+		return err
+	}
+
+	var orphans []string
+	for target, source := range outputSources {
+		if isSourcePath(source) || targetPathFromContentPath(source) != target {
+			continue
+		}
+
+		u := outputUrl(target)
+		if !references[u] && !keptAsset(u) {
+			orphans = append(orphans, target)
+		}
+	}
+	sort.Strings(orphans)
+
+	var size int64
+	for _, target := range orphans {
+		info, err := os.Stat(target)
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		addDiagnostic("orphans", outputSources[target], 0, "orphaned asset")
+
+		if config.Orphans.Exclude {
+			if err := os.Remove(target); err != nil {
+				return err
+			}
+			delete(outputSources, target)
+		}
+	}
+
+	if len(orphans) > 0 {
+		fmt.Printf("%d orphaned assets, %d bytes\n", len(orphans), size)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// outputOwners maps every path written by the build to what produced it, so
+// two sources writing the same file fail the build instead of one silently
+// replacing the other.
+var outputOwners = make(map[string]string)
+
+func claimOutput(target string, owner string) error {
+	key := filepath.Clean(target)
+	if config.CaseInsensitiveOutput {
+		key = strings.ToLower(key)
+	}
+
+	if previous, ok := outputOwners[key]; ok && previous != owner {
+		return fmt.Errorf("Output collision: %s and %s both write %s", previous, owner, outputUrl(target))
+	}
+
+	outputOwners[key] = owner
+	return nil
+}
+
+// stagingDirectory is where the running build writes its output. It is a
+// sibling of the target directory and replaces it once the build is done.
+var stagingDirectory string
+
+func targetLockPath() string {
+	return outputDirectory() + ".lock"
+}
+
+// lockTarget makes sure only one build writes to the target directory at a
+// time. The lock is held by the system, not by the file being there, so a
+// build that is killed does not keep later ones out. The returned function
+// releases the lock.
+func lockTarget() (func(), error) {
+	path := targetLockPath()
+	if err := createDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	unlock, err := lockFile(path)
+	if errors.Is(err, errLocked) {
+		owner, _ := os.ReadFile(path)
+		return nil, fmt.Errorf("Another build is running (process %s)", strings.TrimSpace(string(owner)))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to lock %s: %w", path, err)
+	}
+
+	return unlock, nil
+}
+
+func stageTarget() error {
+	target := outputDirectory()
+	if err := createDir(filepath.Dir(target)); err != nil {
+		return err
+	}
+
+	staging, err := os.MkdirTemp(filepath.Dir(target), "."+filepath.Base(target)+"-build-")
+	if err != nil {
+		return fmt.Errorf("Failed to create staging directory: %w", err)
+	}
+	if err := os.Chmod(staging, 0755); err != nil {
+		return err
+	}
+
+	stagingDirectory = staging
+	return nil
+}
+
+// promoteTarget hands the finished staging directory to the output sink.
+func promoteTarget() error {
+	staging := stagingDirectory
+	stagingDirectory = ""
+
+	return siteSink.store(staging)
+}
+
+// writeOutputFile writes to a temporary file next to path and renames it,
+// so readers never see a partially written file.
+func writeOutputFile(path string, data []byte) error {
+	return writeOutputWith(path, func(file *os.File) error {
+		_, err := file.Write(data)
+		return err
+	})
+}
+
+func writeOutputWith(path string, write func(file *os.File) error) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Chmod(0644); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
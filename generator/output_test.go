@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLockTarget(t *testing.T) {
+	t.Setenv("TARGET_PATH", filepath.Join(t.TempDir(), "site"))
+
+	unlock, err := lockTarget()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockTarget(); err == nil || !strings.Contains(err.Error(), "Another build is running") {
+		t.Fatalf("second lock = %v, want it refused", err)
+	}
+
+	unlock()
+	unlock, err = lockTarget()
+	if err != nil {
+		t.Fatalf("lock after unlock = %v", err)
+	}
+	unlock()
+}
+
+func TestLockTargetLeftBehind(t *testing.T) {
+	t.Setenv("TARGET_PATH", filepath.Join(t.TempDir(), "site"))
+
+	// A build that was killed leaves its lock file, with a process id that no
+	// longer runs.
+	if err := os.WriteFile(targetLockPath(), []byte("999999999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock, err := lockTarget()
+	if err != nil {
+		t.Fatalf("lock over a stale lock file = %v", err)
+	}
+	unlock()
+}
+
+func TestStageAndPromoteTarget(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "site")
+	t.Setenv("TARGET_PATH", target)
+	siteSink = directorySink{}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{"first build", map[string]string{"index.html": "one", "old.html": "old"}},
+		{"replaces the previous build", map[string]string{"index.html": "two"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := stageTarget(); err != nil {
+				t.Fatal(err)
+			}
+			for name, text := range test.files {
+				if err := writeOutputFile(filepath.Join(targetDirectory(), name), []byte(text)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := promoteTarget(); err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := os.ReadDir(target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(test.files) {
+				t.Errorf("target has %d files, want %d", len(entries), len(test.files))
+			}
+			for name, text := range test.files {
+				data, err := os.ReadFile(filepath.Join(target, name))
+				if err != nil || string(data) != text {
+					t.Errorf("%s = %q, %v; want %q", name, data, err, text)
+				}
+			}
+
+			siblings, _ := filepath.Glob(filepath.Join(filepath.Dir(target), ".site-build-*"))
+			if len(siblings) != 0 {
+				t.Errorf("staging directories left behind: %v", siblings)
+			}
+		})
+	}
+}
+
+func TestWriteOutputFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		existing string
+		data     string
+	}{
+		{"new file", "", "first"},
+		{"replaces a file", "old", "second"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(test.name, " ", "-")+".html")
+			if test.existing != "" {
+				if err := os.WriteFile(path, []byte(test.existing), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := writeOutputFile(path, []byte(test.data)); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil || string(data) != test.data {
+				t.Errorf("%s = %q, %v; want %q", path, data, err, test.data)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0644 {
+				t.Errorf("mode = %v, want 0644", info.Mode().Perm())
+			}
+		})
+	}
+
+	// A write that fails leaves the file as it was, and no temporary file.
+	path := filepath.Join(dir, "kept.html")
+	if err := os.WriteFile(path, []byte("kept"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	failed := errors.New("failed")
+	err := writeOutputWith(path, func(file *os.File) error {
+		file.WriteString("partial")
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("writeOutputWith = %v, want the error of the write", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "kept" {
+		t.Errorf("file after a failed write = %q, want kept", data)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, ".*")); len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestHomePageCollision(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CONTENT_PATH", filepath.Join(dir, "content"))
+	t.Setenv("TARGET_PATH", filepath.Join(dir, "site"))
+	t.Setenv("CONFIG_PATH", "")
+	t.Setenv("TEMPLATE_PATH", "")
+	saved, savedOwners, savedArticles := config, outputOwners, articles
+	t.Cleanup(func() { config, outputOwners, articles = saved, savedOwners, savedArticles })
+	outputOwners, articles = make(map[string]string), nil
+
+	// A home page in the content and the generated one both write
+	// index.html, and the build names both.
+	source := filepath.Join(dir, "content", "index.html")
+	if err := claimOutput(targetPathFromUrl("/index.html"), source); err != nil {
+		t.Fatal(err)
+	}
+	err := generateHomePage()
+	if err == nil || !strings.Contains(err.Error(), source) || !strings.Contains(err.Error(), "the home page") {
+		t.Fatalf("generateHomePage = %v, want a collision naming %s and the home page", err, source)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pageInfo is the optional metadata of a page outside /articles, read from a
+// JSON file next to it with the same name (about-me.html, about-me.json).
+// A raw page is copied as it is instead of being put into a template.
+type pageInfo struct {
+	Title    string    `json:"title"`
+	Template string    `json:"template"`
+	Menu     *pageMenu `json:"menu"`
+	Raw      bool      `json:"raw"`
+}
+
+type pageMenu struct {
+	Label  string `json:"label"`
+	Weight int    `json:"weight"`
+}
+
+// checkedTemplates remembers the page templates already checked for hooks.
+var checkedTemplates = make(map[string]bool)
+
+func pageMetadataPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".json"
+}
+
+// isPageMetadataPath reports whether a JSON file belongs to a page. Such
+// files configure the build and are not copied.
+func isPageMetadataPath(path string) bool {
+	if filepath.Ext(path) != ".json" {
+		return false
+	}
+
+	for ext := range sourceConverters {
+		if page := strings.TrimSuffix(path, ".json") + ext; fileExists(page) {
+			return !isArticlePath(page)
+		}
+	}
+
+	page := strings.TrimSuffix(path, ".json") + ".html"
+	return fileExists(page) && !isArticlePath(page)
+}
+
+func getPageMetadata(path string) (pageInfo, error) {
+	var metadata pageInfo
+
+	data, err := os.ReadFile(pageMetadataPath(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return metadata, nil
+	}
+	if err != nil {
+		return metadata, err
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&metadata); err != nil {
+		return metadata, fmt.Errorf("Cannot decode page metadata: %w", jsonError(pageMetadataPath(path), data, err))
+	}
+
+	return metadata, nil
+}
+
+// pageTemplatePath resolves the template a page or article asks for against
+// the directory of the default template.
+func pageTemplatePath(name string) (string, error) {
+	if name == "" {
+		return templatePath(), nil
+	}
+
+	path := filepath.Join(filepath.Dir(templatePath()), filepath.FromSlash(name))
+	if !checkedTemplates[path] {
+		if err := checkTemplateFile(path); err != nil {
+			return "", err
+		}
+		checkedTemplates[path] = true
+	}
+
+	return path, nil
+}
+
+// pageMenuItems lists the pages that put themselves into the menu.
+func pageMenuItems() ([]menuItem, error) {
+	var items []menuItem
+	err := filepath.WalkDir(contentDirectory(), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && (isFragmentPath(path) || isRawPath(path)) {
+			return filepath.SkipDir
+		}
+		if isExcludedPath(path) {
+			return skipExcluded(entry)
+		}
+		if entry.IsDir() || !isSourcePath(path) || isArticlePath(path) {
+			return nil
+		}
+
+		metadata, err := getPageMetadata(path)
+		if err != nil || metadata.Menu == nil {
+			return err
+		}
+
+		label := metadata.Menu.Label
+		if label == "" {
+			if label, err = pageTitle(path); err != nil {
+				return err
+			}
+		}
+		items = append(items, menuItem{Label: label, Url: urlFromContentPath(path), Weight: metadata.Menu.Weight})
+		return nil
+	})
+
+	return items, err
+}
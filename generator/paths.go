@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type sanitizeConfig struct {
+	Enabled       bool `json:"enabled"`
+	Transliterate bool `json:"transliterate"`
+}
+
+var transliterations = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ą': "a",
+	'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ę': "e", 'ě': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'ı': "i",
+	'ł': "l", 'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o",
+	'ř': "r", 'ś': "s", 'š': "s", 'ş': "s", 'ß': "ss", 'ť': "t", 'ţ': "t",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+	'æ': "ae", 'œ': "oe", 'þ': "th", 'ð': "d",
+}
+
+// sanitizeSegment makes one path segment safe on common hosts: lowercase,
+// hyphens for spaces and no characters that are illegal or need escaping.
+// A name with nothing safe in it, like "???.html", is named after a short
+// hash of itself instead, so links and outputs still agree on it.
+func sanitizeSegment(segment string) string {
+	if segment == "." || segment == ".." {
+		return segment
+	}
+
+	ext := path.Ext(segment)
+	name := strings.TrimSuffix(segment, ext)
+	clean := sanitizeText(name)
+	if clean == "" && name != "" {
+		sum := sha256.Sum256([]byte(name))
+		clean = hex.EncodeToString(sum[:4])
+	}
+
+	return clean + sanitizeText(ext)
+}
+
+// sanitizeText lowercases text and drops the characters sanitizeSegment
+// leaves out of paths.
+func sanitizeText(text string) string {
+	var clean strings.Builder
+	for _, r := range strings.ToLower(text) {
+		if replacement, ok := transliterations[r]; ok && config.SanitizePaths.Transliterate {
+			clean.WriteString(replacement)
+			continue
+		}
+
+		switch {
+		case unicode.IsSpace(r):
+			clean.WriteRune('-')
+		case r < 0x20 || strings.ContainsRune(`<>:"/\|?*#%&{}^~[]'`+"`", r):
+		case r > unicode.MaxASCII && !unicode.IsLetter(r) && !unicode.IsDigit(r):
+		default:
+			clean.WriteRune(r)
+		}
+	}
+
+	text = clean.String()
+	for strings.Contains(text, "--") {
+		text = strings.ReplaceAll(text, "--", "-")
+	}
+
+	return strings.Trim(text, "-")
+}
+
+// sanitizeUrlPath applies sanitizeSegment to every segment of a url path
+// when path sanitization is enabled.
+func sanitizeUrlPath(u string) string {
+	if !config.SanitizePaths.Enabled {
+		return u
+	}
+
+	segments := strings.Split(u, "/")
+	for i, segment := range segments {
+		if segment != "" {
+			segments[i] = sanitizeSegment(segment)
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// sanitizeLink rewrites the path of a local link the same way the output
+// paths were rewritten, leaving external links alone.
+func sanitizeLink(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return link
+	}
+
+	u.Path = sanitizeUrlPath(u.Path)
+	u.RawPath = ""
+	return u.String()
+}
+
+func sanitizeLinks(doc *goquery.Document) {
+	doc.Find("[href], [src], [poster]").Each(func(_ int, element *goquery.Selection) {
+		for _, name := range []string{"href", "src", "poster"} {
+			if link, ok := element.Attr(name); ok {
+				element.SetAttr(name, sanitizeLink(link))
+			}
+		}
+	})
+
+	doc.Find("[srcset]").Each(func(_ int, element *goquery.Selection) {
+		srcset, _ := element.Attr("srcset")
+		candidates := strings.Split(srcset, ",")
+		for i, candidate := range candidates {
+			if fields := strings.Fields(candidate); len(fields) > 0 {
+				fields[0] = sanitizeLink(fields[0])
+				candidates[i] = strings.Join(fields, " ")
+			}
+		}
+		element.SetAttr("srcset", strings.Join(candidates, ", "))
+	})
+}
+
+// writePathRedirect leaves a page at the unsanitized location of a page
+// whose output path changed, pointing visitors to the new one.
+func writePathRedirect(path string) error {
+	rel, err := filepath.Rel(contentDirectory(), path)
+	if err != nil {
+		return err
+	}
+
+	original := "/" + filepath.ToSlash(rel)
+	sanitized := urlFromContentPath(path)
+	if original == sanitized || config.CaseInsensitiveOutput && strings.EqualFold(original, sanitized) {
+		return nil
+	}
+
+	target := filepath.Join(targetDirectory(), filepath.FromSlash(rel))
+	return writeRedirect(target, sanitized, "the redirect for "+path)
+}
+
+// writeAliasRedirect leaves a page at an old url of an article, listed in
+// its aliases, such as the permalink of the blog it was imported from.
+func writeAliasRedirect(alias string, to string) error {
+	u, err := url.Parse(alias)
+	if err != nil || u.Scheme != "" || u.Host != "" || !strings.HasPrefix(u.Path, "/") {
+		return fmt.Errorf("Alias %q of %s is not an absolute path", alias, to)
+	}
+
+	p := u.Path
+	if strings.HasSuffix(p, "/") || path.Ext(p) == "" {
+		p = strings.TrimSuffix(p, "/") + "/index.html"
+	}
+
+	target := targetPathFromUrl(p)
+	return writeRedirect(target, to, "the alias "+alias+" of "+to)
+}
+
+// writeRedirect writes a page at target sending visitors to the url to.
+func writeRedirect(target string, to string, owner string) error {
+	if err := claimOutput(target, owner); err != nil {
+		return err
+	}
+	if err := createDir(filepath.Dir(target)); err != nil {
+		return err
+	}
+
+	escaped := html.EscapeString(to)
+	page := fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="UTF-8">
+<meta http-equiv="refresh" content="0; url=%s">
+<link rel="canonical" href="%s">
+<meta name="robots" content="noindex">
+<title>Moved</title>
+</head>
+<body>
+<p>This page moved to <a href="%s">%s</a>.</p>
+</body>
+</html>
+`, escaped, escaped, escaped, escaped)
+
+	return writeOutputFile(target, []byte(page))
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestSanitizeSegment(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+
+	tests := []struct {
+		segment       string
+		transliterate bool
+		want          string
+	}{
+		{"My First Post.html", false, "my-first-post.html"},
+		{"what? why!.md", false, "what-why!.md"},
+		{"Café Crème", false, "café-crème"},
+		{"Café Crème", true, "cafe-creme"},
+		{"  spaced  ", false, "spaced"},
+		{".htaccess", false, ".htaccess"},
+		{"..", false, ".."},
+		{"???.html", false, "a03b221c.html"},
+		{"<>", false, "24295a9c"},
+		{"#%&", false, "2919771d"},
+	}
+
+	for _, test := range tests {
+		config.SanitizePaths.Transliterate = test.transliterate
+		if got := sanitizeSegment(test.segment); got != test.want {
+			t.Errorf("sanitizeSegment(%q) = %q, want %q", test.segment, got, test.want)
+		}
+	}
+}
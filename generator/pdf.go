@@ -0,0 +1,691 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+const (
+	pdfPageWidth  = 595.28
+	pdfPageHeight = 841.89
+	pdfMargin     = 60.0
+	pdfBodySize   = 11.0
+	pdfCodeSize   = 9.0
+	pdfListIndent = 18.0
+)
+
+// Widths of the printable ASCII range (32-126) in the standard Helvetica
+// fonts, in thousandths of the font size. The oblique variants share them.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+// winAnsiSpecials maps the characters WinAnsiEncoding places in 0x80-0x9F.
+var winAnsiSpecials = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E, '‘': 0x91,
+	'’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98,
+	'™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+var pdfSpecialWidths = map[byte][2]int{
+	0x85: {1000, 1000}, 0x91: {222, 278}, 0x92: {222, 278}, 0x93: {333, 500},
+	0x94: {333, 500}, 0x95: {350, 350}, 0x96: {556, 556}, 0x97: {1000, 1000},
+}
+
+type pdfStyle struct {
+	bold   bool
+	italic bool
+	mono   bool
+}
+
+func (s pdfStyle) font() string {
+	switch {
+	case s.mono:
+		return "F5"
+	case s.bold && s.italic:
+		return "F4"
+	case s.bold:
+		return "F2"
+	case s.italic:
+		return "F3"
+	default:
+		return "F1"
+	}
+}
+
+func (s pdfStyle) width(text string, size float64) float64 {
+	total := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case s.mono:
+			total += 600
+		case c >= 32 && c <= 126 && s.bold:
+			total += helveticaBoldWidths[c-32]
+		case c >= 32 && c <= 126:
+			total += helveticaWidths[c-32]
+		default:
+			widths, ok := pdfSpecialWidths[c]
+			switch {
+			case ok && s.bold:
+				total += widths[1]
+			case ok:
+				total += widths[0]
+			default:
+				total += 556
+			}
+		}
+	}
+
+	return float64(total) * size / 1000
+}
+
+// winAnsiByte is the byte WinAnsiEncoding gives a character, when it has one.
+func winAnsiByte(r rune) (byte, bool) {
+	if b, ok := winAnsiSpecials[r]; ok {
+		return b, true
+	}
+	if r == '\u00a0' {
+		return ' ', true
+	}
+	if r < 0x80 || r >= 0xa1 && r <= 0xff {
+		return byte(r), true
+	}
+	return 0, false
+}
+
+// winAnsi re-encodes text for the standard PDF fonts, replacing characters
+// they cannot display. Articles with such characters get no PDF, see
+// pdfUnsupported, so this only shows in text the generator adds.
+func winAnsi(text string) string {
+	var encoded strings.Builder
+	for _, r := range text {
+		if b, ok := winAnsiByte(r); ok {
+			encoded.WriteByte(b)
+		} else if !unicode.Is(unicode.Cf, r) {
+			encoded.WriteByte('?')
+		}
+	}
+
+	return encoded.String()
+}
+
+// pdfUnsupported lists the first few characters of text the standard PDF
+// fonts cannot show, such as Persian or CJK script.
+func pdfUnsupported(text string) []string {
+	var unsupported []string
+	for _, r := range text {
+		if _, ok := winAnsiByte(r); ok || unicode.Is(unicode.Cf, r) || unicode.IsSpace(r) {
+			continue
+		}
+		if !slices.Contains(unsupported, string(r)) {
+			unsupported = append(unsupported, string(r))
+		}
+		if len(unsupported) == 5 {
+			break
+		}
+	}
+
+	return unsupported
+}
+
+func pdfString(text string) string {
+	var escaped strings.Builder
+	escaped.WriteByte('(')
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			escaped.WriteByte('\\')
+			escaped.WriteByte(c)
+		case c >= 0x80:
+			fmt.Fprintf(&escaped, "\\%03o", c)
+		default:
+			escaped.WriteByte(c)
+		}
+	}
+	escaped.WriteByte(')')
+
+	return escaped.String()
+}
+
+func pdfNum(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+type pdfWord struct {
+	text  string
+	style pdfStyle
+	space bool
+	br    bool
+}
+
+type pdfImage struct {
+	width      int
+	height     int
+	filter     string
+	colorSpace string
+	data       []byte
+}
+
+type pdfBlock struct {
+	size   float64
+	indent float64
+	marker string
+	words  []pdfWord
+	code   []string
+	image  *pdfImage
+	rule   bool
+	// pageBreak starts a new page, and keep keeps the block on the page of
+	// the one after it, like break-before and break-after in print styles.
+	pageBreak bool
+	keep      bool
+}
+
+// pdfLayout flattens an article DOM into a list of blocks. Inline content
+// accumulates into the current block until a block-level element flushes it.
+type pdfLayout struct {
+	page    string
+	blocks  []pdfBlock
+	current pdfBlock
+	space   bool
+	marker  string
+}
+
+type pdfContext struct {
+	style  pdfStyle
+	size   float64
+	indent float64
+}
+
+func (l *pdfLayout) flush() {
+	if len(l.current.words) > 0 {
+		l.blocks = append(l.blocks, l.current)
+	}
+	l.current = pdfBlock{}
+	l.space = false
+}
+
+func (l *pdfLayout) addWord(word pdfWord, ctx pdfContext) {
+	if len(l.current.words) == 0 {
+		l.current.size = ctx.size
+		l.current.indent = ctx.indent
+		l.current.marker = l.marker
+		l.marker = ""
+		word.space = false
+	}
+	l.current.words = append(l.current.words, word)
+}
+
+func (l *pdfLayout) addText(text string, ctx pdfContext) {
+	if text == "" {
+		return
+	}
+
+	if strings.TrimLeft(text, " \t\r\n") != text {
+		l.space = true
+	}
+	for _, field := range strings.Fields(text) {
+		l.addWord(pdfWord{text: winAnsi(field), style: ctx.style, space: l.space}, ctx)
+		l.space = true
+	}
+	l.space = strings.TrimRight(text, " \t\r\n") != text
+}
+
+func (l *pdfLayout) walk(node *html.Node, ctx pdfContext) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		switch child.Type {
+		case html.TextNode:
+			l.addText(child.Data, ctx)
+		case html.ElementNode:
+			l.element(child, ctx)
+		}
+	}
+}
+
+// pdfHidden matches what the print stylesheet hides.
+var pdfHidden = []string{".comments", ".no-print", "#nav"}
+
+func (l *pdfLayout) element(node *html.Node, ctx pdfContext) {
+	selection := goquery.NewDocumentFromNode(node).Selection
+	for _, selector := range pdfHidden {
+		if selection.Is(selector) {
+			return
+		}
+	}
+	if selection.HasClass("print-break-before") {
+		l.flush()
+		l.blocks = append(l.blocks, pdfBlock{pageBreak: true})
+	}
+
+	switch node.Data {
+	case "script", "style", "iframe", "noscript", "object", "embed", "video", "audio":
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		l.flush()
+		ctx.size = map[string]float64{"h1": 20, "h2": 16, "h3": 13.5}[node.Data]
+		if ctx.size == 0 {
+			ctx.size = 12
+		}
+		ctx.style.bold = true
+		l.walk(node, ctx)
+		l.current.keep = selection.HasClass("print-keep-with-next")
+		l.flush()
+	case "strong", "b":
+		ctx.style.bold = true
+		l.walk(node, ctx)
+	case "em", "i", "cite":
+		ctx.style.italic = true
+		l.walk(node, ctx)
+	case "code", "kbd", "samp":
+		ctx.style.mono = true
+		l.walk(node, ctx)
+	case "br":
+		l.addWord(pdfWord{br: true}, ctx)
+		l.space = false
+	case "hr":
+		l.flush()
+		l.blocks = append(l.blocks, pdfBlock{rule: true})
+	case "pre":
+		l.flush()
+		text := strings.TrimRight(goquery.NewDocumentFromNode(node).Text(), "\n")
+		var lines []string
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, winAnsi(strings.ReplaceAll(line, "\t", "    ")))
+		}
+		l.blocks = append(l.blocks, pdfBlock{size: pdfCodeSize, indent: ctx.indent, code: lines})
+	case "img":
+		l.flush()
+		l.image(node, ctx)
+	case "ul", "ol":
+		l.flush()
+		ctx.indent += pdfListIndent
+		number := 0
+		for item := node.FirstChild; item != nil; item = item.NextSibling {
+			if item.Type != html.ElementNode {
+				continue
+			}
+			number++
+			l.flush()
+			if node.Data == "ol" {
+				l.marker = winAnsi(strconv.Itoa(number) + ".")
+			} else {
+				l.marker = winAnsi("•")
+			}
+			l.walk(item, ctx)
+			l.flush()
+		}
+	case "blockquote":
+		l.flush()
+		ctx.indent += pdfListIndent
+		ctx.style.italic = true
+		l.walk(node, ctx)
+		l.flush()
+	case "tr":
+		l.flush()
+		first := true
+		for cell := node.FirstChild; cell != nil; cell = cell.NextSibling {
+			if cell.Type != html.ElementNode {
+				continue
+			}
+			if !first {
+				l.addText(" | ", ctx)
+			}
+			first = false
+			cellCtx := ctx
+			cellCtx.style.bold = ctx.style.bold || cell.Data == "th"
+			l.walk(cell, cellCtx)
+		}
+		l.flush()
+	case "p", "div", "section", "article", "header", "footer", "figure", "figcaption",
+		"table", "thead", "tbody", "dl", "dt", "dd", "details", "summary", "aside", "main", "nav":
+		l.flush()
+		l.walk(node, ctx)
+		l.flush()
+	default:
+		l.walk(node, ctx)
+	}
+}
+
+func (l *pdfLayout) image(node *html.Node, ctx pdfContext) {
+	selection := goquery.NewDocumentFromNode(node).Selection
+	src, _ := selection.Attr("src")
+	alt, _ := selection.Attr("alt")
+
+	if path, ok := contentPathFromUrl(src, l.page); ok {
+		if img, err := loadPdfImage(path); err == nil {
+			l.blocks = append(l.blocks, pdfBlock{image: img, indent: ctx.indent})
+			return
+		}
+	}
+
+	if alt != "" {
+		ctx.style.italic = true
+		l.addText("["+alt+"]", ctx)
+		l.flush()
+	}
+}
+
+func loadPdfImage(path string) (*pdfImage, error) {
+	data, err := readImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// Baseline JPEGs can be embedded as they are.
+	if format == "jpeg" && (cfg.ColorModel == color.YCbCrModel || cfg.ColorModel == color.GrayModel) {
+		colorSpace := "DeviceRGB"
+		if cfg.ColorModel == color.GrayModel {
+			colorSpace = "DeviceGray"
+		}
+		return &pdfImage{width: cfg.Width, height: cfg.Height, filter: "DCTDecode", colorSpace: colorSpace, data: data}, nil
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := decoded.Bounds()
+	canvas := image.NewRGBA(bounds)
+	draw.Draw(canvas, bounds, image.White, image.Point{}, draw.Src)
+	draw.Draw(canvas, bounds, decoded, bounds.Min, draw.Over)
+
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	for i := 0; i < len(canvas.Pix); i += 4 {
+		writer.Write(canvas.Pix[i : i+3])
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return &pdfImage{
+		width:      bounds.Dx(),
+		height:     bounds.Dy(),
+		filter:     "FlateDecode",
+		colorSpace: "DeviceRGB",
+		data:       compressed.Bytes(),
+	}, nil
+}
+
+// pdfRenderer places blocks onto A4 pages, starting a new page whenever the
+// next line or image does not fit.
+type pdfRenderer struct {
+	pages  []*bytes.Buffer
+	images []*pdfImage
+	y      float64
+}
+
+func (r *pdfRenderer) page() *bytes.Buffer {
+	return r.pages[len(r.pages)-1]
+}
+
+func (r *pdfRenderer) newPage() {
+	r.pages = append(r.pages, &bytes.Buffer{})
+	r.y = pdfPageHeight - pdfMargin
+}
+
+func (r *pdfRenderer) reserve(height float64) {
+	if len(r.pages) == 0 || r.y-height < pdfMargin {
+		r.newPage()
+	}
+	r.y -= height
+}
+
+func (r *pdfRenderer) text(x float64, style pdfStyle, size float64, text string) {
+	fmt.Fprintf(r.page(), "BT /%s %s Tf %s %s Td %s Tj ET\n",
+		style.font(), pdfNum(size), pdfNum(x), pdfNum(r.y), pdfString(text))
+}
+
+func (r *pdfRenderer) lines(block pdfBlock, width float64) [][]pdfWord {
+	var lines [][]pdfWord
+	var line []pdfWord
+	lineWidth := 0.0
+	for _, word := range block.words {
+		if word.br {
+			lines = append(lines, line)
+			line, lineWidth = nil, 0
+			continue
+		}
+
+		w := word.style.width(word.text, block.size)
+		if word.space && len(line) > 0 {
+			w += word.style.width(" ", block.size)
+		}
+		if len(line) > 0 && lineWidth+w > width {
+			lines = append(lines, line)
+			line, lineWidth = nil, 0
+			w = word.style.width(word.text, block.size)
+		}
+		line = append(line, word)
+		lineWidth += w
+	}
+
+	return append(lines, line)
+}
+
+func (r *pdfRenderer) block(block pdfBlock) {
+	x := pdfMargin + block.indent
+	width := pdfPageWidth - pdfMargin - x
+
+	switch {
+	case block.pageBreak:
+		if len(r.pages) > 0 && r.y < pdfPageHeight-pdfMargin {
+			r.newPage()
+		}
+	case block.rule:
+		r.reserve(12)
+		fmt.Fprintf(r.page(), "0.6 G 0.5 w %s %s m %s %s l S 0 G\n",
+			pdfNum(x), pdfNum(r.y+6), pdfNum(x+width), pdfNum(r.y+6))
+	case block.image != nil:
+		w := float64(block.image.width) * 0.75
+		h := float64(block.image.height) * 0.75
+		maxHeight := (pdfPageHeight - 2*pdfMargin) * 0.8
+		scale := min(1, width/w, maxHeight/h)
+		w, h = w*scale, h*scale
+		r.reserve(h)
+		r.images = append(r.images, block.image)
+		fmt.Fprintf(r.page(), "q %s 0 0 %s %s %s cm /Im%d Do Q\n",
+			pdfNum(w), pdfNum(h), pdfNum(x), pdfNum(r.y), len(r.images))
+		r.y -= pdfBodySize
+	case block.code != nil:
+		mono := pdfStyle{mono: true}
+		perLine := max(1, int(width/mono.width(" ", block.size)))
+		for _, line := range block.code {
+			for {
+				chunk := line
+				if len(chunk) > perLine {
+					chunk = line[:perLine]
+				}
+				r.reserve(block.size * 1.35)
+				r.text(x, mono, block.size, chunk)
+				line = line[len(chunk):]
+				if line == "" {
+					break
+				}
+			}
+		}
+		r.y -= pdfBodySize * 0.6
+	default:
+		leading := block.size * 1.35
+		if block.size > pdfBodySize {
+			r.y -= block.size * 0.5
+		}
+		lines := r.lines(block, width)
+		// A block kept with the next one moves to the next page when two
+		// lines of body text would not fit under it.
+		if block.keep && len(r.pages) > 0 && r.y-float64(len(lines))*leading-2*pdfBodySize*1.35 < pdfMargin {
+			r.newPage()
+		}
+		for i, line := range lines {
+			r.reserve(leading)
+			if i == 0 && block.marker != "" {
+				marker := pdfStyle{}
+				r.text(x-marker.width(block.marker+" ", block.size), marker, block.size, block.marker)
+			}
+
+			cursor := x
+			for j, word := range line {
+				if word.space && j > 0 {
+					cursor += word.style.width(" ", block.size)
+				}
+				r.text(cursor, word.style, block.size, word.text)
+				cursor += word.style.width(word.text, block.size)
+			}
+		}
+		r.y -= block.size * 0.6
+	}
+}
+
+func (r *pdfRenderer) document(title string) []byte {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string, stream []byte) int {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\n", len(offsets), body)
+		if stream != nil {
+			out.WriteString("stream\n")
+			out.Write(stream)
+			out.WriteString("\nendstream\n")
+		}
+		out.WriteString("endobj\n")
+		return len(offsets)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	offsets = append(offsets, -1) // the page tree is written last
+
+	var fonts strings.Builder
+	for i, name := range []string{"Helvetica", "Helvetica-Bold", "Helvetica-Oblique", "Helvetica-BoldOblique", "Courier"} {
+		id := object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name), nil)
+		fmt.Fprintf(&fonts, "/F%d %d 0 R ", i+1, id)
+	}
+
+	var xobjects strings.Builder
+	for i, img := range r.images {
+		id := object(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /%s /BitsPerComponent 8 /Filter /%s /Length %d >>",
+			img.width, img.height, img.colorSpace, img.filter, len(img.data)), img.data)
+		fmt.Fprintf(&xobjects, "/Im%d %d 0 R ", i+1, id)
+	}
+
+	var kids []string
+	for i, page := range r.pages {
+		fmt.Fprintf(page, "BT /F1 9 Tf %s %s Td (%d) Tj ET\n", pdfNum(pdfPageWidth/2), pdfNum(pdfMargin/2), i+1)
+
+		var compressed bytes.Buffer
+		writer := zlib.NewWriter(&compressed)
+		writer.Write(page.Bytes())
+		writer.Close()
+
+		contents := object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>", compressed.Len()), compressed.Bytes())
+		id := object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << %s>> /XObject << %s>> >> /Contents %d 0 R >>",
+			pdfNum(pdfPageWidth), pdfNum(pdfPageHeight), fonts.String(), xobjects.String(), contents), nil)
+		kids = append(kids, fmt.Sprintf("%d 0 R", id))
+	}
+
+	offsets[1] = out.Len()
+	fmt.Fprintf(&out, "2 0 obj\n<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", strings.Join(kids, " "), len(kids))
+	info := object(fmt.Sprintf("<< /Title %s /Producer (site-generator) >>", pdfString(winAnsi(title))), nil)
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, info, xref)
+
+	return out.Bytes()
+}
+
+// renderArticlePdf lays out an article the way the print stylesheet shows
+// it: with the print-only markup, without what it hides and with its page
+// breaks.
+func renderArticlePdf(art article) ([]byte, error) {
+	content, err := addPrintMarkup(art.content)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse %s: %w", art.source, err)
+	}
+
+	layout := pdfLayout{page: art.source}
+	for _, node := range doc.Find("body").Nodes {
+		layout.walk(node, pdfContext{size: pdfBodySize})
+	}
+	layout.flush()
+
+	renderer := pdfRenderer{}
+	renderer.newPage()
+	for _, block := range layout.blocks {
+		renderer.block(block)
+	}
+
+	return renderer.document(art.title), nil
+}
+
+func pdfUrl(art article) string {
+	return art.url[:strings.LastIndex(art.url, "/")+1] + "article.pdf"
+}
+
+// writeArticlePdf writes the PDF of an article and reports whether it did.
+// The standard PDF fonts only cover Western European text, so an article in
+// another script is left without a PDF rather than given an unreadable one.
+func writeArticlePdf(art article) (bool, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(art.content))
+	if err != nil {
+		return false, fmt.Errorf("Failed to parse %s: %w", art.source, err)
+	}
+	if unsupported := pdfUnsupported(art.title + " " + doc.Find("body").Text()); len(unsupported) > 0 {
+		addDiagnostic("pdf", art.source, 0, "No PDF, the PDF fonts cannot show %s", strings.Join(unsupported, " "))
+		return false, nil
+	}
+
+	pdf, err := renderArticlePdf(art)
+	if err != nil {
+		return false, err
+	}
+
+	pdfPath := filepath.Join(filepath.Dir(targetPathFromContentPath(art.source)), "article.pdf")
+	if err := recordOutput(pdfPath, art.source); err != nil {
+		return false, err
+	}
+	return true, writeOutputFile(pdfPath, pdf)
+}
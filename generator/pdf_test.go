@@ -0,0 +1,40 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWinAnsi(t *testing.T) {
+	tests := map[string]string{
+		"plain":           "plain",
+		"café":            "caf\xe9",
+		"“quoted” – €5":   "\x93quoted\x94 \x96 \x805",
+		"zero\u200bwidth": "zerowidth",
+		"a→b":             "a?b",
+	}
+
+	for text, want := range tests {
+		if got := winAnsi(text); got != want {
+			t.Errorf("winAnsi(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestPdfUnsupported(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Déjà vu — “fine”", nil},
+		{"سلام سلام", []string{"س", "ل", "ا", "م"}},
+		{"日本語の文章です", []string{"日", "本", "語", "の", "文"}},
+		{"non\u00a0breaking\u200cjoiner", nil},
+	}
+
+	for _, test := range tests {
+		if got := pdfUnsupported(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("pdfUnsupported(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
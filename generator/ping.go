@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type pingConfig struct {
+	Enabled   bool     `json:"enabled"`
+	Endpoints []string `json:"endpoints"`
+	Hub       string   `json:"hub"`
+	Publish   bool     `json:"publish"`
+}
+
+// writtenFeeds lists the url of every feed of the build, for the hub.
+var writtenFeeds []string
+
+// websubHub returns the hub the feeds declare, empty when there is none.
+func websubHub() string {
+	if !config.Ping.Enabled {
+		return ""
+	}
+
+	return config.Ping.Hub
+}
+
+func sendPing(method string, link string, form url.Values) error {
+	if err := requireNetwork("update pings", link); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(method, link, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "site-generator")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", link, resp.Status)
+	}
+	return nil
+}
+
+// announceUpdates tells the configured endpoints that the site changed. An
+// endpoint gets the url of the sitemap in place of {sitemap}, and the hub is
+// asked to push the given feeds to their subscribers. Failures are only reported,
+// since the site itself is already built. Noindex builds are previews and
+// announce nothing.
+func announceUpdates(feeds []string) {
+	if config.Noindex {
+		return
+	}
+	if config.BaseUrl == "" {
+		fmt.Fprintln(os.Stderr, "Cannot ping without base_url")
+		return
+	}
+
+	sitemap := url.QueryEscape(absoluteUrl("/sitemap.xml", "/"))
+	for _, endpoint := range config.Ping.Endpoints {
+		if !config.Sitemap && strings.Contains(endpoint, "{sitemap}") {
+			continue
+		}
+		if err := sendPing("GET", strings.ReplaceAll(endpoint, "{sitemap}", sitemap), nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Ping failed: %s\n", err)
+		}
+	}
+
+	if config.Ping.Hub != "" {
+		for _, feed := range feeds {
+			form := url.Values{"hub.mode": {"publish"}, "hub.url": {absoluteUrl(feed, "/")}}
+			if err := sendPing("POST", config.Ping.Hub, form); err != nil {
+				fmt.Fprintf(os.Stderr, "Hub notification failed: %s\n", err)
+			}
+		}
+	}
+}
+
+// runPublish announces a site after it was deployed: it pings and posts
+// the new articles to social networks. It reads what was deployed from the
+// manifest of the build, since hubs fetch a feed as soon as they are
+// notified and must not see the previous one.
+func runPublish(args []string) error {
+	flags := flag.NewFlagSet("publish", flag.ContinueOnError)
+	flags.StringVar(&buildEnvironment, "env", buildEnvironment, "config environment to publish")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	if !config.Ping.Enabled && !config.Crosspost.Enabled {
+		return fmt.Errorf("Publishing needs ping or crosspost to be enabled")
+	}
+
+	data, err := os.ReadFile(filepath.Join(targetDirectory(), "manifest.json"))
+	if err != nil {
+		return fmt.Errorf("Cannot read the manifest of the build: %w", err)
+	}
+
+	var manifest struct {
+		Files []manifestEntry `json:"files"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("Invalid manifest: %w", err)
+	}
+
+	var feeds []string
+	deployed := make(map[string]bool)
+	for _, file := range manifest.Files {
+		if file.Type == "feed" && file.Path != "/sitemap.xml" || file.Path == articlesJsonUrl {
+			feeds = append(feeds, file.Path)
+		}
+		deployed[file.Path] = true
+	}
+
+	if config.Ping.Enabled {
+		announceUpdates(feeds)
+	}
+	if config.Crosspost.Enabled {
+		return crosspostArticles(deployed)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type audioInfo struct {
+	File     string `json:"file"`
+	Duration string `json:"duration"`
+}
+
+type podcastConfig struct {
+	Enabled     bool   `json:"enabled"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Author      string `json:"author"`
+	Email       string `json:"email"`
+	Image       string `json:"image"`
+	Category    string `json:"category"`
+	Explicit    bool   `json:"explicit"`
+}
+
+const podcastUrl = "/podcast.xml"
+
+// articleAudio resolves the audio file of an article to its content path
+// and url.
+func articleAudio(art article) (string, string, bool) {
+	if art.metadata.Audio == nil || art.metadata.Audio.File == "" {
+		return "", "", false
+	}
+
+	path, ok := contentPathFromUrl(art.metadata.Audio.File, art.source)
+	if !ok {
+		return "", art.metadata.Audio.File, true
+	}
+	return path, urlFromContentPath(path), true
+}
+
+// addAudioPlayer puts a player for the narration right below the article
+// metadata.
+func addAudioPlayer(art *article) error {
+	_, u, ok := articleAudio(*art)
+	if !ok {
+		return nil
+	}
+
+	caption := "Listen to this article"
+	if art.metadata.Audio.Duration != "" {
+		caption += " (" + art.metadata.Audio.Duration + ")"
+	}
+	player := fmt.Sprintf(`<figure class="audio-player"><audio controls preload="none" src="%s"></audio><figcaption>%s</figcaption></figure>`,
+		html.EscapeString(u), html.EscapeString(caption))
+
+	content, err := modifyHtml(art.content, func(doc *goquery.Document) {
+		doc.Find(".article-info").First().AfterHtml(player)
+	})
+	if err != nil {
+		return err
+	}
+
+	art.content = content
+	return nil
+}
+
+func writePodcastFeed() error {
+	if config.BaseUrl == "" {
+		return fmt.Errorf("The podcast feed needs base_url to be set")
+	}
+
+	tmplDoc, err := templateDocument()
+	if err != nil {
+		return err
+	}
+
+	var episodes []article
+	for _, art := range articles {
+		if _, _, ok := articleAudio(art); ok && !art.metadata.ExcludeFeed {
+			episodes = append(episodes, art)
+		}
+	}
+	sort.Slice(episodes, func(i, j int) bool {
+		return episodes[i].date.After(episodes[j].date)
+	})
+
+	podcast := config.Podcast
+	if podcast.Title == "" {
+		podcast.Title = siteTitle(tmplDoc)
+	}
+	if podcast.Author == "" {
+		podcast.Author = siteTitle(tmplDoc)
+	}
+
+	var items strings.Builder
+	for _, art := range episodes {
+		path, u, _ := articleAudio(art)
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("Cannot read audio of %s: %w", art.source, err)
+		}
+
+		mediaType := mime.TypeByExtension(filepath.Ext(path))
+		if mediaType == "" {
+			mediaType = "audio/mpeg"
+		}
+
+		content, err := feedContent(art)
+		if err != nil {
+			return err
+		}
+
+		link := absoluteUrl(art.url, "/")
+		fmt.Fprintf(&items, "    <item>\n      <title>%s</title>\n      <link>%s</link>\n      <guid>%s</guid>\n",
+			xmlEscape(art.title), xmlEscape(link), xmlEscape(link))
+		fmt.Fprintf(&items, "      <pubDate>%s</pubDate>\n", art.date.Format(time.RFC1123Z))
+		fmt.Fprintf(&items, "      <enclosure url=\"%s\" length=\"%d\" type=\"%s\"/>\n",
+			xmlEscape(absoluteUrl(u, "/")), info.Size(), mediaType)
+		if art.metadata.Audio.Duration != "" {
+			fmt.Fprintf(&items, "      <itunes:duration>%s</itunes:duration>\n", xmlEscape(art.metadata.Audio.Duration))
+		}
+		fmt.Fprintf(&items, "      <description>%s</description>\n    </item>\n", xmlEscape(content))
+	}
+
+	var channel strings.Builder
+	fmt.Fprintf(&channel, "    <itunes:author>%s</itunes:author>\n", xmlEscape(podcast.Author))
+	fmt.Fprintf(&channel, "    <itunes:explicit>%t</itunes:explicit>\n", podcast.Explicit)
+	if podcast.Image != "" {
+		fmt.Fprintf(&channel, "    <itunes:image href=\"%s\"/>\n", xmlEscape(absoluteUrl(podcast.Image, "/")))
+	}
+	if podcast.Category != "" {
+		fmt.Fprintf(&channel, "    <itunes:category text=\"%s\"/>\n", xmlEscape(podcast.Category))
+	}
+	if websubHub() != "" {
+		fmt.Fprintf(&channel, "    <atom:link rel=\"hub\" href=\"%s\"/>\n", xmlEscape(websubHub()))
+	}
+	if podcast.Email != "" {
+		fmt.Fprintf(&channel, "    <itunes:owner><itunes:name>%s</itunes:name><itunes:email>%s</itunes:email></itunes:owner>\n",
+			xmlEscape(podcast.Author), xmlEscape(podcast.Email))
+	}
+
+	document := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:atom="http://www.w3.org/2005/Atom">
+  <channel>
+    <title>%s</title>
+    <link>%s</link>
+    <description>%s</description>
+    <language>%s</language>
+    <atom:link rel="self" type="application/rss+xml" href="%s"/>
+%s%s  </channel>
+</rss>
+`, xmlEscape(podcast.Title), xmlEscape(absoluteUrl("/", "/")), xmlEscape(podcast.Description),
+		xmlEscape(siteLanguage(tmplDoc)), xmlEscape(absoluteUrl(podcastUrl, "/")), channel.String(), items.String())
+
+	target := targetPathFromUrl(podcastUrl)
+	if err := claimOutput(target, "the podcast feed"); err != nil {
+		return err
+	}
+	writtenFeeds = append(writtenFeeds, podcastUrl)
+	return writeOutputFile(target, []byte(document))
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// sourcePosition is a line and column in a file, both counted from 1.
+type sourcePosition struct {
+	line   int
+	column int
+}
+
+func (p sourcePosition) String() string {
+	return fmt.Sprintf("%d:%d", p.line, p.column)
+}
+
+// positionIn turns a byte offset into text into a line and column.
+func positionIn(text string, offset int) sourcePosition {
+	offset = min(max(offset, 0), len(text))
+	before := text[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := utf8.RuneCountInString(before[strings.LastIndex(before, "\n")+1:]) + 1
+
+	return sourcePosition{line: line, column: column}
+}
+
+// sourceError is a problem found at a place in a file. Without a position it
+// only names the file.
+type sourceError struct {
+	path     string
+	position *sourcePosition
+	err      error
+}
+
+func (e *sourceError) Error() string {
+	if e.position == nil {
+		return fmt.Sprintf("%s: %s", e.path, e.err)
+	}
+	return fmt.Sprintf("%s:%s: %s", e.path, e.position, e.err)
+}
+
+func (e *sourceError) Unwrap() error {
+	return e.err
+}
+
+// locate finds a directive in a source file. Converters may have wrapped it
+// in a paragraph or escaped its quotes, so both are undone first. The text
+// it was found in is searched only when the file cannot be read, since the
+// lines of a converted source are not the lines of the file.
+func locate(source string, text string, directive string) (sourcePosition, bool) {
+	directive = strings.TrimSpace(directive)
+	directive = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(directive, "<p>"), "</p>"))
+
+	if data, err := os.ReadFile(source); err == nil {
+		text = string(data)
+	}
+	for _, needle := range []string{directive, html.UnescapeString(directive)} {
+		if i := strings.Index(text, needle); i >= 0 {
+			return positionIn(text, i), true
+		}
+	}
+
+	return sourcePosition{}, false
+}
+
+// directiveError points an error at the directive in the source that caused
+// it.
+func directiveError(source string, text string, directive string, err error) error {
+	if position, ok := locate(source, text, directive); ok {
+		return &sourceError{path: source, position: &position, err: err}
+	}
+	return &sourceError{path: source, err: err}
+}
+
+// describeAt names a directive along with where it is in the source, for
+// errors that list several of them.
+func describeAt(source string, text string, directive string, name string) string {
+	if position, ok := locate(source, text, directive); ok {
+		return fmt.Sprintf("%s (%s)", name, position)
+	}
+	return name
+}
+
+// jsonError points a decoding error of a JSON file at its position when the
+// decoder knows it.
+func jsonError(path string, data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	offset := int64(-1)
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+	if offset < 0 {
+		return &sourceError{path: path, err: err}
+	}
+
+	position := positionIn(string(data), int(offset))
+	return &sourceError{path: path, position: &position, err: err}
+}
+
+// elementSelector describes where an element is in a page, like
+// "article > p:nth-child(3) > img", for errors about a single element.
+func elementSelector(element *goquery.Selection) string {
+	var parts []string
+	for node := element; node.Length() > 0 && !node.Is("body, html"); node = node.Parent() {
+		part := goquery.NodeName(node)
+		if id, ok := node.Attr("id"); ok && id != "" {
+			parts = append(parts, part+"#"+id)
+			break
+		}
+		if node.Parent().Children().Length() > 1 {
+			part += fmt.Sprintf(":nth-child(%d)", node.Index()+1)
+		}
+		parts = append(parts, part)
+	}
+
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return strings.Join(parts, " > ")
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Tables longer than this are left out of home page previews.
+const previewTableRows = 10
+
+// sourceLanguage returns the lang and dir attributes set on the html or body
+// element of a source file.
+func sourceLanguage(path string) (string, string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", ""
+	}
+	defer file.Close()
+
+	doc, err := goquery.NewDocumentFromReader(file)
+	if err != nil {
+		return "", ""
+	}
+
+	var lang, dir string
+	for _, selector := range []string{"html", "body"} {
+		if value, ok := doc.Find(selector).Attr("lang"); ok && value != "" {
+			lang = value
+		}
+		if value, ok := doc.Find(selector).Attr("dir"); ok && value != "" {
+			dir = value
+		}
+	}
+
+	return lang, dir
+}
+
+func removeEventHandlers(doc *goquery.Document) {
+	doc.Find("*").Each(func(_ int, element *goquery.Selection) {
+		for _, node := range element.Nodes {
+			attrs := node.Attr[:0]
+			for _, attr := range node.Attr {
+				if !strings.HasPrefix(attr.Key, "on") {
+					attrs = append(attrs, attr)
+				}
+			}
+			node.Attr = attrs
+		}
+	})
+}
+
+// scopeIds prefixes every id in the content, and the references to them, so
+// several articles can share one listing page without their ids colliding.
+func scopeIds(doc *goquery.Document, prefix string) {
+	ids := make(map[string]bool)
+	doc.Find("[id]").Each(func(_ int, element *goquery.Selection) {
+		id, _ := element.Attr("id")
+		ids[id] = true
+		element.SetAttr("id", prefix+id)
+	})
+
+	doc.Find(`a[href^="#"]`).Each(func(_ int, link *goquery.Selection) {
+		href, _ := link.Attr("href")
+		if id := strings.TrimPrefix(href, "#"); ids[id] {
+			link.SetAttr("href", "#"+prefix+id)
+		}
+	})
+
+	doc.Find("[for], [aria-labelledby], [aria-describedby], [aria-controls], [headers], [list]").Each(func(_ int, element *goquery.Selection) {
+		for _, name := range []string{"for", "aria-labelledby", "aria-describedby", "aria-controls", "headers", "list"} {
+			value, ok := element.Attr(name)
+			if !ok {
+				continue
+			}
+
+			refs := strings.Fields(value)
+			for i, id := range refs {
+				if ids[id] {
+					refs[i] = prefix + id
+				}
+			}
+			element.SetAttr(name, strings.Join(refs, " "))
+		}
+	})
+}
+
+// articlePreview renders the part of an article shown on the home page. It
+// keeps the text but leaves out what is too heavy or unsafe to repeat there.
+func articlePreview(art article) (string, error) {
+	if config.SummaryPreviews {
+		content, err := summaryPreview(art)
+		if err != nil {
+			return "", err
+		}
+		art.content = content
+	}
+
+	return modifyHtml(art.content, func(doc *goquery.Document) {
+		resolveBundleLinks(doc.Selection, art.source)
+
+		doc.Find("script, noscript, style, link, template").Remove()
+
+		doc.Find("iframe, object, embed, video, audio").Each(func(_ int, media *goquery.Selection) {
+			media.ReplaceWithHtml(fmt.Sprintf(`<p class="preview-omitted"><a href="%s">Embedded media</a></p>`,
+				html.EscapeString(art.url)))
+		})
+
+		doc.Find("table").Each(func(_ int, table *goquery.Selection) {
+			if table.Find("tr").Length() > previewTableRows {
+				table.ReplaceWithHtml(fmt.Sprintf(`<p class="preview-omitted"><a href="%s">Table</a></p>`,
+					html.EscapeString(art.url)))
+			}
+		})
+
+		removeEventHandlers(doc)
+
+		scopeIds(doc, path.Base(path.Dir(art.url))+"-")
+
+		doc.Find("h1").First().SetHtml(fmt.Sprintf(`<a class="article-title-link" href="%s">%s</a>`,
+			html.EscapeString(art.url), html.EscapeString(art.title)))
+
+		body := doc.Find("body")
+		body.WrapInnerHtml(`<article class="article-preview"></article>`)
+		preview := body.Children().First()
+		if art.lang != "" {
+			preview.SetAttr("lang", art.lang)
+		}
+		if art.dir != "" {
+			preview.SetAttr("dir", art.dir)
+		}
+		if config.Microformats.Enabled {
+			markEntry(preview)
+		}
+	})
+}
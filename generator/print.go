@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type printConfig struct {
+	Enabled       bool              `json:"enabled"`
+	Abbreviations map[string]string `json:"abbreviations"`
+	BreakBefore   []string          `json:"break_before"`
+}
+
+// addPrintMarkup adds markup that is hidden on screen and only shows up when
+// the page is printed: expanded abbreviations, numbered link references with
+// a list of sources, and page-break hints around headings.
+func addPrintMarkup(content string) (string, error) {
+	return modifyHtml(content, func(doc *goquery.Document) {
+		doc.Find("abbr").Each(func(_ int, abbr *goquery.Selection) {
+			title, ok := abbr.Attr("title")
+			if !ok {
+				title, ok = config.Print.Abbreviations[strings.TrimSpace(abbr.Text())]
+				if !ok {
+					return
+				}
+				abbr.SetAttr("title", title)
+			}
+			abbr.AfterHtml(fmt.Sprintf(`<span class="print-only"> (%s)</span>`, html.EscapeString(title)))
+		})
+
+		var sources []string
+		doc.Find("a[href]:not(.archived-copy)").Each(func(_ int, link *goquery.Selection) {
+			href, _ := link.Attr("href")
+			u, err := url.Parse(href)
+			if err != nil || u.Scheme != "http" && u.Scheme != "https" {
+				return
+			}
+
+			index := slices.Index(sources, href)
+			if index == -1 {
+				sources = append(sources, href)
+				index = len(sources) - 1
+			}
+			link.AfterHtml(fmt.Sprintf(`<sup class="print-only">[%d]</sup>`, index+1))
+		})
+
+		if len(sources) > 0 {
+			var list strings.Builder
+			for _, source := range sources {
+				fmt.Fprintf(&list, "<li>%s</li>", html.EscapeString(source))
+			}
+			doc.Find("body").AppendHtml(fmt.Sprintf(
+				`<section class="print-only print-sources"><h2>Sources</h2><ol>%s</ol></section>`, list.String()))
+		}
+
+		doc.Find("h1, h2, h3, h4, h5, h6").Each(func(_ int, heading *goquery.Selection) {
+			heading.AddClass("print-keep-with-next")
+			if slices.Contains(config.Print.BreakBefore, goquery.NodeName(heading)) {
+				heading.AddClass("print-break-before")
+			}
+		})
+	})
+}
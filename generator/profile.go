@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+var profileExtensions = map[string]string{"cpu": ".pprof", "mem": ".pprof", "trace": ".out"}
+
+// profilePath is where a profile of the given kind is written: the path
+// asked for, or else a new file in the temporary directory, so profiling
+// leaves nothing behind in the directory the build runs in.
+func profilePath(kind string, path string) (string, error) {
+	extension, ok := profileExtensions[kind]
+	if !ok {
+		return "", fmt.Errorf("Unknown profile %q, expected cpu, mem or trace", kind)
+	}
+	if path != "" {
+		return path, nil
+	}
+
+	file, err := os.CreateTemp("", "site-"+kind+"-*"+extension)
+	if err != nil {
+		return "", err
+	}
+	return file.Name(), file.Close()
+}
+
+// startProfile starts recording a cpu profile, a heap profile or an
+// execution trace into path, or into a temporary file when path is empty.
+// The returned function finishes the recording.
+func startProfile(kind string, path string) (func() error, error) {
+	if kind == "" {
+		return func() error { return nil }, nil
+	}
+
+	path, err := profilePath(kind, path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	written := func() error {
+		if err := file.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("Profile written to %s\n", path)
+		return nil
+	}
+
+	switch kind {
+	case "cpu":
+		if err := pprof.StartCPUProfile(file); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return func() error {
+			pprof.StopCPUProfile()
+			return written()
+		}, nil
+	case "mem":
+		return func() error {
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(file); err != nil {
+				file.Close()
+				return err
+			}
+			return written()
+		}, nil
+	default:
+		if err := trace.Start(file); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return func() error {
+			trace.Stop()
+			return written()
+		}, nil
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	queryDirective = regexp.MustCompile(`\{\{<\s*query\s+(\w+)((?:\s+\w+="[^"]*")*)\s*>\}\}`)
+	queryArgument  = regexp.MustCompile(`(\w+)="([^"]*)"`)
+)
+
+// siteEntry is what queries know about a page. The index is read before the
+// build so every page, including the ones built first, sees all of the site.
+type siteEntry struct {
+	source  string
+	title   string
+	url     string
+	date    time.Time
+	updated time.Time
+	tags    []string
+	series  string
+	weight  int
+}
+
+var (
+	siteArticles []siteEntry
+	sitePages    []siteEntry
+)
+
+// loadSiteIndex reads the title, date and taxonomies of every page that is
+// going to be built.
+func loadSiteIndex() error {
+	siteArticles, sitePages = nil, nil
+
+	return filepath.WalkDir(contentDirectory(), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && (isFragmentPath(path) || isRawPath(path)) {
+			return filepath.SkipDir
+		}
+		if isExcludedPath(path) {
+			return skipExcluded(entry)
+		}
+		if entry.IsDir() || !isSourcePath(path) {
+			return nil
+		}
+		if (isDraft(path) || isHeldBack(path)) && !config.Drafts {
+			return nil
+		}
+
+		title, err := pageTitle(path)
+		if err != nil {
+			return skipFailure(path, err)
+		}
+
+		if !isArticlePath(path) {
+			sitePages = append(sitePages, siteEntry{source: path, title: title, url: urlFromContentPath(path)})
+			return nil
+		}
+
+		metadata, err := getArticleMetadata(filepath.Dir(path))
+		if err != nil {
+			return skipFailure(path, err)
+		}
+		date, err := time.Parse("2006-01-02", metadata.ReleaseDate)
+		if err != nil {
+			return skipFailure(path, fmt.Errorf("Invalid date found in %s: %s", path, metadata.ReleaseDate))
+		}
+		updated, err := lastUpdated(date, metadata.Changelog, path)
+		if err != nil {
+			return skipFailure(path, err)
+		}
+
+		var tags []string
+		for _, tag := range metadata.Tags {
+			if tag = canonicalTag(tag); tag != "" && !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+
+		siteArticles = append(siteArticles, siteEntry{
+			source:  path,
+			title:   title,
+			url:     urlFromContentPath(path),
+			date:    date,
+			updated: updated,
+			tags:    tags,
+			series:  metadata.Series,
+			weight:  metadata.Weight,
+		})
+		return nil
+	})
+}
+
+// queryArticles filters and sorts the articles with the arguments of a query:
+// tag, series, section and year filter, sort picks date, updated, title or
+// weight with a leading - for descending order, limit caps the result.
+func queryArticles(args map[string]string) ([]siteEntry, error) {
+	var section *sectionConfig
+	if name, ok := args["section"]; ok {
+		found, ok := findSection(name)
+		if !ok {
+			return nil, fmt.Errorf("Unknown section %q", name)
+		}
+		section = &found
+	}
+
+	var list []siteEntry
+	for _, entry := range siteArticles {
+		if tag, ok := args["tag"]; ok && !slices.Contains(entry.tags, canonicalTag(tag)) {
+			continue
+		}
+		if series, ok := args["series"]; ok && entry.series != series {
+			continue
+		}
+		if year, ok := args["year"]; ok && strconv.Itoa(entry.date.Year()) != year {
+			continue
+		}
+		if section != nil && !inSection(article{metadata: articleInfo{Tags: entry.tags, Series: entry.series}}, *section) {
+			continue
+		}
+		list = append(list, entry)
+	}
+
+	if err := sortEntries(list, args["sort"], "-date"); err != nil {
+		return nil, err
+	}
+	return limitEntries(list, args["limit"])
+}
+
+func sortEntries(list []siteEntry, order string, fallback string) error {
+	if order == "" {
+		order = fallback
+	}
+
+	descending := strings.HasPrefix(order, "-")
+	var less func(a, b siteEntry) bool
+	switch strings.TrimPrefix(order, "-") {
+	case "date":
+		less = func(a, b siteEntry) bool { return a.date.Before(b.date) }
+	case "updated":
+		less = func(a, b siteEntry) bool { return a.updated.Before(b.updated) }
+	case "title":
+		less = func(a, b siteEntry) bool { return strings.ToLower(a.title) < strings.ToLower(b.title) }
+	case "weight":
+		less = func(a, b siteEntry) bool { return a.weight < b.weight }
+	default:
+		return fmt.Errorf("Unknown sort order %q", order)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		if descending {
+			return less(list[j], list[i])
+		}
+		return less(list[i], list[j])
+	})
+	return nil
+}
+
+func limitEntries(list []siteEntry, limit string) ([]siteEntry, error) {
+	if limit == "" {
+		return list, nil
+	}
+
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("Invalid limit %q", limit)
+	}
+	return list[:min(n, len(list))], nil
+}
+
+func renderArticleList(list []siteEntry) string {
+	var out strings.Builder
+	out.WriteString(`<ul class="article-list">`)
+	for _, entry := range list {
+		fmt.Fprintf(&out, `<li><a href="%s">%s</a> <time datetime="%s">%s</time></li>`,
+			html.EscapeString(entry.url), html.EscapeString(entry.title),
+			entry.date.Format(time.DateOnly), entry.date.Format(time.DateOnly))
+	}
+	out.WriteString(`</ul>`)
+
+	return out.String()
+}
+
+func renderPageList(list []siteEntry) string {
+	var out strings.Builder
+	out.WriteString(`<ul class="page-list">`)
+	for _, entry := range list {
+		fmt.Fprintf(&out, `<li><a href="%s">%s</a></li>`, html.EscapeString(entry.url), html.EscapeString(entry.title))
+	}
+	out.WriteString(`</ul>`)
+
+	return out.String()
+}
+
+// renderTagList lists every tag with the number of its articles, sorted by
+// name or, with sort="-count", by use.
+func renderTagList(args map[string]string) (string, error) {
+	counts, tags := tagCounts()
+	switch args["sort"] {
+	case "", "name":
+		sort.Strings(tags)
+	case "-count":
+		sort.SliceStable(tags, func(i, j int) bool {
+			if counts[tags[i]] != counts[tags[j]] {
+				return counts[tags[i]] > counts[tags[j]]
+			}
+			return tags[i] < tags[j]
+		})
+	default:
+		return "", fmt.Errorf("Unknown sort order %q", args["sort"])
+	}
+
+	var out strings.Builder
+	out.WriteString(`<ul class="tag-list">`)
+	for _, tag := range tags {
+		fmt.Fprintf(&out, `<li>%s <span class="tag-count">%d</span></li>`, html.EscapeString(tag), counts[tag])
+	}
+	out.WriteString(`</ul>`)
+
+	return out.String(), nil
+}
+
+func renderSectionList() string {
+	var out strings.Builder
+	out.WriteString(`<ul class="section-list">`)
+	for _, section := range config.Sections {
+		title := section.Title
+		if title == "" {
+			title = section.Name
+		}
+		fmt.Fprintf(&out, `<li><a href="%s">%s</a></li>`, html.EscapeString(sectionUrl(section)), html.EscapeString(title))
+	}
+	out.WriteString(`</ul>`)
+
+	return out.String()
+}
+
+func runQuery(kind string, args map[string]string) (string, error) {
+	switch kind {
+	case "articles":
+		list, err := queryArticles(args)
+		if err != nil {
+			return "", err
+		}
+		return renderArticleList(list), nil
+	case "pages":
+		list := append([]siteEntry(nil), sitePages...)
+		if err := sortEntries(list, args["sort"], "title"); err != nil {
+			return "", err
+		}
+		list, err := limitEntries(list, args["limit"])
+		if err != nil {
+			return "", err
+		}
+		return renderPageList(list), nil
+	case "tags":
+		return renderTagList(args)
+	case "tag_cloud":
+		return renderTagCloud(args)
+	case "on_this_day":
+		return renderOnThisDay(), nil
+	case "sections":
+		return renderSectionList(), nil
+	default:
+		return "", fmt.Errorf("Unknown query %q", kind)
+	}
+}
+
+// expandQueries replaces every query directive, such as
+// {{< query articles tag="go" limit="5" >}}, with the list it selects.
+func expandQueries(text string, source string) (string, error) {
+	var queryErr error
+	text = queryDirective.ReplaceAllStringFunc(text, func(match string) string {
+		if queryErr != nil {
+			return match
+		}
+
+		parts := queryDirective.FindStringSubmatch(match)
+		args := make(map[string]string)
+		for _, arg := range queryArgument.FindAllStringSubmatch(parts[2], -1) {
+			args[arg[1]] = arg[2]
+		}
+
+		result, err := runQuery(parts[1], args)
+		if err != nil {
+			queryErr = directiveError(source, text, match, fmt.Errorf("Invalid query: %w", err))
+			return match
+		}
+		return result
+	})
+
+	return text, queryErr
+}
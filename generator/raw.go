@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+// isRawPath tells whether a directory is one of the raw directories, which
+// are copied to the site as they are, pages included, for content such as
+// standalone demos that must not be put into the template.
+func isRawPath(path string) bool {
+	for _, dir := range config.Raw {
+		if filepath.Clean(path) == filepath.Join(contentDirectory(), filepath.FromSlash(dir)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// copyRawDirectory copies a raw directory file by file. The names are kept
+// as they are, so the files keep finding each other.
+func copyRawDirectory(root string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			return createDir(rawTarget(path))
+		}
+		return copyFile(path, rawTarget(path))
+	})
+}
+
+// isRawPage tells whether a page outside /articles asks to be copied as it
+// is in its metadata.
+func isRawPage(path string) (bool, error) {
+	if isArticlePath(path) {
+		return false, nil
+	}
+
+	metadata, err := getPageMetadata(path)
+	return metadata.Raw, err
+}
+
+// rawTarget is where a raw file goes, under the very name it has in the
+// content directory.
+func rawTarget(path string) string {
+	rel, err := filepath.Rel(contentDirectory(), path)
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(targetDirectory(), rel)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type statsConfig struct {
+	Enabled bool   `json:"enabled"`
+	File    string `json:"file"`
+}
+
+// articleStats are the readability metrics of an article. Flesch reading
+// ease goes down and the Flesch-Kincaid grade up as text gets denser.
+type articleStats struct {
+	Url               string  `json:"url"`
+	Title             string  `json:"title"`
+	Date              string  `json:"date"`
+	Words             int     `json:"words"`
+	Sentences         int     `json:"sentences"`
+	WordsPerSentence  float64 `json:"words_per_sentence"`
+	SyllablesPerWord  float64 `json:"syllables_per_word"`
+	ReadingEase       float64 `json:"reading_ease"`
+	GradeLevel        float64 `json:"grade_level"`
+	PassivePercentage float64 `json:"passive_percentage"`
+}
+
+var (
+	sentenceEnd    = regexp.MustCompile(`[.!?]+(?:\s+|$)`)
+	wordPattern    = regexp.MustCompile(`[\p{L}\p{N}]+(?:['’][\p{L}]+)*`)
+	vowelGroup     = regexp.MustCompile(`[aeiouy]+`)
+	passivePattern = regexp.MustCompile(`(?i)\b(?:am|is|are|was|were|be|been|being)\s+(?:\w+ly\s+)?(?:\w+ed|` +
+		`born|built|done|found|given|known|made|seen|shown|taken|written|told|held|kept|left|lost|paid|put|read|run|said|sent|set|thought|understood|won)\b`)
+)
+
+var collectedStats []articleStats
+
+// countSyllables estimates the syllables of an English word by its vowel
+// groups, which is what readability formulas are calibrated against.
+func countSyllables(word string) int {
+	word = strings.ToLower(word)
+	count := len(vowelGroup.FindAllString(word, -1))
+	if strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") && count > 1 {
+		count--
+	}
+
+	return max(count, 1)
+}
+
+// measureReadability computes the metrics of the prose of an article. Code,
+// headings and the article info are left out.
+func measureReadability(art article) (articleStats, error) {
+	stats := articleStats{Url: art.url, Title: art.title, Date: art.date.Format("2006-01-02")}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(art.content))
+	if err != nil {
+		return stats, err
+	}
+	doc.Find("pre, code, script, style, h1, h2, h3, h4, h5, h6, .article-info, figure, table").Remove()
+
+	var sentences []string
+	doc.Find("p, li, blockquote, dd").Each(func(_ int, block *goquery.Selection) {
+		if block.Find("p, li").Length() > 0 {
+			return
+		}
+		for _, sentence := range sentenceEnd.Split(strings.TrimSpace(block.Text()), -1) {
+			if wordPattern.MatchString(sentence) {
+				sentences = append(sentences, sentence)
+			}
+		}
+	})
+	if len(sentences) == 0 {
+		return stats, nil
+	}
+
+	syllables, passive := 0, 0
+	for _, sentence := range sentences {
+		words := wordPattern.FindAllString(sentence, -1)
+		stats.Words += len(words)
+		for _, word := range words {
+			syllables += countSyllables(word)
+		}
+		if passivePattern.MatchString(sentence) {
+			passive++
+		}
+	}
+	stats.Sentences = len(sentences)
+
+	stats.WordsPerSentence = float64(stats.Words) / float64(stats.Sentences)
+	stats.SyllablesPerWord = float64(syllables) / float64(stats.Words)
+	stats.ReadingEase = 206.835 - 1.015*stats.WordsPerSentence - 84.6*stats.SyllablesPerWord
+	stats.GradeLevel = 0.39*stats.WordsPerSentence + 11.8*stats.SyllablesPerWord - 15.59
+	stats.PassivePercentage = 100 * float64(passive) / float64(stats.Sentences)
+	return stats, nil
+}
+
+func collectReadability(art article) error {
+	stats, err := measureReadability(art)
+	if err != nil {
+		return err
+	}
+
+	collectedStats = append(collectedStats, stats)
+	return nil
+}
+
+// reportReadability prints the metrics of every article, oldest first so a
+// trend shows, and keeps them in the stats file.
+func reportReadability() error {
+	sort.Slice(collectedStats, func(i, j int) bool {
+		return collectedStats[i].Date < collectedStats[j].Date
+	})
+
+	fmt.Println("Readability (ease, grade, words per sentence, passive):")
+	for _, stats := range collectedStats {
+		fmt.Printf("  %s %-40s %6.1f %5.1f %5.1f %4.0f%%\n", stats.Date, stats.Title, stats.ReadingEase,
+			stats.GradeLevel, stats.WordsPerSentence, stats.PassivePercentage)
+	}
+
+	file := config.Stats.File
+	if file == "" {
+		file = "stats.json"
+	}
+	return writeCacheFile(file, collectedStats)
+}
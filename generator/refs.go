@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var refDirective = regexp.MustCompile(`\{\{<\s*ref\s+"([^"]+)"\s*>\}\}`)
+
+// resolveRef finds the url of the page a ref names: an article by the name
+// of its directory, or any page by its path in the content directory.
+func resolveRef(name string) (string, bool) {
+	for _, entry := range siteArticles {
+		if filepath.Base(filepath.Dir(entry.source)) == name {
+			return entry.url, true
+		}
+	}
+
+	path := filepath.Join(contentDirectory(), filepath.FromSlash(strings.TrimPrefix(name, "/")))
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = directoryIndex(path)
+	}
+
+	for _, list := range [][]siteEntry{siteArticles, sitePages} {
+		for _, entry := range list {
+			if entry.source == path {
+				return entry.url, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// expandRefs replaces every {{< ref "name" >}} with the url of the page it
+// names. A ref to a page that is not built fails the build.
+func expandRefs(text string, source string) (string, error) {
+	var missing []string
+	text = refDirective.ReplaceAllStringFunc(text, func(match string) string {
+		name := refDirective.FindStringSubmatch(match)[1]
+		u, ok := resolveRef(name)
+		if !ok {
+			missing = append(missing, describeAt(source, text, match, name))
+			return match
+		}
+		return html.EscapeString(u)
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("Unresolved refs in %s: %s", source, strings.Join(missing, ", "))
+	}
+
+	return text, nil
+}
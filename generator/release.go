@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// version is set by the release builds with -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+const releasesUrl = "https://api.github.com/repos/armaho/blog/releases/latest"
+
+var releaseClient = &http.Client{Timeout: 5 * time.Minute, Transport: networkTransport{}}
+
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		Url  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// buildVersion is the version of the running binary. Builds made with go
+// install carry the module version, local builds the commit they came from.
+func buildVersion() string {
+	if version != "dev" {
+		return version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return version
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && len(setting.Value) >= 12 {
+			return version + "-" + setting.Value[:12]
+		}
+	}
+
+	return version
+}
+
+func runVersion(args []string) error {
+	flags := flag.NewFlagSet("version", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	fmt.Printf("site-generator %s %s/%s\n", buildVersion(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
+
+// releaseAssetName is the name the release workflow gives the binary for
+// the running platform.
+func releaseAssetName() string {
+	name := fmt.Sprintf("sitegen-%s-%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+
+	return name
+}
+
+func download(u string) ([]byte, error) {
+	if err := requireNetwork("download", u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "site-generator")
+	if strings.HasPrefix(u, "https://api.github.com/") {
+		req.Header.Set("Accept", "application/vnd.github+json")
+	}
+
+	resp, err := releaseClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s answered %s", u, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// parseVersion splits a version like v1.2.3 or v1.2.3-rc.1 into its
+// numbers and its pre-release part.
+func parseVersion(value string) ([3]int, string, bool) {
+	var numbers [3]int
+	rest, ok := strings.CutPrefix(value, "v")
+	if !ok {
+		return numbers, "", false
+	}
+	rest, _, _ = strings.Cut(rest, "+")
+	core, prerelease, _ := strings.Cut(rest, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return numbers, "", false
+	}
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return numbers, "", false
+		}
+		numbers[i] = number
+	}
+
+	return numbers, prerelease, true
+}
+
+// compareVersions orders two versions parsed by parseVersion the way
+// semantic versioning does: a pre-release comes before its release, and
+// pre-release fields compare as numbers when they are numbers.
+func compareVersions(a [3]int, aPre string, b [3]int, bPre string) int {
+	for i := range a {
+		if a[i] != b[i] {
+			return cmp.Compare(a[i], b[i])
+		}
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+
+	aFields, bFields := strings.Split(aPre, "."), strings.Split(bPre, ".")
+	for i := 0; i < len(aFields) && i < len(bFields); i++ {
+		aNumber, aErr := strconv.Atoi(aFields[i])
+		bNumber, bErr := strconv.Atoi(bFields[i])
+		switch {
+		case aFields[i] == bFields[i]:
+			continue
+		case aErr == nil && bErr == nil:
+			return cmp.Compare(aNumber, bNumber)
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			return strings.Compare(aFields[i], bFields[i])
+		}
+	}
+	return cmp.Compare(len(aFields), len(bFields))
+}
+
+// isNewerRelease tells whether latest is a newer version than current.
+// Builds that carry no version, like local ones, are never older than a
+// release, so they are only replaced on request.
+func isNewerRelease(current string, latest string) (bool, error) {
+	latestNumbers, latestPre, ok := parseVersion(latest)
+	if !ok {
+		return false, fmt.Errorf("Release %s has no version number", latest)
+	}
+	currentNumbers, currentPre, ok := parseVersion(current)
+	if !ok {
+		return false, nil
+	}
+
+	return compareVersions(latestNumbers, latestPre, currentNumbers, currentPre) > 0, nil
+}
+
+// releaseChecksum finds the checksum of a file in a checksums.txt made by
+// sha256sum.
+func releaseChecksum(checksums []byte, name string) (string, bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return fields[0], true
+		}
+	}
+
+	return "", false
+}
+
+// replaceExecutable puts the new binary in place of the running one.
+func replaceExecutable(data []byte) (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return "", err
+	}
+
+	return executable, replaceBinary(executable, data, runtime.GOOS == "windows")
+}
+
+// replaceBinary writes a new binary next to path first and renames it over
+// path, so a failed update leaves the old binary working. Windows does not
+// let a running binary be replaced, only renamed, so there the old one is
+// moved aside to path.old first, and back when the new one cannot take its
+// place.
+func replaceBinary(path string, data []byte, moveAside bool) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Chmod(0755); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if !moveAside {
+		return os.Rename(file.Name(), path)
+	}
+
+	old := path + ".old"
+	os.Remove(old)
+	if err := os.Rename(path, old); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		if restoreErr := os.Rename(old, path); restoreErr != nil {
+			return fmt.Errorf("%w, and the old binary is left at %s: %w", err, old, restoreErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// runSelfUpdate replaces the binary with the one of the latest release when
+// that is newer, after checking it against the checksums published with the
+// release. The checksums come from the same release as the binary, so they
+// catch a broken download, not a release that was tampered with.
+func runSelfUpdate(args []string) error {
+	flags := flag.NewFlagSet("self-update", flag.ContinueOnError)
+	check := flags.Bool("check", false, "only report whether a newer release exists")
+	force := flags.Bool("force", false, "install the latest release even when it is not newer")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	data, err := download(releasesUrl)
+	if err != nil {
+		return fmt.Errorf("Cannot look up the latest release: %w", err)
+	}
+
+	var release githubRelease
+	if err := json.Unmarshal(data, &release); err != nil {
+		return fmt.Errorf("Invalid release: %w", err)
+	}
+
+	current := buildVersion()
+	newer, err := isNewerRelease(current, release.TagName)
+	if err != nil {
+		return err
+	}
+	if !newer && !*force {
+		if _, _, ok := parseVersion(current); !ok {
+			fmt.Printf("site-generator %s is not a release, use --force to replace it with %s\n", current, release.TagName)
+		} else {
+			fmt.Printf("site-generator %s is up to date, the latest release is %s\n", current, release.TagName)
+		}
+		return nil
+	}
+	if *check {
+		fmt.Printf("site-generator %s is available, running %s\n", release.TagName, current)
+		return nil
+	}
+
+	assets := make(map[string]string)
+	for _, asset := range release.Assets {
+		assets[asset.Name] = asset.Url
+	}
+
+	name := releaseAssetName()
+	if assets[name] == "" {
+		return fmt.Errorf("Release %s has no binary for %s/%s", release.TagName, runtime.GOOS, runtime.GOARCH)
+	}
+	if assets["checksums.txt"] == "" {
+		return fmt.Errorf("Release %s has no checksums.txt", release.TagName)
+	}
+
+	checksums, err := download(assets["checksums.txt"])
+	if err != nil {
+		return fmt.Errorf("Cannot download the checksums: %w", err)
+	}
+	expected, ok := releaseChecksum(checksums, name)
+	if !ok {
+		return fmt.Errorf("checksums.txt of %s does not list %s", release.TagName, name)
+	}
+
+	binary, err := download(assets[name])
+	if err != nil {
+		return fmt.Errorf("Cannot download %s: %w", name, err)
+	}
+	sum := sha256.Sum256(binary)
+	if actual := hex.EncodeToString(sum[:]); actual != expected {
+		return fmt.Errorf("Checksum mismatch for %s: expected %s, got %s", name, expected, actual)
+	}
+
+	executable, err := replaceExecutable(binary)
+	if err != nil {
+		return fmt.Errorf("Failed to replace the binary: %w", err)
+	}
+
+	fmt.Printf("Updated %s from %s to %s\n", executable, current, release.TagName)
+	return nil
+}
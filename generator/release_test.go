@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsNewerRelease(t *testing.T) {
+	tests := []struct {
+		current string
+		latest  string
+		want    bool
+	}{
+		{"v1.2.3", "v1.2.4", true},
+		{"v1.2.3", "v1.10.0", true},
+		{"v1.2.3", "v2.0.0", true},
+		{"v1.2.3", "v1.2.3", false},
+		{"v1.2.4", "v1.2.3", false},
+		{"v1.10.0", "v1.9.0", false},
+		{"v1.2.3-rc.1", "v1.2.3", true},
+		{"v1.2.3", "v1.2.3-rc.1", false},
+		{"v1.2.3-rc.2", "v1.2.3-rc.10", true},
+		{"v1.2.3-alpha", "v1.2.3-beta", true},
+		{"v1.2.3-rc.1", "v1.2.3-rc.1.1", true},
+		{"v1.2.3+build.1", "v1.2.3", false},
+		{"v0.0.0-20240101120000-0123456789ab", "v0.1.0", true},
+		{"dev", "v1.2.3", false},
+		{"dev-0123456789ab", "v1.2.3", false},
+	}
+
+	for _, test := range tests {
+		got, err := isNewerRelease(test.current, test.latest)
+		if err != nil || got != test.want {
+			t.Errorf("isNewerRelease(%q, %q) = %v, %v; want %v", test.current, test.latest, got, err, test.want)
+		}
+	}
+
+	if _, err := isNewerRelease("v1.2.3", "latest"); err == nil {
+		t.Error("a release without a version number was accepted")
+	}
+}
+
+func TestReleaseChecksum(t *testing.T) {
+	checksums := []byte("abc123  sitegen-linux-amd64\ndef456 *sitegen-windows-amd64.exe\n")
+
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"sitegen-linux-amd64", "abc123", true},
+		{"sitegen-windows-amd64.exe", "def456", true},
+		{"sitegen-linux", "", false},
+	}
+
+	for _, test := range tests {
+		if got, ok := releaseChecksum(checksums, test.name); got != test.want || ok != test.ok {
+			t.Errorf("releaseChecksum(%q) = %q, %v; want %q, %v", test.name, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestReplaceBinary(t *testing.T) {
+	tests := []struct {
+		name      string
+		moveAside bool
+	}{
+		{"in place", false},
+		{"moving the old binary aside", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sitegen")
+			if err := os.WriteFile(path, []byte("old"), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := replaceBinary(path, []byte("new"), test.moveAside); err != nil {
+				t.Fatal(err)
+			}
+			if data, err := os.ReadFile(path); err != nil || string(data) != "new" {
+				t.Errorf("binary = %q, %v; want new", data, err)
+			}
+			if data, err := os.ReadFile(path + ".old"); test.moveAside && string(data) != "old" {
+				t.Errorf("old binary = %q, %v; want old", data, err)
+			}
+			entries, _ := os.ReadDir(filepath.Dir(path))
+			if want := map[bool]int{false: 1, true: 2}[test.moveAside]; len(entries) != want {
+				t.Errorf("%d files next to the binary, want %d", len(entries), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+type reportPost struct {
+	Title     string `json:"title"`
+	Url       string `json:"url"`
+	Date      string `json:"date"`
+	WordCount int    `json:"word_count"`
+	Revisions int    `json:"revisions,omitempty"`
+}
+
+type reportTag struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// yearReport sums up the writing of one year for a year-in-review post.
+// Updated lists the posts of any year by the revisions made to them during
+// the year.
+type yearReport struct {
+	Year           int          `json:"year"`
+	Posts          int          `json:"posts"`
+	Words          int          `json:"words"`
+	ReadingMinutes int          `json:"reading_minutes"`
+	Longest        *reportPost  `json:"longest,omitempty"`
+	TopTags        []reportTag  `json:"top_tags"`
+	Updated        []reportPost `json:"most_updated"`
+	Articles       []reportPost `json:"articles"`
+}
+
+// reportLimit is how many tags and updated posts the report names.
+const reportLimit = 10
+
+func reportPostOf(art article) reportPost {
+	u := art.url
+	if config.BaseUrl != "" {
+		u = absoluteUrl(art.url, "/")
+	}
+
+	return reportPost{Title: art.title, Url: u, Date: art.metadata.ReleaseDate, WordCount: art.metadata.WordCount}
+}
+
+func buildYearReport(all []article, year int) yearReport {
+	report := yearReport{Year: year, TopTags: []reportTag{}, Updated: []reportPost{}, Articles: []reportPost{}}
+
+	counts := make(map[string]int)
+	for _, art := range all {
+		revisions := 0
+		for _, entry := range art.metadata.Changelog {
+			if strings.HasPrefix(entry.Date, fmt.Sprintf("%d-", year)) {
+				revisions++
+			}
+		}
+		if revisions > 0 {
+			post := reportPostOf(art)
+			post.Revisions = revisions
+			report.Updated = append(report.Updated, post)
+		}
+
+		if art.date.Year() != year {
+			continue
+		}
+
+		post := reportPostOf(art)
+		report.Articles = append(report.Articles, post)
+		report.Posts++
+		report.Words += art.metadata.WordCount
+		report.ReadingMinutes += art.metadata.EstimatedTime
+		if report.Longest == nil || post.WordCount > report.Longest.WordCount {
+			report.Longest = &post
+		}
+		for _, tag := range art.metadata.Tags {
+			counts[tag]++
+		}
+	}
+
+	for tag, count := range counts {
+		report.TopTags = append(report.TopTags, reportTag{Tag: tag, Count: count})
+	}
+	sort.Slice(report.TopTags, func(i, j int) bool {
+		if report.TopTags[i].Count != report.TopTags[j].Count {
+			return report.TopTags[i].Count > report.TopTags[j].Count
+		}
+		return report.TopTags[i].Tag < report.TopTags[j].Tag
+	})
+	report.TopTags = report.TopTags[:min(reportLimit, len(report.TopTags))]
+
+	sort.SliceStable(report.Updated, func(i, j int) bool {
+		return report.Updated[i].Revisions > report.Updated[j].Revisions
+	})
+	report.Updated = report.Updated[:min(reportLimit, len(report.Updated))]
+
+	return report
+}
+
+func (report yearReport) markdown() string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "# %d in review\n\n", report.Year)
+	fmt.Fprintf(&out, "- Posts: %d\n- Words: %d\n- Reading time: %d minutes\n", report.Posts, report.Words, report.ReadingMinutes)
+	if report.Longest != nil {
+		fmt.Fprintf(&out, "- Longest post: [%s](%s), %d words\n", report.Longest.Title, report.Longest.Url, report.Longest.WordCount)
+	}
+
+	if len(report.TopTags) > 0 {
+		out.WriteString("\n## Top tags\n\n")
+		for _, tag := range report.TopTags {
+			fmt.Fprintf(&out, "- %s: %d\n", tag.Tag, tag.Count)
+		}
+	}
+
+	if len(report.Updated) > 0 {
+		out.WriteString("\n## Most updated\n\n")
+		for _, post := range report.Updated {
+			fmt.Fprintf(&out, "- [%s](%s): %d revisions\n", post.Title, post.Url, post.Revisions)
+		}
+	}
+
+	if len(report.Articles) > 0 {
+		out.WriteString("\n## Posts\n\n")
+		for _, post := range report.Articles {
+			fmt.Fprintf(&out, "- %s [%s](%s)\n", post.Date, post.Title, post.Url)
+		}
+	}
+
+	return out.String()
+}
+
+func runReport(args []string) error {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	flags.StringVar(&buildEnvironment, "env", buildEnvironment, "config environment to use")
+	year := flags.Int("year", time.Now().Year(), "year to sum up")
+	format := flags.String("format", "markdown", "write the report as markdown or json")
+	output := flags.String("output", "", "file to write the report to instead of standard output")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *format != "markdown" && *format != "json" {
+		return fmt.Errorf("Unknown report format: %s", *format)
+	}
+
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	if err := fetchContent(); err != nil {
+		return err
+	}
+	if err := checkTemplate(); err != nil {
+		return err
+	}
+	if err := loadSiteIndex(); err != nil {
+		return err
+	}
+
+	all, err := collectArticles()
+	if err != nil {
+		return err
+	}
+	report := buildYearReport(all, *year)
+
+	var data []byte
+	if *format == "json" {
+		if data, err = json.MarshalIndent(report, "", "  "); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	} else {
+		data = []byte(report.markdown())
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*output, data, 0644)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// randomConfig writes a page that sends its visitors to a random article,
+// picked in the browser from articles.json so it changes on every visit.
+type randomConfig struct {
+	Enabled bool   `json:"enabled"`
+	Url     string `json:"url"`
+}
+
+// onThisDayConfig lists on the home page the articles released on the day of
+// the build in past years, or up to days away from it.
+type onThisDayConfig struct {
+	Enabled bool   `json:"enabled"`
+	Title   string `json:"title"`
+	Days    int    `json:"days"`
+}
+
+const randomScript = `fetch("%s").then(r => r.json()).then(list => {
+  if (list.length > 0) location.replace(list[Math.floor(Math.random() * list.length)].url);
+});`
+
+func writeRandomPage() error {
+	if !config.Json {
+		return fmt.Errorf("The random page needs json to be set")
+	}
+
+	u := config.Random.Url
+	if u == "" {
+		u = "/random/index.html"
+	}
+
+	tmpl, err := templateWithVariables(pageVariables(u, "Random post"))
+	if err != nil {
+		return err
+	}
+
+	tmpl.Find("#content").SetHtml(fmt.Sprintf(
+		`<p class="random-post">Picking a random post&hellip;</p><noscript><p><a href="/index.html">Read the latest posts</a></p></noscript><script>%s</script>`,
+		fmt.Sprintf(randomScript, siteUrl(articlesJsonUrl))))
+
+	// The page is a different one on every visit, so it is kept out of
+	// search engines and the sitemap.
+	head := &pageHead{}
+	head.add("robots", `meta[name="robots"]`, `<meta name="robots" content="noindex">`)
+	if err := finalizePage(tmpl, head, u, nil); err != nil {
+		return err
+	}
+
+	final, err := tmpl.Html()
+	if err != nil {
+		return fmt.Errorf("Failed to serialize HTML: %w", err)
+	}
+
+	target := targetPathFromUrl(u)
+	if err := claimOutput(target, "the random page"); err != nil {
+		return err
+	}
+	if err := createDir(filepath.Dir(target)); err != nil {
+		return err
+	}
+	return writeOutputFile(target, []byte(final))
+}
+
+// onThisDay returns the articles of past years released within the
+// configured number of days of the build date, the most recent first.
+func onThisDay() []siteEntry {
+	now := buildTime
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	window := time.Duration(max(config.OnThisDay.Days, 0)) * 24 * time.Hour
+
+	var list []siteEntry
+	for _, entry := range siteArticles {
+		if entry.date.Year() >= today.Year() {
+			continue
+		}
+
+		// The neighbouring years catch windows that cross new year.
+		for year := today.Year() - 1; year <= today.Year()+1; year++ {
+			anniversary := time.Date(year, entry.date.Month(), entry.date.Day(), 0, 0, 0, 0, time.UTC)
+			if distance := anniversary.Sub(today).Abs(); distance <= window {
+				list = append(list, entry)
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].date.After(list[j].date)
+	})
+	return list
+}
+
+func renderOnThisDay() string {
+	list := onThisDay()
+	if len(list) == 0 {
+		return ""
+	}
+
+	title := config.OnThisDay.Title
+	if title == "" {
+		title = "On this day"
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, `<section class="on-this-day"><h2>%s</h2><ul>`, html.EscapeString(title))
+	for _, entry := range list {
+		fmt.Fprintf(&out, `<li><time datetime="%s">%d</time> <a href="%s">%s</a></li>`,
+			entry.date.Format(time.DateOnly), entry.date.Year(), html.EscapeString(entry.url), html.EscapeString(entry.title))
+	}
+	out.WriteString(`</ul></section>`)
+
+	return out.String()
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Bucket reads objects of a bucket. Requests are signed with AWS Signature
+// Version 4 when AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are set, and
+// anonymous otherwise. AWS_ENDPOINT_URL points at other S3 compatible stores.
+type s3Bucket struct {
+	name     string
+	region   string
+	endpoint string
+}
+
+func newS3Bucket(name string) s3Bucket {
+	bucket := s3Bucket{name: name, region: os.Getenv("AWS_REGION")}
+	if bucket.region == "" {
+		bucket.region = "us-east-1"
+	}
+
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL"); endpoint != "" {
+		bucket.endpoint = strings.TrimSuffix(endpoint, "/") + "/" + name
+	} else {
+		bucket.endpoint = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", name, bucket.region)
+	}
+
+	return bucket
+}
+
+// s3Escape encodes a string the way Signature Version 4 expects, leaving
+// slashes alone in paths.
+func s3Escape(s string, path bool) string {
+	var out strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			out.WriteByte(b)
+		case b == '/' && path:
+			out.WriteByte(b)
+		default:
+			fmt.Fprintf(&out, "%%%02X", b)
+		}
+	}
+
+	return out.String()
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// sign adds the Signature Version 4 headers to a GET request.
+func (b s3Bucket) sign(req *http.Request) {
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return
+	}
+
+	now := time.Now().UTC()
+	date := now.Format("20060102")
+	payload := hex.EncodeToString(sha256.New().Sum(nil))
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+
+	var names []string
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(values[0])
+		}
+	}
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.RawPath,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payload,
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonical))
+
+	scope := date + "/" + b.region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + now.Format("20060102T150405Z") + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSha256([]byte("AWS4"+secretKey), date)
+	key = hmacSha256(key, b.region)
+	key = hmacSha256(key, "s3")
+	key = hmacSha256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// s3Query encodes a query string in the sorted form the signature covers.
+func s3Query(query url.Values) string {
+	var params []string
+	for key, values := range query {
+		for _, value := range values {
+			params = append(params, s3Escape(key, false)+"="+s3Escape(value, false))
+		}
+	}
+	sort.Strings(params)
+
+	return strings.Join(params, "&")
+}
+
+func (b s3Bucket) get(key string, query url.Values) ([]byte, error) {
+	req, err := http.NewRequest("GET", b.endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.Path = strings.TrimSuffix(req.URL.Path, "/") + "/" + key
+	req.URL.RawPath = s3Escape(req.URL.Path, true)
+	req.URL.RawQuery = s3Query(query)
+	req.Header.Set("User-Agent", "site-generator")
+	b.sign(req)
+
+	resp, err := releaseClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("s3://%s/%s answered %s", b.name, key, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// list returns the keys of every object under prefix.
+func (b s3Bucket) list(prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		data, err := b.get("", query)
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		if err := xml.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("Invalid listing of s3://%s: %w", b.name, err)
+		}
+
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+		if !result.IsTruncated {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// buildTime is when the build started. Articles released after it are
+// scheduled, and with hold_scheduled they are held back until a later build.
+var buildTime = time.Now()
+
+// nextPublish is the earliest release date of an article held back, zero
+// when nothing is waiting to be published.
+var nextPublish time.Time
+
+// releaseAfterBuild is the release date of an article released after the
+// build, zero for any other page.
+func releaseAfterBuild(path string) time.Time {
+	if !isArticlePath(path) {
+		return time.Time{}
+	}
+
+	metadata, err := getArticleMetadata(filepath.Dir(path))
+	if err != nil {
+		return time.Time{}
+	}
+
+	date, err := time.Parse("2006-01-02", metadata.ReleaseDate)
+	if err != nil || !date.After(buildTime) {
+		return time.Time{}
+	}
+	return date
+}
+
+// isHeldBack tells whether an article is left out of the build until its
+// release date, and notes the earliest such release for the publish hints.
+// A build with drafts publishes it already, so there is nothing to wait for.
+func isHeldBack(path string) bool {
+	if !config.HoldScheduled {
+		return false
+	}
+
+	date := releaseAfterBuild(path)
+	if date.IsZero() {
+		return false
+	}
+
+	if !config.Drafts && (nextPublish.IsZero() || date.Before(nextPublish)) {
+		nextPublish = date
+	}
+	return true
+}
+
+// pageCacheSeconds suggests how long pages and feeds may be cached: until
+// the next scheduled article at most, a day otherwise.
+func pageCacheSeconds() int {
+	maxAge := 24 * time.Hour
+	if !nextPublish.IsZero() {
+		maxAge = min(maxAge, max(time.Minute, nextPublish.Sub(buildTime)))
+	}
+
+	return int(maxAge.Seconds())
+}
+
+// feedUpdatePeriod maps the time until the next scheduled article onto the
+// RSS syndication module periods.
+func feedUpdatePeriod() string {
+	if !nextPublish.IsZero() && nextPublish.Sub(buildTime) <= 24*time.Hour {
+		return "hourly"
+	}
+
+	return "daily"
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIsHeldBack(t *testing.T) {
+	content := t.TempDir()
+	t.Setenv("CONTENT_PATH", content)
+	saved, savedNext := config, nextPublish
+	t.Cleanup(func() { config, nextPublish = saved, savedNext })
+
+	future := buildTime.AddDate(0, 0, 3).Format(time.DateOnly)
+	writeTestFiles(t, content, map[string]string{
+		"articles/past/index.md":         "# Past\n",
+		"articles/past/metadata.json":    `{"release_date": "2020-01-01"}`,
+		"articles/future/index.md":       "# Future\n",
+		"articles/future/metadata.json":  `{"release_date": "` + future + `"}`,
+		"articles/undated/index.md":      "# Undated\n",
+		"articles/undated/metadata.json": `{}`,
+	})
+
+	tests := []struct {
+		article  string
+		hold     bool
+		drafts   bool
+		want     bool
+		wantNext bool
+	}{
+		{"past", false, false, false, false},
+		{"past", true, false, false, false},
+		{"future", false, false, false, false},
+		{"future", true, false, true, true},
+		{"future", true, true, true, false},
+		{"undated", true, false, false, false},
+	}
+
+	for _, test := range tests {
+		config.HoldScheduled, config.Drafts = test.hold, test.drafts
+		nextPublish = time.Time{}
+
+		path := filepath.Join(content, "articles", test.article, "index.md")
+		if got := isHeldBack(path); got != test.want {
+			t.Errorf("isHeldBack(%s) with hold_scheduled %v = %v, want %v", test.article, test.hold, got, test.want)
+		}
+		// Only an article waiting for its release date gives publish hints.
+		if nextPublish.IsZero() == test.wantNext {
+			t.Errorf("next publish after %s with hold_scheduled %v, drafts %v = %v", test.article, test.hold, test.drafts, nextPublish)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"slices"
+)
+
+// sectionConfig groups articles by tag or series into a landing page with
+// its own feed, at /<name>/. Sort orders the landing page like home_sort
+// does the home page.
+type sectionConfig struct {
+	Name   string   `json:"name"`
+	Title  string   `json:"title"`
+	Tags   []string `json:"tags"`
+	Series []string `json:"series"`
+	Sort   string   `json:"sort"`
+}
+
+func sectionUrl(section sectionConfig) string {
+	return "/" + section.Name + "/index.html"
+}
+
+func sectionFeedUrl(section sectionConfig) string {
+	return "/" + section.Name + feedUrl
+}
+
+func inSection(art article, section sectionConfig) bool {
+	if slices.Contains(section.Series, art.metadata.Series) && art.metadata.Series != "" {
+		return true
+	}
+	for _, tag := range art.metadata.Tags {
+		if slices.Contains(section.Tags, canonicalTag(tag)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sectionArticles(section sectionConfig) []article {
+	var list []article
+	for _, art := range articles {
+		if inSection(art, section) {
+			list = append(list, art)
+		}
+	}
+
+	return list
+}
+
+func findSection(name string) (sectionConfig, bool) {
+	for _, section := range config.Sections {
+		if section.Name == name {
+			return section, true
+		}
+	}
+
+	return sectionConfig{}, false
+}
+
+// homeArticles lists what the home page shows: every article, or only those
+// of the sections named in home_sections, except the ones excluded from
+// it.
+func homeArticles() []article {
+	var list []article
+	for _, art := range articles {
+		if art.metadata.ExcludeHome {
+			continue
+		}
+		if len(config.HomeSections) == 0 {
+			list = append(list, art)
+			continue
+		}
+		for _, name := range config.HomeSections {
+			if section, ok := findSection(name); ok && inSection(art, section) {
+				list = append(list, art)
+				break
+			}
+		}
+	}
+
+	return list
+}
+
+func checkSections() error {
+	for _, section := range config.Sections {
+		if section.Name == "" || path.Base(section.Name) != section.Name {
+			return fmt.Errorf("Invalid section name: %q", section.Name)
+		}
+		if err := sortArticles(nil, section.Sort); err != nil {
+			return fmt.Errorf("Invalid sort of section %s: %w", section.Name, err)
+		}
+	}
+	if err := sortArticles(nil, config.HomeSort); err != nil {
+		return fmt.Errorf("Invalid home_sort: %w", err)
+	}
+	for _, name := range config.HomeSections {
+		if _, ok := findSection(name); !ok {
+			return fmt.Errorf("Unknown section in home_sections: %s", name)
+		}
+	}
+
+	return nil
+}
+
+func generateSectionPages() error {
+	for _, section := range config.Sections {
+		title := section.Title
+		if title == "" {
+			title = section.Name
+		}
+
+		list := sectionArticles(section)
+		page := listPage{
+			url:      sectionUrl(section),
+			title:    title,
+			articles: list,
+			order:    section.Sort,
+			feed:     sectionFeedUrl(section),
+			owner:    "the section " + section.Name,
+		}
+		if err := generateListPage(page); err != nil {
+			return err
+		}
+
+		if config.Feed.Enabled {
+			if err := writeFeedFile(sectionFeedUrl(section), title, list); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHomeArticles(t *testing.T) {
+	saved, savedArticles := config, articles
+	t.Cleanup(func() { config, articles = saved, savedArticles })
+
+	articles = []article{
+		{title: "plain", metadata: articleInfo{Tags: []string{"go"}}},
+		{title: "not in feeds", metadata: articleInfo{Tags: []string{"go"}, ExcludeFeed: true}},
+		{title: "not on the home page", metadata: articleInfo{Tags: []string{"go"}, ExcludeHome: true}},
+		{title: "not indexed", metadata: articleInfo{Tags: []string{"bikes"}, Noindex: true}},
+	}
+	config.Sections = []sectionConfig{{Name: "code", Tags: []string{"go"}}}
+
+	tests := []struct {
+		name     string
+		sections []string
+		want     []string
+	}{
+		{"every article", nil, []string{"plain", "not in feeds", "not indexed"}},
+		{"home sections", []string{"code"}, []string{"plain", "not in feeds"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config.HomeSections = test.sections
+
+			var got []string
+			for _, art := range homeArticles() {
+				got = append(got, art.title)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("homeArticles() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
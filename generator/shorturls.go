@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"path"
+)
+
+type shortUrlsConfig struct {
+	Enabled bool   `json:"enabled"`
+	File    string `json:"file"`
+	Prefix  string `json:"prefix"`
+}
+
+var (
+	shortCodes       map[string]string
+	shortCodesLoaded bool
+	// shortUrls maps the short url of every article of the build to it, for
+	// the manifest.
+	shortUrls = make(map[string]string)
+)
+
+func shortUrlsFile() string {
+	if config.ShortUrls.File != "" {
+		return config.ShortUrls.File
+	}
+
+	return "short-urls.json"
+}
+
+func saveShortCodes() error {
+	if !shortCodesLoaded {
+		return nil
+	}
+
+	return writeCacheFile(shortUrlsFile(), shortCodes)
+}
+
+// articleShortUrl returns the short url of an article. Codes are kept in
+// the short urls file by the name of the article's directory, so they never
+// change, even when the permalinks of the site do. A new article gets the
+// shortest prefix of the hash of its name that is still free.
+func articleShortUrl(art article) string {
+	if !shortCodesLoaded {
+		shortCodesLoaded = true
+		shortCodes = make(map[string]string)
+		readCacheFile(shortUrlsFile(), &shortCodes)
+	}
+
+	prefix := config.ShortUrls.Prefix
+	if prefix == "" {
+		prefix = "/s/"
+	}
+
+	slug := path.Base(path.Dir(art.url))
+	for code, name := range shortCodes {
+		if name == slug {
+			return prefix + code
+		}
+	}
+
+	sum := sha256.Sum256([]byte(slug))
+	hash := hex.EncodeToString(sum[:])
+	code := hash[:4]
+	for i := 5; shortCodes[code] != ""; i++ {
+		code = hash[:i]
+	}
+	shortCodes[code] = slug
+	return prefix + code
+}
+
+// addShortUrl points the short url of an article at it with a redirect and
+// announces it on the page.
+func addShortUrl(head *pageHead, art article) error {
+	short := articleShortUrl(art)
+	if err := writeAliasRedirect(short, art.url); err != nil {
+		return fmt.Errorf("Failed to write short url: %w", err)
+	}
+
+	shortUrls[short] = art.url
+	head.add("shortlink", `link[rel="shortlink"]`,
+		fmt.Sprintf(`<link rel="shortlink" href="%s">`, html.EscapeString(absoluteUrl(short, "/"))))
+	return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// outputSink takes the finished build out of the staging directory. The
+// build itself always writes to disk, since its later steps read their own
+// output back.
+type outputSink interface {
+	store(staging string) error
+}
+
+// siteSink is where the running build ends up, chosen with --output.
+var siteSink outputSink = directorySink{}
+
+func newOutputSink(format string) (outputSink, error) {
+	switch format {
+	case "", "dir":
+		return directorySink{}, nil
+	case "zip":
+		return zipSink{}, nil
+	case "tar":
+		return tarSink{}, nil
+	case "tar.gz", "tgz":
+		return tarSink{gzip: true}, nil
+	default:
+		return nil, fmt.Errorf("Unknown output format %q", format)
+	}
+}
+
+// directorySink swaps the staging directory into place of the target
+// directory and removes the previous output.
+type directorySink struct{}
+
+func (directorySink) store(staging string) error {
+	target := outputDirectory()
+
+	previous := ""
+	if _, err := os.Stat(target); err == nil {
+		previous = staging + "-previous"
+		if err := os.Rename(target, previous); err != nil {
+			return fmt.Errorf("Failed to move %s aside: %w", target, err)
+		}
+	}
+
+	if err := os.Rename(staging, target); err != nil {
+		if previous != "" {
+			os.Rename(previous, target)
+		}
+		return fmt.Errorf("Failed to move build output into %s: %w", target, err)
+	}
+
+	if previous != "" {
+		return deleteDirIfExists(previous)
+	}
+	return nil
+}
+
+// walkStaging calls visit with the slash separated name of every file and
+// directory of the build.
+func walkStaging(staging string, visit func(name string, path string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(staging, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || path == staging {
+			return err
+		}
+
+		rel, err := filepath.Rel(staging, path)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		return visit(filepath.ToSlash(rel), path, info)
+	})
+}
+
+func copyFileTo(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// zipSink writes the site into a zip archive at TARGET_PATH.
+type zipSink struct{}
+
+func (zipSink) store(staging string) error {
+	err := writeOutputWith(outputDirectory(), func(file *os.File) error {
+		archive := zip.NewWriter(file)
+		err := walkStaging(staging, func(name string, path string, info fs.FileInfo) error {
+			if info.IsDir() {
+				return nil
+			}
+
+			header, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			header.Name = name
+			header.Method = zip.Deflate
+
+			w, err := archive.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			return copyFileTo(w, path)
+		})
+		if err != nil {
+			return err
+		}
+		return archive.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to write %s: %w", outputDirectory(), err)
+	}
+
+	return deleteDirIfExists(staging)
+}
+
+// tarSink writes the site into a tarball at TARGET_PATH, gzipped or not.
+type tarSink struct {
+	gzip bool
+}
+
+func (s tarSink) store(staging string) error {
+	err := writeOutputWith(outputDirectory(), func(file *os.File) error {
+		var w io.Writer = file
+		var compressor *gzip.Writer
+		if s.gzip {
+			compressor = gzip.NewWriter(file)
+			w = compressor
+		}
+
+		archive := tar.NewWriter(w)
+		err := walkStaging(staging, func(name string, path string, info fs.FileInfo) error {
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = name
+			if info.IsDir() {
+				header.Name += "/"
+			}
+
+			if err := archive.WriteHeader(header); err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			return copyFileTo(archive, path)
+		})
+		if err != nil {
+			return err
+		}
+		if err := archive.Close(); err != nil {
+			return err
+		}
+		if compressor != nil {
+			return compressor.Close()
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to write %s: %w", outputDirectory(), err)
+	}
+
+	return deleteDirIfExists(staging)
+}
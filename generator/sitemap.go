@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sitemapPages maps the url of every page for the sitemap to its last
+// modification date, empty when unknown.
+var sitemapPages = make(map[string]string)
+
+func writeSitemap() error {
+	if config.BaseUrl == "" {
+		return fmt.Errorf("The sitemap needs base_url to be set")
+	}
+
+	var urls []string
+	for u := range sitemapPages {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+
+	var sitemap strings.Builder
+	sitemap.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	sitemap.WriteString(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n")
+	for _, u := range urls {
+		fmt.Fprintf(&sitemap, "  <url><loc>%s</loc>", xmlEscape(absoluteUrl(strings.TrimSuffix(u, "index.html"), "/")))
+		if lastmod := sitemapPages[u]; lastmod != "" {
+			fmt.Fprintf(&sitemap, "<lastmod>%s</lastmod>", lastmod)
+		}
+		sitemap.WriteString("</url>\n")
+	}
+	sitemap.WriteString("</urlset>\n")
+
+	target := filepath.Join(targetDirectory(), "sitemap.xml")
+	if err := claimOutput(target, "the sitemap"); err != nil {
+		return err
+	}
+	return writeOutputFile(target, []byte(sitemap.String()))
+}
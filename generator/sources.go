@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// convertedSource is a content file in another format turned into HTML,
+// with the files it produced along the way, such as notebook plots.
+type convertedSource struct {
+	html   string
+	assets map[string][]byte
+}
+
+type sourceConverter func(path string, data []byte) (convertedSource, error)
+
+// sourceConverters turns content files into HTML pages by extension. HTML
+// sources are used as they are, and converters.commands adds extensions
+// converted by external programs.
+var sourceConverters = map[string]sourceConverter{
+	".ipynb": convertNotebook,
+	".org":   convertOrg,
+	".adoc":  convertAsciidoc,
+	".md":    convertMarkdown,
+}
+
+// convertedSources remembers every conversion, since a source is read for
+// the site index, its title and the page itself.
+var convertedSources = make(map[string]convertedSource)
+
+func isSourcePath(path string) bool {
+	ext := filepath.Ext(path)
+	_, ok := sourceConverters[ext]
+	return ext == ".html" || ok
+}
+
+// pagePath is where the page of a source is written, relative paths kept.
+func pagePath(path string) string {
+	if filepath.Ext(path) == ".html" || !isSourcePath(path) {
+		return path
+	}
+
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".html"
+}
+
+// directoryIndex returns the source of the index page of a directory.
+func directoryIndex(dir string) string {
+	for ext := range sourceConverters {
+		if path := filepath.Join(dir, "index"+ext); fileExists(path) {
+			return path
+		}
+	}
+
+	return filepath.Join(dir, "index.html")
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func convertSource(path string) (convertedSource, error) {
+	if source, ok := convertedSources[path]; ok {
+		return source, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return convertedSource{}, fmt.Errorf("Failed to open source: %w", err)
+	}
+
+	source := convertedSource{html: string(data)}
+	if convert, ok := sourceConverters[filepath.Ext(path)]; ok {
+		if source, err = convert(path, data); err != nil {
+			return source, fmt.Errorf("Failed to convert %s: %w", path, err)
+		}
+	}
+
+	convertedSources[path] = source
+	return source, nil
+}
+
+// sourceAsset returns a file made by a conversion by the content path it
+// would have had, for the outputs that embed images.
+func sourceAsset(path string) ([]byte, bool) {
+	for source, converted := range convertedSources {
+		if filepath.Dir(source) != filepath.Dir(path) {
+			continue
+		}
+		if data, ok := converted.assets[filepath.Base(path)]; ok {
+			return data, true
+		}
+	}
+
+	return nil, false
+}
+
+// writeSourceAssets writes the files a conversion produced next to the page.
+func writeSourceAssets(path string) error {
+	source, err := convertSource(path)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(targetPathFromContentPath(path))
+	for name, data := range source.assets {
+		target := filepath.Join(dir, name)
+		if err := recordOutput(target, path); err != nil {
+			return err
+		}
+		if err := writeOutputFile(target, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type sriConfig struct {
+	Enabled   bool     `json:"enabled"`
+	CacheFile string   `json:"cache_file"`
+	Exclude   []string `json:"exclude"`
+}
+
+var (
+	sriHashes       map[string]string
+	sriHashesLoaded bool
+)
+
+func sriCacheFile() string {
+	if config.Sri.CacheFile != "" {
+		return config.Sri.CacheFile
+	}
+
+	return cachePath("sri-hashes.json")
+}
+
+func saveSriHashes() error {
+	if !sriHashesLoaded {
+		return nil
+	}
+
+	return writeCacheFile(sriCacheFile(), sriHashes)
+}
+
+func fetchSriHash(link string) (string, error) {
+	if err := requireNetwork("integrity hashes", link); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("GET", link, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "site-generator")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned %s", link, resp.Status)
+	}
+
+	hash := sha512.New384()
+	if _, err := io.Copy(hash, resp.Body); err != nil {
+		return "", err
+	}
+
+	return "sha384-" + base64.StdEncoding.EncodeToString(hash.Sum(nil)), nil
+}
+
+// sriHash returns the integrity value of an external resource. Resources are
+// only downloaded the first time they are seen, so the cache also keeps the
+// build working offline.
+func sriHash(link string) (string, bool) {
+	if !sriHashesLoaded {
+		sriHashesLoaded = true
+		sriHashes = make(map[string]string)
+		readCacheFile(sriCacheFile(), &sriHashes)
+	}
+
+	if hash, ok := sriHashes[link]; ok {
+		return hash, true
+	}
+
+	hash, err := fetchSriHash(link)
+	if err != nil {
+		addDiagnostic("network", link, 0, "Cannot compute integrity hash: %s", err)
+		return "", false
+	}
+
+	sriHashes[link] = hash
+	return hash, true
+}
+
+func applySri(doc *goquery.Document) {
+	doc.Find(`script[src], link[rel~="stylesheet"][href]`).Each(func(_ int, element *goquery.Selection) {
+		if _, ok := element.Attr("integrity"); ok {
+			return
+		}
+
+		link, ok := element.Attr("src")
+		if !ok {
+			link, _ = element.Attr("href")
+		}
+		if strings.HasPrefix(link, "//") {
+			link = "https:" + link
+		}
+		if !strings.HasPrefix(link, "https://") && !strings.HasPrefix(link, "http://") {
+			return
+		}
+		for _, prefix := range config.Sri.Exclude {
+			if strings.HasPrefix(link, prefix) {
+				return
+			}
+		}
+
+		if hash, ok := sriHash(link); ok {
+			element.SetAttr("integrity", hash)
+			element.SetAttr("crossorigin", "anonymous")
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"html"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// articleSummary is the summary in the metadata, or an excerpt of the first
+// paragraph when there is none. Descriptions, feeds, the JSON index and
+// previews all take it from here.
+func articleSummary(art article) (string, error) {
+	if art.metadata.Summary != "" {
+		return art.metadata.Summary, nil
+	}
+
+	return articleExcerpt(art)
+}
+
+// addSummaryTags describes an article to search engines and link previews.
+func addSummaryTags(head *pageHead, art article) {
+	if art.summary == "" {
+		return
+	}
+
+	summary := html.EscapeString(art.summary)
+	head.add("description", `meta[name="description"]`, fmt.Sprintf(`<meta name="description" content="%s">`, summary))
+	head.add("og:description", `meta[property="og:description"]`,
+		fmt.Sprintf(`<meta property="og:description" content="%s">`, summary))
+}
+
+// summaryPreview is what a list page shows of an article with
+// summary_previews set: its heading and information followed by the summary.
+func summaryPreview(art article) (string, error) {
+	return modifyHtml(art.content, func(doc *goquery.Document) {
+		heading := doc.Find("h1").First().Clone()
+		info := doc.Find(".article-info").First().Clone()
+
+		body := doc.Find("body")
+		body.Empty()
+		body.AppendSelection(info)
+		body.AppendSelection(heading)
+		body.AppendHtml(fmt.Sprintf(`<p class="article-summary">%s</p>`, html.EscapeString(art.summary)))
+	})
+}
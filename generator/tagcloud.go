@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tagCloudConfig writes how often every tag is used to /tags.json, and with
+// html a ready list to /tag-cloud.html, for templates that show a tag cloud
+// or a topics sidebar. Levels is the number of sizes a tag can get.
+type tagCloudConfig struct {
+	Enabled bool `json:"enabled"`
+	Html    bool `json:"html"`
+	Levels  int  `json:"levels"`
+}
+
+type tagWeight struct {
+	Tag    string `json:"tag"`
+	Count  int    `json:"count"`
+	Weight int    `json:"weight"`
+}
+
+const (
+	tagCloudJsonUrl = "/tags.json"
+	tagCloudHtmlUrl = "/tag-cloud.html"
+)
+
+// tagCounts returns the number of articles of every tag, with the tags in the
+// order they were first seen.
+func tagCounts() (map[string]int, []string) {
+	counts := make(map[string]int)
+	var tags []string
+	for _, entry := range siteArticles {
+		for _, tag := range entry.tags {
+			if counts[tag] == 0 {
+				tags = append(tags, tag)
+			}
+			counts[tag]++
+		}
+	}
+
+	return counts, tags
+}
+
+// tagWeights gives every tag a weight from 1 to the configured levels, on a
+// logarithmic scale so a few popular tags do not flatten all the others. With
+// a limit only the most used tags are kept. The result is sorted by name.
+func tagWeights(limit int) []tagWeight {
+	levels := config.TagCloud.Levels
+	if levels <= 0 {
+		levels = 5
+	}
+
+	counts, tags := tagCounts()
+	sort.SliceStable(tags, func(i, j int) bool {
+		if counts[tags[i]] != counts[tags[j]] {
+			return counts[tags[i]] > counts[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+	if limit > 0 && limit < len(tags) {
+		tags = tags[:limit]
+	}
+	sort.Strings(tags)
+
+	least, most := math.MaxInt, 0
+	for _, tag := range tags {
+		least, most = min(least, counts[tag]), max(most, counts[tag])
+	}
+
+	weights := make([]tagWeight, 0, len(tags))
+	for _, tag := range tags {
+		weight := 1
+		if most > least {
+			scale := math.Log(float64(counts[tag])/float64(least)) / math.Log(float64(most)/float64(least))
+			weight = 1 + int(math.Round(scale*float64(levels-1)))
+		}
+		weights = append(weights, tagWeight{Tag: tag, Count: counts[tag], Weight: weight})
+	}
+
+	return weights
+}
+
+// renderTagCloud lists the tags with a tag-weight-N class that stylesheets
+// size them by.
+func renderTagCloud(args map[string]string) (string, error) {
+	limit := 0
+	if value, ok := args["limit"]; ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("Invalid limit %q", value)
+		}
+		limit = n
+	}
+
+	var out strings.Builder
+	out.WriteString(`<ul class="tag-cloud">`)
+	for _, tag := range tagWeights(limit) {
+		fmt.Fprintf(&out, `<li class="tag-weight-%d">%s <span class="tag-count">%d</span></li>`,
+			tag.Weight, html.EscapeString(tag.Tag), tag.Count)
+	}
+	out.WriteString(`</ul>`)
+
+	return out.String(), nil
+}
+
+func writeTagCloud() error {
+	if err := writeJsonFile(tagCloudJsonUrl, "the tag cloud", tagWeights(0)); err != nil {
+		return err
+	}
+	if !config.TagCloud.Html {
+		return nil
+	}
+
+	fragment, err := renderTagCloud(nil)
+	if err != nil {
+		return err
+	}
+
+	target := targetPathFromUrl(tagCloudHtmlUrl)
+	if err := claimOutput(target, "the tag cloud"); err != nil {
+		return err
+	}
+	if err := createDir(filepath.Dir(target)); err != nil {
+		return err
+	}
+	return writeOutputFile(target, []byte(fragment+"\n"))
+}
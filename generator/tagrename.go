@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// findJsonMember finds the member key of the object starting at start.
+func findJsonMember(text string, start int, key string) (jsonMember, bool) {
+	members, _ := jsonMembers(text, start)
+	for _, member := range members {
+		if member.key == key {
+			return member, true
+		}
+	}
+	return jsonMember{}, false
+}
+
+// renameJsonItems renames the string items of the array starting at start
+// for which rename gives a new name. An item that ends up with the name of
+// one before it is removed instead.
+func renameJsonItems(text string, start int, rename func(string) (string, bool)) (string, bool) {
+	items, _ := jsonMembers(text, start)
+	names := make([]string, len(items))
+	renamed := make([]bool, len(items))
+	changed := false
+	for i, item := range items {
+		json.Unmarshal([]byte(text[item.valueStart:item.valueEnd]), &names[i])
+		if name, ok := rename(names[i]); ok {
+			names[i], renamed[i], changed = name, true, true
+		}
+	}
+
+	// Going from the end keeps the positions of the items not edited yet.
+	for i := len(items) - 1; i >= 0; i-- {
+		if slices.Contains(names[:i], names[i]) {
+			text = removeJsonMember(text, items, i)
+		} else if renamed[i] {
+			encoded, _ := json.Marshal(names[i])
+			text = text[:items[i].valueStart] + string(encoded) + text[items[i].valueEnd:]
+		}
+		items, _ = jsonMembers(text, start)
+	}
+
+	return text, changed
+}
+
+// renameArticleTags renames a tag in the metadata of every article, keeping
+// the rest of each file as it is, and returns the files it changed.
+func renameArticleTags(rename func(string) (string, bool), write bool) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(contentDirectory(), "articles", "*", "metadata.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		text := string(data)
+		if !json.Valid(data) {
+			var value any
+			return nil, fmt.Errorf("Cannot rename tags: %w", jsonError(path, data, json.Unmarshal(data, &value)))
+		}
+
+		tags, ok := findJsonMember(text, skipJsonSpace(text, 0), "tags")
+		if !ok || text[tags.valueStart] != '[' {
+			continue
+		}
+		if text, ok = renameJsonItems(text, tags.valueStart, rename); !ok {
+			continue
+		}
+
+		changed = append(changed, path)
+		if write {
+			if err := rewriteFile(path, []byte(text)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return changed, nil
+}
+
+// renameConfigTag points the tag aliases of the config at the new name, makes
+// the old name an alias of it and renames the tag in sections. It returns
+// the new text of the config and the sections it changed.
+func renameConfigTag(text string, rename func(string) (string, bool), from string, to string) (string, []string) {
+	root := skipJsonSpace(text, 0)
+	members, end := jsonMembers(text, root)
+
+	if tags, ok := findJsonMember(text, root, "tags"); !ok {
+		text = insertJsonMember(text, members, end, fmt.Sprintf(`"tags": {"aliases": {%q: %q}}`, from, to))
+	} else if aliases, ok := findJsonMember(text, tags.valueStart, "aliases"); !ok {
+		fields, fieldsEnd := jsonMembers(text, tags.valueStart)
+		text = insertJsonMember(text, fields, fieldsEnd, fmt.Sprintf(`"aliases": {%q: %q}`, from, to))
+	} else {
+		// Aliases of the new name would now lead back to the old one.
+		entries, _ := jsonMembers(text, aliases.valueStart)
+		for i := len(entries) - 1; i >= 0; i-- {
+			if foldTag(entries[i].key) == foldTag(to) {
+				text = removeJsonMember(text, entries, i)
+				entries, _ = jsonMembers(text, aliases.valueStart)
+			}
+		}
+
+		found := false
+		for i := len(entries) - 1; i >= 0; i-- {
+			var target string
+			json.Unmarshal([]byte(text[entries[i].valueStart:entries[i].valueEnd]), &target)
+			if _, ok := rename(target); ok || foldTag(entries[i].key) == foldTag(from) {
+				encoded, _ := json.Marshal(to)
+				text = text[:entries[i].valueStart] + string(encoded) + text[entries[i].valueEnd:]
+				entries, _ = jsonMembers(text, aliases.valueStart)
+			}
+			found = found || foldTag(entries[i].key) == foldTag(from)
+		}
+		if !found {
+			entries, entriesEnd := jsonMembers(text, aliases.valueStart)
+			text = insertJsonMember(text, entries, entriesEnd, fmt.Sprintf("%q: %q", from, to))
+		}
+	}
+
+	var sections []string
+	list, ok := findJsonMember(text, root, "sections")
+	if !ok || text[list.valueStart] != '[' {
+		return text, sections
+	}
+	entries, _ := jsonMembers(text, list.valueStart)
+	for i := range entries {
+		section := entries[i]
+		tags, ok := findJsonMember(text, section.valueStart, "tags")
+		if !ok || text[tags.valueStart] != '[' {
+			continue
+		}
+
+		var renamed bool
+		if text, renamed = renameJsonItems(text, tags.valueStart, rename); renamed {
+			var name string
+			if member, ok := findJsonMember(text, section.valueStart, "name"); ok {
+				json.Unmarshal([]byte(text[member.valueStart:member.valueEnd]), &name)
+			}
+			sections = append(sections, name)
+		}
+		entries, _ = jsonMembers(text, list.valueStart)
+	}
+
+	return text, sections
+}
+
+// runTags changes tags across the whole site:
+//
+//	tags rename [--dry-run] old new
+//
+// Articles tagged with old, or with an alias of it, get new instead, and
+// the config keeps old as an alias of new so that links and imports using
+// it still find the tag. Only the sources change: the site has no page per
+// tag to redirect, and the sections listing the tag show the new name from
+// the next build on.
+func runTags(args []string) error {
+	if len(args) == 0 || args[0] != "rename" {
+		return fmt.Errorf("Usage: tags rename [--dry-run] old new")
+	}
+
+	flags := flag.NewFlagSet("tags rename", flag.ContinueOnError)
+	flags.StringVar(&buildEnvironment, "env", buildEnvironment, "config environment to use")
+	dryRun := flags.Bool("dry-run", false, "list what would change without changing it")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return fmt.Errorf("Usage: tags rename [--dry-run] old new")
+	}
+
+	if err := loadConfig(); err != nil {
+		return err
+	}
+
+	from := canonicalTag(flags.Arg(0))
+	to := strings.Join(strings.Fields(flags.Arg(1)), " ")
+	if from == "" || to == "" {
+		return fmt.Errorf("Tags cannot be empty")
+	}
+	if foldTag(to) == from {
+		return fmt.Errorf("%q is already named %q", flags.Arg(0), to)
+	}
+	rename := func(tag string) (string, bool) {
+		if canonicalTag(tag) == from {
+			return to, true
+		}
+		return "", false
+	}
+
+	changed, err := renameArticleTags(rename, !*dryRun)
+	if err != nil {
+		return err
+	}
+	for _, path := range changed {
+		fmt.Printf("Renamed tag in %s\n", path)
+	}
+
+	if path := configPath(); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("Cannot read config file: %s", path)
+		}
+		text, sections := renameConfigTag(string(data), rename, from, to)
+		if !*dryRun {
+			if err := rewriteFile(path, []byte(text)); err != nil {
+				return err
+			}
+		}
+
+		fmt.Printf("Made %q an alias of %q in %s\n", from, to, path)
+		for _, name := range sections {
+			fmt.Printf("Renamed tag in section %s, whose page changes with the next build\n", name)
+		}
+	}
+
+	fmt.Printf("Renamed %q to %q in %d articles\n", from, to, len(changed))
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunTagsRename(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		article string
+		args    []string
+		want    string
+		wantCfg string
+	}{
+		{
+			name:    "plain tag",
+			config:  "{}\n",
+			article: `{"tags": ["go", "web"]}`,
+			args:    []string{"rename", "go", "golang"},
+			want:    `{"tags": ["golang", "web"]}`,
+			wantCfg: "{\"tags\": {\"aliases\": {\"go\": \"golang\"}}}\n",
+		},
+		{
+			name:    "merges into a tag already there",
+			config:  "{}\n",
+			article: `{"tags": ["golang", "go"]}`,
+			args:    []string{"rename", "go", "golang"},
+			want:    `{"tags": ["golang"]}`,
+			wantCfg: "{\"tags\": {\"aliases\": {\"go\": \"golang\"}}}\n",
+		},
+		{
+			name:    "alias of the old name",
+			config:  `{"tags": {"aliases": {"golang": "go"}}}`,
+			article: `{"tags": ["golang"]}`,
+			args:    []string{"rename", "go", "Go language"},
+			want:    `{"tags": ["Go language"]}`,
+			wantCfg: `{"tags": {"aliases": {"golang": "Go language", "go": "Go language"}}}`,
+		},
+		{
+			name:    "dry run",
+			config:  "{}\n",
+			article: `{"tags": ["go"]}`,
+			args:    []string{"rename", "--dry-run", "go", "golang"},
+			want:    `{"tags": ["go"]}`,
+			wantCfg: "{}\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			content := filepath.Join(dir, "content")
+			configFile := filepath.Join(dir, "config.json")
+			t.Setenv("CONTENT_PATH", content)
+			t.Setenv("CONFIG_PATH", configFile)
+			saved := config
+			t.Cleanup(func() { config = saved })
+
+			writeTestFiles(t, dir, map[string]string{
+				"config.json":                      test.config,
+				"content/articles/a/index.md":      "# A\n",
+				"content/articles/a/metadata.json": test.article,
+			})
+			metadata := filepath.Join(content, "articles", "a", "metadata.json")
+			os.Chmod(metadata, 0600)
+
+			if err := runTags(test.args); err != nil {
+				t.Fatal(err)
+			}
+
+			for path, want := range map[string]string{metadata: test.want, configFile: test.wantCfg} {
+				data, err := os.ReadFile(path)
+				if err != nil || string(data) != want {
+					t.Errorf("%s = %q, %v; want %q", filepath.Base(path), data, err, want)
+				}
+			}
+			info, err := os.Stat(metadata)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("mode of the metadata = %v, want 0600", info.Mode().Perm())
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type tagsConfig struct {
+	Lowercase bool              `json:"lowercase"`
+	Aliases   map[string]string `json:"aliases"`
+}
+
+// tagMerges counts, per original spelling, the articles whose tag was
+// rewritten to a different canonical one.
+var tagMerges = make(map[string]map[string]int)
+
+func foldTag(tag string) string {
+	tag = strings.Join(strings.Fields(tag), " ")
+	if config.Tags.Lowercase {
+		tag = strings.ToLower(tag)
+	}
+
+	return tag
+}
+
+func canonicalTag(tag string) string {
+	tag = foldTag(tag)
+	for alias, target := range config.Tags.Aliases {
+		if foldTag(alias) == tag {
+			return foldTag(target)
+		}
+	}
+
+	return tag
+}
+
+// normalizeTags rewrites the tags of one article to their canonical names
+// and drops the duplicates this creates.
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		canonical := canonicalTag(tag)
+		if canonical != tag {
+			if tagMerges[canonical] == nil {
+				tagMerges[canonical] = make(map[string]int)
+			}
+			tagMerges[canonical][tag]++
+		}
+
+		if canonical != "" && !seen[canonical] {
+			seen[canonical] = true
+			normalized = append(normalized, canonical)
+		}
+	}
+
+	return normalized
+}
+
+func reportTagMerges() {
+	var canonical []string
+	for tag := range tagMerges {
+		canonical = append(canonical, tag)
+	}
+	sort.Strings(canonical)
+
+	for _, tag := range canonical {
+		var originals []string
+		for original, count := range tagMerges[tag] {
+			originals = append(originals, fmt.Sprintf("%q (%d)", original, count))
+		}
+		sort.Strings(originals)
+		fmt.Printf("Merged tags into %q: %s\n", tag, strings.Join(originals, ", "))
+	}
+}
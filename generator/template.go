@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// checkTemplate makes sure the template has every hook the generator fills
+// in, so a renamed id fails the build instead of producing empty pages.
+func checkTemplate() error {
+	return checkTemplateFile(templatePath())
+}
+
+func checkTemplateFile(path string) error {
+	data, err := readTemplateFile(path)
+	if err != nil {
+		return fmt.Errorf("Failed to open template: %w", err)
+	}
+
+	// The parser invents <head> and <title> when they are missing, so their
+	// presence is checked on the raw tokens.
+	explicit := make(map[string]bool)
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			if tokenizer.Err() != io.EOF {
+				return fmt.Errorf("Failed to parse template: %w", tokenizer.Err())
+			}
+			break
+		}
+		if tokenType == html.StartTagToken {
+			name, _ := tokenizer.TagName()
+			explicit[string(name)] = true
+		}
+	}
+
+	var missing []string
+	for _, tag := range []string{"head", "title"} {
+		if !explicit[tag] {
+			missing = append(missing, "<"+tag+">")
+		}
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("Failed to parse template: %w", err)
+	}
+
+	hooks := append([]string{"#content"}, config.TemplateHooks...)
+	if len(config.Menu) > 0 {
+		hooks = append(hooks, "#nav")
+	}
+	for _, hook := range hooks {
+		switch doc.Find(hook).Length() {
+		case 0:
+			missing = append(missing, hook)
+		case 1:
+		default:
+			return fmt.Errorf("Template %s has more than one %s", templateName(path), hook)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("Template %s is missing: %s", templateName(path), strings.Join(missing, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+type validateConfig struct {
+	Enabled bool `json:"enabled"`
+	Strict  bool `json:"strict"`
+}
+
+var voidElements = []string{
+	"area", "base", "br", "col", "embed", "hr", "img", "input",
+	"link", "meta", "source", "track", "wbr",
+}
+
+// Elements whose end tag may be left out without changing the document.
+var optionalEndTags = []string{
+	"html", "head", "body", "p", "li", "dt", "dd", "option", "optgroup",
+	"thead", "tbody", "tfoot", "tr", "td", "th", "colgroup", "rt", "rp",
+}
+
+func reportProblem(file string, line int, format string, args ...any) {
+	addDiagnostic("validate", file, line, format, args...)
+}
+
+type openElement struct {
+	name string
+	line int
+}
+
+// validateSource checks the raw source of a page, before the HTML parser
+// gets a chance to silently repair it.
+func validateSource(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var stack []openElement
+	line := 1
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			if tokenizer.Err() != io.EOF {
+				reportProblem(path, line, "%s", tokenizer.Err())
+			}
+			break
+		}
+
+		tokenLine := line
+		raw := tokenizer.Raw()
+		line += bytes.Count(raw, []byte("\n"))
+
+		token := tokenizer.Token()
+		switch tokenType {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			seen := make(map[string]bool)
+			for _, attr := range token.Attr {
+				if seen[attr.Key] {
+					reportProblem(path, tokenLine, "duplicate attribute %q on <%s>", attr.Key, token.Data)
+				}
+				seen[attr.Key] = true
+			}
+
+			void := slices.Contains(voidElements, token.Data)
+			if tokenType == html.SelfClosingTagToken && !void {
+				reportProblem(path, tokenLine, "<%s/> is not a void element, the slash is ignored", token.Data)
+			}
+			if tokenType == html.StartTagToken && !void {
+				stack = append(stack, openElement{token.Data, tokenLine})
+			}
+		case html.EndTagToken:
+			if slices.Contains(voidElements, token.Data) {
+				reportProblem(path, tokenLine, "</%s> closes a void element", token.Data)
+				continue
+			}
+
+			match := -1
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].name == token.Data {
+					match = i
+					break
+				}
+			}
+			if match == -1 {
+				reportProblem(path, tokenLine, "</%s> has no matching start tag", token.Data)
+				continue
+			}
+
+			for _, open := range stack[match+1:] {
+				if !slices.Contains(optionalEndTags, open.name) {
+					reportProblem(path, open.line, "<%s> is not closed before </%s>", open.name, token.Data)
+				}
+			}
+			stack = stack[:match]
+		}
+	}
+
+	for _, open := range stack {
+		if !slices.Contains(optionalEndTags, open.name) {
+			reportProblem(path, open.line, "<%s> is never closed", open.name)
+		}
+	}
+
+	return nil
+}
+
+func validateIds(doc *goquery.Document, page string) {
+	seen := make(map[string]bool)
+	doc.Find("[id]").Each(func(_ int, element *goquery.Selection) {
+		id, _ := element.Attr("id")
+		if seen[id] {
+			reportProblem(page, 0, "duplicate id %q", id)
+		}
+		seen[id] = true
+	})
+}
+
+// reportValidation fails strict builds on any validation problem. The
+// problems themselves are reported with the other diagnostics.
+func reportValidation() error {
+	problems := countDiagnostics("validate", "alt-text")
+	if problems > 0 && config.Validate.Strict {
+		return fmt.Errorf("HTML validation found %d problems", problems)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var (
+	templateVariable = regexp.MustCompile(`\{\{\s*(site|page)\.(\w+)\s*((?:\|[^|}]*)*)\}\}`)
+
+	// escapedVariable is a variable written as {{/* page.title */}}, which
+	// the page shows as {{ page.title }}, for pages about the syntax.
+	escapedVariable = regexp.MustCompile(`\{\{/\*\s*(.*?)\s*\*/\}\}`)
+
+	variableOrEscape = regexp.MustCompile(escapedVariable.String() + "|" + templateVariable.String())
+)
+
+func pageVariables(url string, title string) map[string]string {
+	return map[string]string{
+		"url":   url,
+		"title": title,
+	}
+}
+
+func articleVariables(art article) map[string]string {
+	variables := pageVariables(art.url, art.title)
+	variables["date"] = art.metadata.ReleaseDate
+	variables["updated"] = art.updated.Format("2006-01-02")
+	variables["word_count"] = strconv.Itoa(art.metadata.WordCount)
+	variables["estimated_time"] = strconv.Itoa(art.metadata.EstimatedTime)
+	variables["tags"] = strings.Join(art.metadata.Tags, ", ")
+	variables["series"] = art.metadata.Series
+	variables["summary"] = art.summary
+
+	return variables
+}
+
+// substituteVariables resolves {{ site.x }} from the config and {{ page.x }}
+// from the page being built, passing them through any filters that follow.
+// Unknown names fail the build rather than leak into the output.
+// {{/* page.x */}} shows as {{ page.x }} instead.
+func substituteVariables(text string, page map[string]string, source string) (string, error) {
+	site := map[string]string{"base_url": config.BaseUrl}
+	for key, value := range config.Site {
+		site[key] = value
+	}
+
+	var unknown, filterErrors []string
+	text = variableOrEscape.ReplaceAllStringFunc(text, func(match string) string {
+		if escaped := escapedVariable.FindStringSubmatch(match); escaped != nil {
+			return "{{ " + escaped[1] + " }}"
+		}
+
+		parts := templateVariable.FindStringSubmatch(match)
+		values := page
+		if parts[1] == "site" {
+			values = site
+		}
+
+		value, ok := values[parts[2]]
+		if !ok {
+			unknown = append(unknown, describeAt(source, text, match, parts[1]+"."+parts[2]))
+			return match
+		}
+
+		value, err := applyFilters(value, parts[3])
+		if err != nil {
+			filterErrors = append(filterErrors, fmt.Sprintf("%s: %s", describeAt(source, text, match, parts[1]+"."+parts[2]), err))
+			return match
+		}
+		return value
+	})
+
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("Unknown variables in %s: %s", source, strings.Join(unknown, ", "))
+	}
+	if len(filterErrors) > 0 {
+		return "", fmt.Errorf("Invalid filters in %s: %s", source, strings.Join(filterErrors, "; "))
+	}
+
+	return text, nil
+}
+
+func templateWithVariables(page map[string]string) (*goquery.Document, error) {
+	return templateFileWithVariables(templatePath(), page)
+}
+
+func templateFileWithVariables(path string, page map[string]string) (*goquery.Document, error) {
+	data, err := readTemplateFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open template: %w", err)
+	}
+
+	text, err := expandQueries(string(data), templateName(path))
+	if err != nil {
+		return nil, err
+	}
+
+	text, err = expandRefs(text, templateName(path))
+	if err != nil {
+		return nil, err
+	}
+
+	text, err = substituteVariables(text, page, templateName(path))
+	if err != nil {
+		return nil, err
+	}
+
+	tmplDoc, err := goquery.NewDocumentFromReader(strings.NewReader(text))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse template: %w", err)
+	}
+
+	return tmplDoc, nil
+}
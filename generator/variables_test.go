@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestSubstituteVariables(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.Site = map[string]string{"title": "Bored"}
+	page := map[string]string{"title": "Hello", "date": "2024-03-05"}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"site", "<title>{{ site.title }}</title>", "<title>Bored</title>"},
+		{"page with filter", `{{ page.date | dateFormat "2 Jan" }}`, "5 Mar"},
+		{"escaped", "Write {{/* page.title */}} for the title.", "Write {{ page.title }} for the title."},
+		{"escaped with a filter", `<code>{{/* page.date | dateFormat "2 Jan" */}}</code>`, `<code>{{ page.date | dateFormat "2 Jan" }}</code>`},
+		{"escaped next to a variable", "{{/*page.title*/}} is {{ page.title }}", "{{ page.title }} is Hello"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := substituteVariables(test.text, page, "test.html")
+			if err != nil || got != test.want {
+				t.Errorf("substituteVariables(%q) = %q, %v; want %q", test.text, got, err, test.want)
+			}
+		})
+	}
+
+	if _, err := substituteVariables("{{ page.missing }}", page, "test.html"); err == nil {
+		t.Error("an unknown variable was accepted")
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type videoConfig struct {
+	Enabled   bool     `json:"enabled"`
+	Ffmpeg    string   `json:"ffmpeg"`
+	Transcode []string `json:"transcode"`
+	Poster    []string `json:"poster"`
+}
+
+// The arguments are passed to ffmpeg with {input} and {output} replaced.
+var (
+	defaultTranscodeArgs = []string{"-y", "-loglevel", "error", "-i", "{input}", "-c:v", "libx264", "-pix_fmt", "yuv420p",
+		"-movflags", "+faststart", "-c:a", "aac", "{output}"}
+	defaultPosterArgs = []string{"-y", "-loglevel", "error", "-i", "{input}", "-frames:v", "1", "-q:v", "3", "{output}"}
+)
+
+type processedVideo struct {
+	mp4    string
+	poster string
+}
+
+// processedVideos remembers the outputs made for every video so pages
+// sharing a video only process it once.
+var processedVideos = make(map[string]processedVideo)
+
+// isUpToDate tells whether the output was written after its source last
+// changed, so the work that makes it can be skipped.
+func isUpToDate(output string, source string) bool {
+	outputInfo, err := os.Stat(output)
+	if err != nil {
+		return false
+	}
+	sourceInfo, err := os.Stat(source)
+	return err == nil && outputInfo.ModTime().After(sourceInfo.ModTime())
+}
+
+// runFfmpeg writes the file at url u from the video at path. ffmpeg writes
+// into a directory of its own next to the output, which is then renamed
+// into place, so a failed or interrupted run never leaves half a file.
+func runFfmpeg(args []string, path string, u string) error {
+	output := targetPathFromUrl(u)
+	if err := recordOutput(output, path); err != nil {
+		return err
+	}
+	if isUpToDate(output, path) {
+		return nil
+	}
+
+	ffmpeg := config.Video.Ffmpeg
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+
+	dir, err := os.MkdirTemp(filepath.Dir(output), "."+filepath.Base(output)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	partial := filepath.Join(dir, filepath.Base(output))
+
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		expanded[i] = strings.NewReplacer("{input}", path, "{output}", partial).Replace(arg)
+	}
+
+	cmd := exec.Command(ffmpeg, expanded...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ffmpeg failed on %s: %w\n%s", path, err, out)
+	}
+
+	return os.Rename(partial, output)
+}
+
+// processVideo makes a poster image and, unless the video already is an MP4,
+// a web friendly transcode next to the copied video.
+func processVideo(path string) (processedVideo, error) {
+	if video, ok := processedVideos[path]; ok {
+		return video, nil
+	}
+
+	var video processedVideo
+	base := strings.TrimSuffix(urlFromContentPath(path), filepath.Ext(path))
+	if err := createDir(filepath.Dir(targetPathFromUrl(base))); err != nil {
+		return video, err
+	}
+
+	posterArgs := config.Video.Poster
+	if len(posterArgs) == 0 {
+		posterArgs = defaultPosterArgs
+	}
+	if err := runFfmpeg(posterArgs, path, base+".poster.jpg"); err != nil {
+		return video, err
+	}
+	video.poster = base + ".poster.jpg"
+
+	if !strings.EqualFold(filepath.Ext(path), ".mp4") {
+		transcodeArgs := config.Video.Transcode
+		if len(transcodeArgs) == 0 {
+			transcodeArgs = defaultTranscodeArgs
+		}
+		if err := runFfmpeg(transcodeArgs, path, base+".mp4"); err != nil {
+			return video, err
+		}
+		video.mp4 = base + ".mp4"
+	}
+
+	processedVideos[path] = video
+	return video, nil
+}
+
+// processVideos gives every local video in the content a poster and an MP4
+// source ahead of the original one.
+func processVideos(content *goquery.Selection, page string) error {
+	var videoErr error
+	content.Find("video").Each(func(_ int, element *goquery.Selection) {
+		if videoErr != nil {
+			return
+		}
+
+		src, ok := element.Attr("src")
+		if !ok {
+			src, _ = element.Find("source[src]").First().Attr("src")
+		}
+		path, ok := contentPathFromUrl(src, page)
+		if !ok {
+			return
+		}
+
+		video, err := processVideo(path)
+		if err != nil {
+			videoErr = fmt.Errorf("%s in %s: %w", elementSelector(element), page, err)
+			return
+		}
+
+		if _, ok := element.Attr("poster"); !ok {
+			element.SetAttr("poster", video.poster)
+		}
+		if video.mp4 != "" {
+			if _, ok := element.Attr("src"); ok {
+				element.RemoveAttr("src")
+				element.PrependHtml(fmt.Sprintf(`<source src="%s">`, html.EscapeString(src)))
+			}
+			element.PrependHtml(fmt.Sprintf(`<source src="%s" type="video/mp4">`, html.EscapeString(video.mp4)))
+		}
+	})
+
+	return videoErr
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunFfmpeg(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the stand-in for ffmpeg is a shell script")
+	}
+
+	dir := t.TempDir()
+	t.Setenv("CONTENT_PATH", filepath.Join(dir, "content"))
+	t.Setenv("TARGET_PATH", filepath.Join(dir, "site"))
+	saved, savedOwners, savedOutputs := config, outputOwners, outputSources
+	t.Cleanup(func() { config, outputOwners, outputSources = saved, savedOwners, savedOutputs })
+
+	// The stand-in writes its arguments to the output, or fails after
+	// writing part of it when asked to.
+	writeTestFiles(t, dir, map[string]string{
+		"content/articles/a/clip.webm": "video",
+		"site/articles/a/.keep":        "",
+		"ffmpeg": "#!/bin/sh\nfor out; do :; done\necho run >> \"" + filepath.Join(dir, "runs") + "\"\n" +
+			"echo \"$@\" > \"$out\"\n[ \"$1\" != fail ]\n",
+	})
+	os.Chmod(filepath.Join(dir, "ffmpeg"), 0755)
+	config.Video.Ffmpeg = filepath.Join(dir, "ffmpeg")
+
+	source := filepath.Join(dir, "content", "articles", "a", "clip.webm")
+	output := filepath.Join(dir, "site", "articles", "a", "clip.mp4")
+	runs := func() int {
+		data, _ := os.ReadFile(filepath.Join(dir, "runs"))
+		return strings.Count(string(data), "run")
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		touch    bool
+		wantErr  bool
+		wantRuns int
+		want     string
+	}{
+		{"first run", []string{"ok", "{output}"}, false, false, 1, "ok "},
+		{"up to date", []string{"again", "{output}"}, false, false, 1, "ok "},
+		{"source changed", []string{"again", "{output}"}, true, false, 2, "again "},
+		{"failed run keeps the old output", []string{"fail", "{output}"}, true, true, 3, "again "},
+	}
+
+	for _, test := range tests {
+		outputOwners, outputSources = make(map[string]string), make(map[string]string)
+		if test.touch {
+			later := time.Now().Add(time.Hour)
+			os.Chtimes(source, later, later)
+		}
+
+		err := runFfmpeg(test.args, source, "/articles/a/clip.mp4")
+		if (err != nil) != test.wantErr {
+			t.Fatalf("%s: runFfmpeg = %v, want an error %v", test.name, err, test.wantErr)
+		}
+		if got := runs(); got != test.wantRuns {
+			t.Errorf("%s: ffmpeg ran %d times, want %d", test.name, got, test.wantRuns)
+		}
+		data, err := os.ReadFile(output)
+		if err != nil || !strings.HasPrefix(string(data), test.want) {
+			t.Errorf("%s: output = %q, %v; want it to start with %q", test.name, data, err, test.want)
+		}
+		entries, _ := os.ReadDir(filepath.Dir(output))
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), ".clip") {
+				t.Errorf("%s: %s left next to the output", test.name, entry.Name())
+			}
+		}
+	}
+}
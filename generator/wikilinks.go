@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+var wikiLink = regexp.MustCompile(`\[\[([^\s\[\]|][^\[\]|\n]*?)(?:\|([^\[\]\n]+))?\]\]`)
+
+// findWikiTargets returns the pages a wiki link may mean, matched by title
+// or by slug.
+func findWikiTargets(name string) []siteEntry {
+	var targets []siteEntry
+	for _, list := range [][]siteEntry{siteArticles, sitePages} {
+		for _, entry := range list {
+			slug := strings.TrimSuffix(filepath.Base(entry.source), filepath.Ext(entry.source))
+			if slug == "index" {
+				slug = filepath.Base(filepath.Dir(entry.source))
+			}
+
+			if strings.EqualFold(entry.title, name) || slug == name || slugify(entry.title) == slugify(name) {
+				targets = append(targets, entry)
+			}
+		}
+	}
+
+	return targets
+}
+
+func wikiLinkHtml(match string, source string) string {
+	parts := wikiLink.FindStringSubmatch(match)
+	name := strings.TrimSpace(parts[1])
+	label := strings.TrimSpace(parts[2])
+	if label == "" {
+		label = name
+	}
+
+	targets := findWikiTargets(name)
+	switch len(targets) {
+	case 0:
+		addDiagnostic("wiki-link", source, 0, "no page for [[%s]]", name)
+		return html.EscapeString(match)
+	case 1:
+		return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(targets[0].url), html.EscapeString(label))
+	default:
+		var urls []string
+		for _, target := range targets {
+			urls = append(urls, target.url)
+		}
+		addDiagnostic("wiki-link", source, 0, "[[%s]] is ambiguous between %s", name, strings.Join(urls, ", "))
+		return html.EscapeString(match)
+	}
+}
+
+// resolveWikiLinks turns [[Title]] and [[slug|label]] in the text of a
+// document into links. Code is left alone.
+func resolveWikiLinks(doc *goquery.Document, source string) {
+	doc.Find("*").Not("pre, code, script, style, textarea").Contents().Each(func(_ int, node *goquery.Selection) {
+		if node.Nodes[0].Type != html.TextNode || !wikiLink.MatchString(node.Nodes[0].Data) {
+			return
+		}
+		if node.ParentsFiltered("pre, code, a").Length() > 0 {
+			return
+		}
+
+		text := node.Nodes[0].Data
+		var replaced strings.Builder
+		last := 0
+		for _, loc := range wikiLink.FindAllStringIndex(text, -1) {
+			replaced.WriteString(html.EscapeString(text[last:loc[0]]))
+			replaced.WriteString(wikiLinkHtml(text[loc[0]:loc[1]], source))
+			last = loc[1]
+		}
+		replaced.WriteString(html.EscapeString(text[last:]))
+
+		node.ReplaceWithHtml(replaced.String())
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// wordCountConfig counts the words and reading time of articles from their
+// content instead of taking them from the metadata. Code blocks, tables and
+// figure captions can be left out of the count; code is then read at
+// seconds_per_code_line instead. Languages sets the reading speed of a
+// language, in characters per minute for Chinese and Japanese.
+type wordCountConfig struct {
+	Enabled            bool           `json:"enabled"`
+	ExcludeCode        bool           `json:"exclude_code"`
+	ExcludeTables      bool           `json:"exclude_tables"`
+	ExcludeCaptions    bool           `json:"exclude_captions"`
+	SecondsPerCodeLine float64        `json:"seconds_per_code_line"`
+	WordsPerMinute     int            `json:"words_per_minute"`
+	Languages          map[string]int `json:"languages"`
+}
+
+// defaultReadingRates are the reading speeds measured by Trauzettel-Klosinski
+// et al. (2012) for languages far from the English default.
+var defaultReadingRates = map[string]int{"zh": 255, "ja": 357, "ar": 138}
+
+// readingRate is how many words, or characters for the scripts read by the
+// character, a reader gets through per minute in the language.
+func readingRate(lang string) int {
+	lang = strings.ToLower(lang)
+	base, _, _ := strings.Cut(lang, "-")
+
+	if rate := config.WordCount.Languages[lang]; rate > 0 {
+		return rate
+	}
+	if rate := config.WordCount.Languages[base]; rate > 0 {
+		return rate
+	}
+	if rate := defaultReadingRates[base]; rate > 0 {
+		return rate
+	}
+	if config.WordCount.WordsPerMinute > 0 {
+		return config.WordCount.WordsPerMinute
+	}
+	return wordsPerMinute
+}
+
+// countTokens counts the words of a text. Han, Hiragana and Katakana have no
+// spaces between words and count by the character. Elsewhere a word is a run
+// of letters, marks and digits, held together by the zero width non-joiner
+// of Persian, apostrophes and hyphens, so punctuation on its own is no word.
+func countTokens(text string) int {
+	count := 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana):
+			count++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			if !inWord {
+				count++
+				inWord = true
+			}
+		case r == '\u200c' || r == '\'' || r == '’' || r == '-':
+		default:
+			inWord = false
+		}
+	}
+
+	return count
+}
+
+// measureArticle returns the number of words of an article and the minutes
+// it takes to read in its language.
+func measureArticle(content string, lang string) (int, int, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return 0, 0, fmt.Errorf("Failed to parse HTML: %w", err)
+	}
+
+	codeLines := 0
+	if config.WordCount.ExcludeCode {
+		doc.Find("pre").Each(func(_ int, pre *goquery.Selection) {
+			codeLines += len(strings.Split(strings.TrimRight(pre.Text(), "\n"), "\n"))
+		})
+		doc.Find("pre").Remove()
+	}
+	if config.WordCount.ExcludeTables {
+		doc.Find("table").Remove()
+	}
+	if config.WordCount.ExcludeCaptions {
+		doc.Find("figcaption").Remove()
+	}
+
+	words := countTokens(doc.Text())
+	seconds := float64(words)/float64(readingRate(lang))*60 + float64(codeLines)*config.WordCount.SecondsPerCodeLine
+	return words, max(int(math.Ceil(seconds/60)), 1), nil
+}
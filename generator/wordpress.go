@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	wordpressBlockComment = regexp.MustCompile(`<!--\s*/?wp:[^>]*-->`)
+	wordpressBlankLine    = regexp.MustCompile(`\n\s*\n`)
+	wordpressBlockTag     = regexp.MustCompile(`^<(?i:p|div|h[1-6]|ul|ol|li|blockquote|pre|table|figure|hr|img|iframe|section|dl|address)\b`)
+)
+
+// wxrItem is an item of a WordPress export (WXR) file.
+type wxrItem struct {
+	Title      string `xml:"title"`
+	Link       string `xml:"link"`
+	Content    string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PostName   string `xml:"post_name"`
+	PostDate   string `xml:"post_date"`
+	Status     string `xml:"status"`
+	PostType   string `xml:"post_type"`
+	Categories []struct {
+		Domain string `xml:"domain,attr"`
+		Name   string `xml:",chardata"`
+	} `xml:"category"`
+}
+
+type wxrFile struct {
+	Items []wxrItem `xml:"channel>item"`
+}
+
+// wordpressParagraphs does what WordPress does when it renders a classic
+// post: text separated by blank lines becomes paragraphs.
+func wordpressParagraphs(content string) string {
+	content = wordpressBlockComment.ReplaceAllString(content, "")
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	var blocks []string
+	for _, block := range wordpressBlankLine.Split(content, -1) {
+		block = strings.TrimSpace(block)
+		if block == "" {
+			continue
+		}
+		if !wordpressBlockTag.MatchString(block) {
+			block = "<p>" + strings.ReplaceAll(block, "\n", "<br>\n") + "</p>"
+		}
+		blocks = append(blocks, block)
+	}
+
+	return strings.Join(blocks, "\n")
+}
+
+func (imp *importer) importWordpress(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Cannot read export: %w", err)
+	}
+
+	var export wxrFile
+	if err := xml.Unmarshal(data, &export); err != nil {
+		return fmt.Errorf("Cannot parse WordPress export %s: %w", path, err)
+	}
+
+	for _, item := range export.Items {
+		if item.PostType != "post" || item.Status == "trash" || item.Status == "auto-draft" {
+			continue
+		}
+
+		date, err := time.Parse(time.DateTime, item.PostDate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping %s: invalid date %q\n", item.Title, item.PostDate)
+			imp.skipped++
+			continue
+		}
+
+		post := importedPost{
+			slug:  item.PostName,
+			title: item.Title,
+			date:  date,
+			html:  wordpressParagraphs(item.Content),
+			draft: item.Status != "publish" && item.Status != "future",
+		}
+		for _, category := range item.Categories {
+			name := strings.TrimSpace(category.Name)
+			if (category.Domain == "post_tag" || category.Domain == "category") && name != "" &&
+				!strings.EqualFold(name, "uncategorized") {
+				post.tags = append(post.tags, name)
+			}
+		}
+
+		// Links of unpublished posts and ?p= links cannot be redirected from
+		// a static site.
+		if u, err := url.Parse(item.Link); err == nil && u.RawQuery == "" && u.Path != "" && u.Path != "/" {
+			post.aliases = []string{u.Path}
+		}
+
+		if err := imp.writePost(post); err != nil {
+			return fmt.Errorf("Cannot import %s: %w", item.Title, err)
+		}
+	}
+
+	return nil
+}